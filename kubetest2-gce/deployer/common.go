@@ -26,6 +26,7 @@ import (
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/boskos"
+	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
 const (
@@ -54,7 +55,7 @@ func (d *deployer) initialize() error {
 		if d.GCPProject == "" {
 			klog.V(1).Info("No GCP project provided, acquiring from Boskos")
 
-			boskosClient, err := boskos.NewClient(d.BoskosLocation)
+			boskosClient, err := boskos.NewClient(types.Context(d.commonOptions), d.BoskosLocation, d.BoskosUsername, d.BoskosPassword)
 			if err != nil {
 				return fmt.Errorf("failed to make boskos client: %s", err)
 			}
@@ -153,6 +154,10 @@ func (d *deployer) buildEnv() []string {
 		env = append(env, fmt.Sprintf("KUBE_RUNTIME_CONFIG=%s", d.RuntimeConfig))
 	}
 
+	if d.FeatureGates != "" {
+		env = append(env, fmt.Sprintf("KUBE_FEATURE_GATES=%s", d.FeatureGates))
+	}
+
 	if d.EnablePodSecurityPolicy {
 		env = append(env, "ENABLE_POD_SECURITY_POLICY=true")
 	}
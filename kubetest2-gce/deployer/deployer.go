@@ -33,6 +33,8 @@ import (
 
 	"sigs.k8s.io/kubetest2/kubetest2-gce/deployer/options"
 	"sigs.k8s.io/kubetest2/pkg/build"
+	"sigs.k8s.io/kubetest2/pkg/credentials"
+	"sigs.k8s.io/kubetest2/pkg/kubeconfig"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
@@ -72,11 +74,14 @@ type deployer struct {
 	EnableComputeAPI            bool   `desc:"If set, the deployer will enable the compute API for the project during the Up phase. This is necessary if the project has not been used before. WARNING: The currently configured GCP account must have permission to enable this API on the configured project."`
 	OverwriteLogsDir            bool   `desc:"If set, will overwrite an existing logs directory if one is encountered during dumping of logs. Useful when runnning tests locally."`
 	BoskosLocation              string `desc:"If set, manually specifies the location of the boskos server. If unset and boskos is needed, defaults to http://boskos.test-pods.svc.cluster.local."`
+	BoskosUsername              string `desc:"If set, the username to authenticate to the boskos server with, requires --boskos-password."`
+	BoskosPassword              string `desc:"The password of --boskos-username."`
 	LegacyMode                  bool   `desc:"Set if the provided repo root is the kubernetes/kubernetes repo and not kubernetes/cloud-provider-gcp."`
 	NumNodes                    int    `desc:"The number of nodes in the cluster."`
 
 	EnableCacheMutationDetector bool   `desc:"Sets the environment variable ENABLE_CACHE_MUTATION_DETECTOR=true during deployment. This should cause a panic if anything mutates a shared informer cache."`
 	RuntimeConfig               string `desc:"Sets the KUBE_RUNTIME_CONFIG environment variable during deployment."`
+	FeatureGates                string `desc:"Sets the KUBE_FEATURE_GATES environment variable during deployment, e.g. Gate1=true,Gate2=false."`
 	EnablePodSecurityPolicy     bool   `desc:"Sets the environment variable ENABLE_POD_SECURITY_POLICY=true during deployment."`
 	CreateCustomNetwork         bool   `desc:"Sets the environment variable CREATE_CUSTOM_NETWORK=true during deployment."`
 }
@@ -128,6 +133,8 @@ func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
 	if err != nil {
 		klog.Fatalf("couldn't parse flagset for deployer struct: %s", err)
 	}
+	// the references of the secrets can't be described in the struct tags
+	flagSet.Lookup("boskos-password").Usage += " " + credentials.Usage
 
 	// initing the klog flags adds them to goflag.CommandLine
 	// they can then be added to the built pflag set
@@ -148,6 +155,10 @@ func (d *deployer) Provider() string {
 	return Name
 }
 
+// Kubeconfig returns the path to a kubeconfig in the run dir holding the
+// context of the cluster, named by kubeconfig.ContextName(Name, instance prefix),
+// which is merged from the kubeconfig written by kube-up.sh. It also sets the
+// KUBECONFIG environment variable to it.
 func (d *deployer) Kubeconfig() (string, error) {
 	_, err := os.Stat(d.kubeconfigPath)
 	if os.IsNotExist(err) {
@@ -157,5 +168,8 @@ func (d *deployer) Kubeconfig() (string, error) {
 		return "", fmt.Errorf("unknown error when checking for kubeconfig at %s: %s", d.kubeconfigPath, err)
 	}
 
-	return d.kubeconfigPath, nil
+	return kubeconfig.Write(filepath.Join(d.commonOptions.RunDir(), "kubeconfig"), kubeconfig.Source{
+		Path: d.kubeconfigPath,
+		Name: kubeconfig.ContextName(Name, d.instancePrefix),
+	})
 }
@@ -24,6 +24,7 @@ import (
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/featuregates"
 	"sigs.k8s.io/kubetest2/pkg/fs"
 )
 
@@ -152,6 +153,13 @@ func (d *deployer) verifyUpFlags() error {
 		return err
 	}
 
+	if _, err := featuregates.Parse(d.FeatureGates); err != nil {
+		return fmt.Errorf("invalid --feature-gates: %s", err)
+	}
+	if _, err := featuregates.ParseRuntimeConfig(d.RuntimeConfig); err != nil {
+		return fmt.Errorf("invalid --runtime-config: %s", err)
+	}
+
 	// verifyUpFlags does not check for a gcp project because it is
 	// assumed that one will be acquired from boskos if it is not set
 
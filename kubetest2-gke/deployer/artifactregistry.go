@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// remoteDockerRepos maps the supported upstream registries to the
+// --remote-docker-repo value of the Artifact Registry remote repository.
+var remoteDockerRepos = map[string]string{
+	"docker.io":       "DOCKER-HUB",
+	"registry.k8s.io": "https://registry.k8s.io",
+}
+
+func (d *deployer) verifyArtifactRegistryMirrorFlags() error {
+	for _, upstream := range d.artifactRegistryMirrors {
+		if _, ok := remoteDockerRepos[upstream]; !ok {
+			return fmt.Errorf("unsupported --artifact-registry-mirror %q, must be one of docker.io, registry.k8s.io", upstream)
+		}
+	}
+	return nil
+}
+
+// artifactRegistryMirrorName returns the name of the per-run remote
+// repository mirroring the upstream registry.
+func artifactRegistryMirrorName(runID, upstream string) string {
+	return runResourceName(runID, upstream)
+}
+
+// artifactRegistryMirrorEndpoint returns the endpoint containerd pulls the
+// images of the upstream registry from.
+func artifactRegistryMirrorEndpoint(project, region, name string) string {
+	return fmt.Sprintf("https://%s-docker.pkg.dev/v2/%s/%s", region, project, name)
+}
+
+// createArtifactRegistryMirrors creates the remote repositories in the host
+// project, and configures them as registry mirrors on the cluster nodes.
+// The node service account needs read access to the repositories.
+// Reference: https://cloud.google.com/artifact-registry/docs/repositories/remote-repo
+func (d *deployer) createArtifactRegistryMirrors() error {
+	project := d.projects[0]
+	region := regionFromLocation(d.region, d.zone)
+	for _, upstream := range d.artifactRegistryMirrors {
+		name := artifactRegistryMirrorName(d.commonOptions.RunID(), upstream)
+		klog.V(1).Infof("Creating Artifact Registry remote repository %s mirroring %s", name, upstream)
+		if err := runWithOutput(exec.Command("gcloud", "artifacts", "repositories", "create", name,
+			"--project="+project,
+			"--location="+region,
+			"--repository-format=docker",
+			"--mode=remote-repository",
+			"--remote-docker-repo="+remoteDockerRepos[upstream],
+			"--description=kubetest2 mirror of "+upstream+" for run "+d.commonOptions.RunID(),
+		)); err != nil {
+			return fmt.Errorf("error creating the Artifact Registry remote repository for %s: %w", upstream, err)
+		}
+		d.registryMirrors = append(d.registryMirrors, upstream+"="+artifactRegistryMirrorEndpoint(project, region, name))
+	}
+	return nil
+}
+
+// deleteArtifactRegistryMirrors deletes the remote repositories created by
+// createArtifactRegistryMirrors.
+func (d *deployer) deleteArtifactRegistryMirrors() error {
+	project := d.projects[0]
+	region := regionFromLocation(d.region, d.zone)
+	for _, upstream := range d.artifactRegistryMirrors {
+		name := artifactRegistryMirrorName(d.commonOptions.RunID(), upstream)
+		if err := runWithOutput(exec.Command("gcloud", "artifacts", "repositories", "delete", name,
+			"--project="+project,
+			"--location="+region,
+			"--quiet",
+		)); err != nil {
+			return fmt.Errorf("error deleting the Artifact Registry remote repository %s: %w", name, err)
+		}
+	}
+	return nil
+}
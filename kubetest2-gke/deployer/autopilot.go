@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	computeClassLabel  = "cloud.google.com/compute-class"
+	machineFamilyLabel = "cloud.google.com/machine-family"
+	performanceClass   = "Performance"
+
+	warmupName = "kubetest2-warmup"
+)
+
+// warmupManifestTemplate is a placeholder workload with one pod per node.
+// The pods have a negative priority, so they are preempted as soon as the
+// tests need the capacity, while the nodes they scaled up remain.
+var warmupManifestTemplate = template.Must(template.New("warmup").Parse(`apiVersion: scheduling.k8s.io/v1
+kind: PriorityClass
+metadata:
+  name: {{.Name}}
+value: -10
+preemptionPolicy: Never
+globalDefault: false
+description: "Placeholder pods used by kubetest2 to pre-scale GKE Autopilot clusters."
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Name}}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+  namespace: {{.Name}}
+spec:
+  replicas: {{.Replicas}}
+  selector:
+    matchLabels:
+      name: {{.Name}}
+  template:
+    metadata:
+      labels:
+        name: {{.Name}}
+    spec:
+      priorityClassName: {{.Name}}
+      terminationGracePeriodSeconds: 0
+{{- if .NodeSelector}}
+      nodeSelector:
+{{- range $key, $value := .NodeSelector}}
+        {{$key}}: {{$value}}
+{{- end}}
+{{- end}}
+      affinity:
+        podAntiAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+          - labelSelector:
+              matchLabels:
+                name: {{.Name}}
+            topologyKey: kubernetes.io/hostname
+      containers:
+      - name: pause
+        image: registry.k8s.io/pause:3.9
+        resources:
+          requests:
+            cpu: {{.CPU}}
+            memory: {{.Memory}}
+`))
+
+// verifyAutopilotFlags validates the flags only used for GKE Autopilot clusters.
+func (d *deployer) verifyAutopilotFlags() error {
+	if !d.autopilot {
+		if d.autopilotComputeClass != "" || d.autopilotMachineFamily != "" || d.autopilotWarmupNodes != 0 {
+			return errors.New("--autopilot-compute-class, --autopilot-machine-family and --autopilot-warmup-nodes can only be used with --autopilot")
+		}
+		return nil
+	}
+	// Machine families can only be selected with the Performance compute class
+	// or custom compute classes.
+	// Reference: https://cloud.google.com/kubernetes-engine/docs/concepts/autopilot-compute-classes
+	if d.autopilotMachineFamily != "" && d.autopilotComputeClass == "" {
+		return errors.New("--autopilot-machine-family requires --autopilot-compute-class, e.g. " + performanceClass)
+	}
+	if d.autopilotWarmupNodes < 0 {
+		return errors.New("--autopilot-warmup-nodes must not be negative")
+	}
+	return nil
+}
+
+// autopilotNodeSelector returns the node selector workloads should use to
+// run on the requested compute class and machine family.
+func (d *deployer) autopilotNodeSelector() map[string]string {
+	selector := map[string]string{}
+	if d.autopilotComputeClass != "" {
+		selector[computeClassLabel] = d.autopilotComputeClass
+	}
+	if d.autopilotMachineFamily != "" {
+		selector[machineFamilyLabel] = d.autopilotMachineFamily
+	}
+	return selector
+}
+
+// autopilotTesterEnv exposes the node selector of the Autopilot hardware
+// selection to the tester as KUBETEST2_AUTOPILOT_NODE_SELECTOR, in the format
+// of key=value separated by comma, so the test workloads can select it too.
+func (d *deployer) autopilotTesterEnv() []string {
+	selector := d.autopilotNodeSelector()
+	if !d.autopilot || len(selector) == 0 {
+		return nil
+	}
+	pairs := make([]string, 0, len(selector))
+	for key, value := range selector {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return []string{"KUBETEST2_AUTOPILOT_NODE_SELECTOR=" + strings.Join(pairs, ",")}
+}
+
+// recordAutopilotMetadata records the Autopilot hardware selection in the
+// metadata, so testers can schedule their workloads accordingly.
+func (d *deployer) recordAutopilotMetadata() {
+	d.metadata.Add("autopilot", d.autopilot)
+	if !d.autopilot {
+		return
+	}
+	d.metadata.Add("autopilot-compute-class", d.autopilotComputeClass)
+	d.metadata.Add("autopilot-machine-family", d.autopilotMachineFamily)
+	d.metadata.Add("autopilot-node-selector", d.autopilotNodeSelector())
+	d.metadata.Add("autopilot-warmup-nodes", d.autopilotWarmupNodes)
+}
+
+func (d *deployer) warmupManifest() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := warmupManifestTemplate.Execute(&buf, struct {
+		Name         string
+		Replicas     int
+		NodeSelector map[string]string
+		CPU          string
+		Memory       string
+	}{
+		Name:         warmupName,
+		Replicas:     d.autopilotWarmupNodes,
+		NodeSelector: d.autopilotNodeSelector(),
+		CPU:          d.autopilotWarmupCPU,
+		Memory:       d.autopilotWarmupMemory,
+	}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// warmUpAutopilotClusters deploys a placeholder workload to pre-scale the
+// GKE Autopilot clusters to the requested number of nodes, and waits for it
+// to be ready, so the tests do not flake on the scale-up latency. The
+// clusters are warmed up concurrently.
+func (d *deployer) warmUpAutopilotClusters() error {
+	if !d.autopilot || d.autopilotWarmupNodes == 0 {
+		return nil
+	}
+
+	manifest, err := d.warmupManifest()
+	if err != nil {
+		return err
+	}
+	return d.forEachCluster(func(project string, cluster cluster) error {
+		klog.V(1).Infof("Warming up cluster %s in %s with %d nodes", cluster.name, project, d.autopilotWarmupNodes)
+		start := time.Now()
+
+		kubeconfig := d.clusterKubeconfig(project, cluster.name)
+		apply := kubectlCommand(kubeconfig, "apply", "-f", "-")
+		apply.SetStdin(bytes.NewReader(manifest))
+		if err := runWithOutput(apply); err != nil {
+			return fmt.Errorf("error applying the warm-up workload: %w", err)
+		}
+		if err := runWithOutput(kubectlCommand(kubeconfig, "rollout", "status", "deployment/"+warmupName,
+			"--namespace="+warmupName, "--timeout="+d.autopilotWarmupTimeout.String())); err != nil {
+			return fmt.Errorf("error waiting for the warm-up workload: %w", err)
+		}
+		klog.V(1).Infof("Cluster %s in %s warmed up in %v", cluster.name, project, time.Since(start))
+		return nil
+	})
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAutopilotNodeSelector(t *testing.T) {
+	testCases := []struct {
+		name             string
+		d                deployer
+		expectedSelector string
+		expectedEnv      []string
+	}{
+		{
+			name: "no hardware selection",
+			d:    deployer{autopilot: true, autopilotWarmupNodes: 3},
+		},
+		{
+			name:             "compute class",
+			d:                deployer{autopilot: true, autopilotWarmupNodes: 3, autopilotComputeClass: "Scale-Out"},
+			expectedSelector: "      nodeSelector:\n        cloud.google.com/compute-class: Scale-Out\n",
+			expectedEnv:      []string{"KUBETEST2_AUTOPILOT_NODE_SELECTOR=cloud.google.com/compute-class=Scale-Out"},
+		},
+		{
+			name:             "compute class and machine family",
+			d:                deployer{autopilot: true, autopilotWarmupNodes: 3, autopilotComputeClass: performanceClass, autopilotMachineFamily: "c3"},
+			expectedSelector: "      nodeSelector:\n        cloud.google.com/compute-class: Performance\n        cloud.google.com/machine-family: c3\n",
+			expectedEnv:      []string{"KUBETEST2_AUTOPILOT_NODE_SELECTOR=cloud.google.com/compute-class=Performance,cloud.google.com/machine-family=c3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			manifest, err := tc.d.warmupManifest()
+			if err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			if tc.expectedSelector == "" {
+				if strings.Contains(string(manifest), "nodeSelector") {
+					st.Errorf("expected no node selector in the warm-up manifest, got:\n%s", manifest)
+				}
+			} else if !strings.Contains(string(manifest), tc.expectedSelector) {
+				st.Errorf("expected the node selector %q in the warm-up manifest, got:\n%s", tc.expectedSelector, manifest)
+			}
+			if diff := cmp.Diff(tc.expectedEnv, tc.d.autopilotTesterEnv()); diff != "" {
+				st.Errorf("tester env differs (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVerifyAutopilotFlags(t *testing.T) {
+	testCases := []struct {
+		name      string
+		d         deployer
+		expectErr bool
+	}{
+		{
+			name: "compute class and machine family",
+			d:    deployer{autopilot: true, autopilotComputeClass: performanceClass, autopilotMachineFamily: "c3"},
+		},
+		{
+			name:      "machine family without compute class",
+			d:         deployer{autopilot: true, autopilotMachineFamily: "c3"},
+			expectErr: true,
+		},
+		{
+			name:      "compute class without autopilot",
+			d:         deployer{autopilotComputeClass: performanceClass},
+			expectErr: true,
+		},
+		{
+			name:      "negative warm-up nodes",
+			d:         deployer{autopilot: true, autopilotWarmupNodes: -1},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			err := tc.d.verifyAutopilotFlags()
+			if (err != nil) != tc.expectErr {
+				st.Errorf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestWarmUpAutopilotClusters(t *testing.T) {
+	f, restore := useFakeCmder(func(args []string) (string, error) {
+		if strings.Contains(strings.Join(args, " "), "kubecfg-p2-b rollout") {
+			return "", errors.New("timed out")
+		}
+		return "", nil
+	})
+	defer restore()
+
+	d := &deployer{
+		autopilot:              true,
+		autopilotWarmupNodes:   2,
+		autopilotWarmupTimeout: time.Minute,
+		setupConcurrency:       2,
+		kubecfgDir:             "/kubeconfigs",
+		projects:               []string{"p1", "p2"},
+		projectClustersLayout: map[string][]cluster{
+			"p1": {{name: "a"}},
+			"p2": {{index: 1, name: "b"}},
+		},
+	}
+	err := d.warmUpAutopilotClusters()
+	if err == nil || !strings.Contains(err.Error(), "cluster b in p2") {
+		t.Errorf("expected the failure of cluster b in p2, got %v", err)
+	}
+
+	ran := f.ran()
+	sort.Strings(ran)
+	expected := []string{
+		"kubectl --kubeconfig=/kubeconfigs/kubecfg-p1-a apply -f -",
+		"kubectl --kubeconfig=/kubeconfigs/kubecfg-p1-a rollout status deployment/kubetest2-warmup --namespace=kubetest2-warmup --timeout=1m0s",
+		"kubectl --kubeconfig=/kubeconfigs/kubecfg-p2-b apply -f -",
+		"kubectl --kubeconfig=/kubeconfigs/kubecfg-p2-b rollout status deployment/kubetest2-warmup --namespace=kubetest2-warmup --timeout=1m0s",
+	}
+	if diff := cmp.Diff(expected, ran); diff != "" {
+		t.Errorf("commands differ (-want, +got):\n%s", diff)
+	}
+}
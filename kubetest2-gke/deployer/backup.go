@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const backupRestoreAddon = "BackupRestore"
+
+func backupPlanName(clusterName string) string {
+	return clusterName + "-plan"
+}
+
+func backupName(clusterName string) string {
+	return clusterName + "-pre-test"
+}
+
+// clusterResourceName returns the full resource name of the cluster used by
+// the other GCP APIs.
+func (d *deployer) clusterResourceName(project, clusterName string) string {
+	location := d.region
+	if d.zone != "" {
+		location = d.zone
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/clusters/%s", project, location, clusterName)
+}
+
+// backupClusters takes a backup of the selected namespaces of all the clusters
+// with Backup for GKE, and records the backup names in the metadata so that
+// destructive test suites can restore the state between test groups.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/add-on/backup-for-gke/how-to/backup
+func (d *deployer) backupClusters() error {
+	if !d.backupBeforeTest {
+		return nil
+	}
+
+	scopeArg := "--all-namespaces"
+	if len(d.backupNamespaces) > 0 {
+		scopeArg = "--selected-namespaces=" + strings.Join(d.backupNamespaces, ",")
+	}
+	region := regionFromLocation(d.region, d.zone)
+	backups := make(map[string]string)
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			if d.autopilot {
+				if err := runWithOutput(exec.Command("gcloud", containerArgs("clusters", "update", cluster.name,
+					"--project="+project,
+					locationFlag(d.region, d.zone),
+					"--update-addons="+backupRestoreAddon+"=ENABLED")...)); err != nil {
+					return fmt.Errorf("error enabling the Backup for GKE agent on cluster %s: %w", cluster.name, err)
+				}
+			}
+
+			plan := backupPlanName(cluster.name)
+			klog.V(1).Infof("Creating backup plan %s for cluster %s in %s", plan, cluster.name, project)
+			if err := runWithOutput(exec.Command("gcloud", containerArgs("backup-restore", "backup-plans", "create", plan,
+				"--project="+project,
+				"--location="+region,
+				"--cluster="+d.clusterResourceName(project, cluster.name),
+				scopeArg,
+				"--include-secrets",
+				"--include-volume-data")...)); err != nil {
+				return fmt.Errorf("error creating the backup plan for cluster %s: %w", cluster.name, err)
+			}
+
+			backup := backupName(cluster.name)
+			klog.V(1).Infof("Taking backup %s of cluster %s in %s", backup, cluster.name, project)
+			if err := runWithOutput(exec.Command("gcloud", containerArgs("backup-restore", "backups", "create", backup,
+				"--project="+project,
+				"--location="+region,
+				"--backup-plan="+plan,
+				"--wait-for-completion")...)); err != nil {
+				return fmt.Errorf("error taking the backup of cluster %s: %w", cluster.name, err)
+			}
+			backups[cluster.name] = fmt.Sprintf("projects/%s/locations/%s/backupPlans/%s/backups/%s", project, region, plan, backup)
+		}
+	}
+	d.metadata.Add("backups", backups)
+	return nil
+}
+
+// deleteBackups deletes the backups and backup plans created by
+// backupClusters.
+func (d *deployer) deleteBackups() error {
+	if !d.backupBeforeTest {
+		return nil
+	}
+
+	region := regionFromLocation(d.region, d.zone)
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			plan := backupPlanName(cluster.name)
+			// A backup plan can only be deleted after all its backups are deleted.
+			if err := runWithOutput(exec.Command("gcloud", containerArgs("backup-restore", "backups", "delete", backupName(cluster.name),
+				"--project="+project,
+				"--location="+region,
+				"--backup-plan="+plan,
+				"--quiet")...)); err != nil {
+				return fmt.Errorf("error deleting the backup of cluster %s: %w", cluster.name, err)
+			}
+			if err := runWithOutput(exec.Command("gcloud", containerArgs("backup-restore", "backup-plans", "delete", plan,
+				"--project="+project,
+				"--location="+region,
+				"--quiet")...)); err != nil {
+				return fmt.Errorf("error deleting the backup plan of cluster %s: %w", cluster.name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -25,6 +25,7 @@ import (
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/build"
+	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
 var (
@@ -86,7 +87,13 @@ func (d *deployer) Build() error {
 			return fmt.Errorf("error staging build: %v", err)
 		}
 	}
-	d.Version = version
+	// The clusters come up with --cluster-version while building in parallel,
+	// the build is only used by the tests.
+	if d.buildInParallelWithUp() {
+		klog.V(1).Infof("Building in parallel with up, not creating the clusters with the build version %s", version)
+	} else {
+		d.Version = version
+	}
 	build.StoreCommonBinaries(d.RepoRoot, d.commonOptions.RunDir())
 	return nil
 }
@@ -96,7 +103,7 @@ func (d *deployer) verifyBuildFlags() error {
 		return fmt.Errorf("required repo-root when building from source")
 	}
 	d.BuildOptions.CommonBuildOptions.RepoRoot = d.RepoRoot
-	if d.commonOptions.ShouldBuild() && d.commonOptions.ShouldUp() && d.BuildOptions.CommonBuildOptions.StageLocation == "" {
+	if d.commonOptions.ShouldBuild() && d.commonOptions.ShouldUp() && !d.buildInParallelWithUp() && d.BuildOptions.CommonBuildOptions.StageLocation == "" {
 		return fmt.Errorf("creating a gke cluster from built sources requires staging them to a specific GCS bucket, use --stage=gs://<bucket>")
 	}
 	// force extra GCP files to be staged
@@ -106,6 +113,18 @@ func (d *deployer) verifyBuildFlags() error {
 	return d.BuildOptions.Validate()
 }
 
+// buildInParallelWithUp returns true if kubetest2 builds while the clusters
+// come up, with --parallel-build.
+func (d *deployer) buildInParallelWithUp() bool {
+	return d.commonOptions.ShouldBuild() && d.commonOptions.ShouldUp() && types.ParallelBuild(d.commonOptions)
+}
+
+// SupportsParallelBuild implements types.DeployerWithParallelBuild, the
+// clusters come up from --cluster-version while building.
+func (d *deployer) SupportsParallelBuild() bool {
+	return true
+}
+
 // ensure that the version is a valid gke version
 func normalizeVersion(version string) (string, error) {
 
@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+// The capabilities of the clusters recorded in the metadata, which the ginkgo
+// tester selects the tests with (see --auto-skip).
+const (
+	capabilityAutopilot      = "autopilot"
+	capabilityPrivateCluster = "private-cluster"
+	capabilityWindows        = "windows"
+	capabilityDataplaneV2    = "dataplane-v2"
+	capabilityNetworkPolicy  = "network-policy"
+)
+
+// clusterCapabilities returns the capabilities of the clusters relevant to
+// the choice of the tests to run.
+func (d *deployer) clusterCapabilities() []string {
+	createFlags := map[string]bool{}
+	for _, f := range d.createCommand() {
+		createFlags[f] = true
+	}
+
+	capabilities := []string{}
+	if d.autopilot {
+		capabilities = append(capabilities, capabilityAutopilot)
+	}
+	if d.privateClusterAccessLevel != "" {
+		capabilities = append(capabilities, capabilityPrivateCluster)
+	}
+	if d.windowsNodes > 0 {
+		capabilities = append(capabilities, capabilityWindows)
+	}
+	// GKE Autopilot clusters always use GKE Dataplane V2, which enforces the
+	// network policies.
+	dataplaneV2 := d.autopilot || createFlags["--enable-dataplane-v2"] || createFlags["--datapath-provider=advanced"]
+	if dataplaneV2 {
+		capabilities = append(capabilities, capabilityDataplaneV2)
+	}
+	if dataplaneV2 || createFlags["--enable-network-policy"] {
+		capabilities = append(capabilities, capabilityNetworkPolicy)
+	}
+	return capabilities
+}
+
+// recordCapabilities records the capabilities of the clusters in the
+// metadata.
+func (d *deployer) recordCapabilities() {
+	d.metadata.Add("capabilities", d.clusterCapabilities())
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClusterCapabilities(t *testing.T) {
+	testCases := []struct {
+		name     string
+		d        *deployer
+		expected []string
+	}{
+		{
+			name:     "standard cluster",
+			d:        &deployer{},
+			expected: []string{},
+		},
+		{
+			name:     "autopilot private cluster",
+			d:        &deployer{autopilot: true, privateClusterAccessLevel: "no"},
+			expected: []string{"autopilot", "private-cluster", "dataplane-v2", "network-policy"},
+		},
+		{
+			name:     "windows node pool with network policy",
+			d:        &deployer{windowsNodes: 1, gcloudExtraFlags: "--enable-network-policy"},
+			expected: []string{"windows", "network-policy"},
+		},
+		{
+			name:     "dataplane v2 from the create command",
+			d:        &deployer{createCommandFlag: "beta container clusters create --quiet --enable-dataplane-v2"},
+			expected: []string{"dataplane-v2", "network-policy"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			if diff := cmp.Diff(tc.expected, tc.d.clusterCapabilities()); diff != "" {
+				st.Errorf("capabilities differ (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
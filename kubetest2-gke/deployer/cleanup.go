@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+const (
+	downModeCluster    = "cluster"
+	downModeNamespaces = "namespaces"
+)
+
+// leftoverKinds are the kinds of resources deleted by --down-mode=namespaces,
+// in order. The webhooks go first as they could block the other deletions,
+// and the cluster-scoped resources go last as they may be referenced from the
+// namespaces.
+var leftoverKinds = []string{
+	"validatingwebhookconfigurations",
+	"mutatingwebhookconfigurations",
+	"namespaces",
+	"customresourcedefinitions",
+	"apiservices",
+	"clusterrolebindings",
+	"clusterroles",
+	"priorityclasses",
+	"storageclasses",
+	"persistentvolumes",
+}
+
+func (d *deployer) verifyDownModeFlags() error {
+	switch d.downMode {
+	case downModeCluster:
+		if d.downLabelSelector != "" {
+			return fmt.Errorf("--down-label-selector can only be used with --down-mode=%s", downModeNamespaces)
+		}
+	case downModeNamespaces:
+		// kubetest2 does not create the resources of the tests, so it cannot
+		// tell them apart from those of the other users of the clusters.
+		if d.downLabelSelector == "" {
+			return fmt.Errorf("--down-label-selector must be set with --down-mode=%s", downModeNamespaces)
+		}
+	default:
+		return fmt.Errorf("--down-mode must be one of %v", []string{downModeCluster, downModeNamespaces})
+	}
+	return nil
+}
+
+// cleanupLeftovers deletes the namespaces, CRDs and cluster-scoped resources
+// matching --down-label-selector instead of deleting the clusters, so that
+// shared clusters can be reused safely between runs.
+func (d *deployer) cleanupLeftovers() error {
+	if _, err := d.Kubeconfig(); err != nil {
+		return err
+	}
+	selector := d.downLabelSelector
+	klog.V(0).Infof("Keeping the clusters, deleting the resources matching %q", selector)
+
+	var cleanups operationTracker
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			kubeconfig := d.clusterKubeconfig(project, cluster.name)
+			cleanups.start(types.Context(d.commonOptions), "clean up cluster "+cluster.name, d.cleanupTimeout, func(ctx context.Context) error {
+				for _, kind := range leftoverKinds {
+					if err := runWithOutput(exec.CommandContext(ctx, "kubectl", "--kubeconfig="+kubeconfig,
+						"delete", kind,
+						"--selector="+selector,
+						"--ignore-not-found",
+						"--wait=true")); err != nil {
+						return fmt.Errorf("error deleting the %s: %w", kind, err)
+					}
+				}
+				return nil
+			})
+		}
+	}
+	return cleanups.wait()
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+)
+
+func TestVerifyDownModeFlags(t *testing.T) {
+	testCases := []struct {
+		name          string
+		downMode      string
+		labelSelector string
+		expectError   bool
+	}{
+		{
+			name:     "cluster",
+			downMode: downModeCluster,
+		},
+		{
+			name:          "label selector without namespaces",
+			downMode:      downModeCluster,
+			labelSelector: "app=test",
+			expectError:   true,
+		},
+		{
+			name:          "namespaces",
+			downMode:      downModeNamespaces,
+			labelSelector: "app=test",
+		},
+		{
+			name:        "namespaces without label selector",
+			downMode:    downModeNamespaces,
+			expectError: true,
+		},
+		{
+			name:        "unknown mode",
+			downMode:    "nodes",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			d := &deployer{downMode: tc.downMode, downLabelSelector: tc.labelSelector}
+			err := d.verifyDownModeFlags()
+			if tc.expectError && err == nil {
+				st.Error("expected an error but got nil")
+			} else if !tc.expectError && err != nil {
+				st.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// addonsConfigFields maps the addonsConfig fields of a cluster to the addons
+// of the cluster creation command.
+var addonsConfigFields = map[string]string{
+	"httpLoadBalancing":                "HttpLoadBalancing",
+	"horizontalPodAutoscaling":         "HorizontalPodAutoscaling",
+	"networkPolicyConfig":              "NetworkPolicy",
+	"dnsCacheConfig":                   nodeLocalDNSAddon,
+	"gcePersistentDiskCsiDriverConfig": "GcePersistentDiskCsiDriver",
+	"gcpFilestoreCsiDriverConfig":      "GcpFilestoreCsiDriver",
+	"gcsFuseCsiDriverConfig":           "GcsFuseCsiDriver",
+	"gkeBackupAgentConfig":             backupRestoreAddon,
+	"configConnectorConfig":            "ConfigConnector",
+}
+
+// disabledConfigFields are the addonsConfig fields which are enabled unless
+// they are disabled, the other addons are disabled unless they are enabled.
+var disabledConfigFields = map[string]bool{
+	"httpLoadBalancing":        true,
+	"horizontalPodAutoscaling": true,
+	"networkPolicyConfig":      true,
+}
+
+// clusterSpec is the part of the description of a cluster replayed by
+// --clone-from-cluster.
+type clusterSpec struct {
+	CurrentMasterVersion string `json:"currentMasterVersion"`
+	ReleaseChannel       struct {
+		Channel string `json:"channel"`
+	} `json:"releaseChannel"`
+	Autopilot struct {
+		Enabled bool `json:"enabled"`
+	} `json:"autopilot"`
+	// the addons are either enabled or disabled depending on the addon
+	AddonsConfig map[string]struct {
+		Enabled  bool `json:"enabled"`
+		Disabled bool `json:"disabled"`
+	} `json:"addonsConfig"`
+	NodePools []clonedNodePool `json:"nodePools"`
+}
+
+type clonedNodePool struct {
+	Name             string `json:"name"`
+	InitialNodeCount int    `json:"initialNodeCount"`
+	Config           struct {
+		MachineType string `json:"machineType"`
+		ImageType   string `json:"imageType"`
+	} `json:"config"`
+}
+
+// parseCloneSource parses --clone-from-cluster in the format of
+// project/location/name.
+func parseCloneSource(source string) (project, location, name string, err error) {
+	parts := strings.Split(source, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("--clone-from-cluster does not follow expected format (project/location/name): %s", source)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// loadCloneSource describes the cluster of --clone-from-cluster, and replays
+// its version, release channel, default node pool and addons into the flags
+// left to their defaults. Its other node pools are created after the clusters.
+func (d *deployer) loadCloneSource() error {
+	if d.cloneFromCluster == "" {
+		return nil
+	}
+	project, location, name, err := parseCloneSource(d.cloneFromCluster)
+	if err != nil {
+		return err
+	}
+	var spec clusterSpec
+	if err := gcloudJSON(&spec, containerArgs("clusters", "describe", name,
+		"--project="+project,
+		clusterLocationFlag(location))...); err != nil {
+		return fmt.Errorf("error describing the cluster to clone %s: %s", d.cloneFromCluster, execError(err))
+	}
+	if spec.Autopilot.Enabled != d.autopilot {
+		return fmt.Errorf("--autopilot must match the mode of the cluster to clone %s", d.cloneFromCluster)
+	}
+	d.applyClusterSpec(&spec)
+	d.metadata.Add("cloned-from-cluster", d.cloneFromCluster)
+	return nil
+}
+
+// applyClusterSpec replays the settings of the cluster spec into the flags
+// left to their defaults, so the explicitly set flags take precedence.
+func (d *deployer) applyClusterSpec(spec *clusterSpec) {
+	if d.Version == "" && spec.CurrentMasterVersion != "" {
+		d.Version = spec.CurrentMasterVersion
+	}
+	if d.ReleaseChannel == "" && spec.ReleaseChannel.Channel != "" {
+		d.ReleaseChannel = strings.ToLower(spec.ReleaseChannel.Channel)
+	}
+	disabledDefaults := false
+	for field, config := range spec.AddonsConfig {
+		addon, ok := addonsConfigFields[field]
+		if !ok {
+			continue
+		}
+		if disabledConfigFields[field] && !config.Disabled || config.Enabled {
+			d.clonedAddons = append(d.clonedAddons, addon)
+		} else if containsString(defaultAddons, addon) {
+			disabledDefaults = true
+		}
+	}
+	// The order of the addons does not matter, but keep the args stable.
+	sort.Strings(d.clonedAddons)
+	// The default addons are disabled by listing the others in --addons,
+	// which cannot be empty.
+	if disabledDefaults && len(d.clonedAddons) == 0 && !d.backupBeforeTest && !d.nodeLocalDNSCache {
+		klog.Warningf("The default addons disabled on the cluster to clone %s cannot be disabled without enabling another addon", d.cloneFromCluster)
+	}
+
+	if d.autopilot || len(spec.NodePools) == 0 {
+		return
+	}
+	defaultPool := spec.NodePools[0]
+	if d.machineType == defaultNodePool.MachineType && defaultPool.Config.MachineType != "" {
+		d.machineType = defaultPool.Config.MachineType
+	}
+	if d.nodes == defaultNodePool.Nodes && defaultPool.InitialNodeCount > 0 {
+		d.nodes = defaultPool.InitialNodeCount
+	}
+	if d.imageType == defaultImage && len(d.imageTypes) == 0 && defaultPool.Config.ImageType != "" {
+		d.imageType = defaultPool.Config.ImageType
+	}
+	d.clonedNodePools = spec.NodePools[1:]
+	klog.V(1).Infof("Cloning cluster %s: version %q, release channel %q, addons %v, %d extra node pool(s)",
+		d.cloneFromCluster, d.Version, d.ReleaseChannel, d.clonedAddons, len(d.clonedNodePools))
+}
+
+// clonedNodePoolArgs returns the args for creating a node pool of the cloned
+// cluster in a cluster.
+func (d *deployer) clonedNodePoolArgs(project, loc, clusterName string, pool clonedNodePool) []string {
+	args := make([]string, 0)
+	if d.gcloudCommandGroup != "" {
+		args = append(args, d.gcloudCommandGroup)
+	}
+	args = append(args, "container", "node-pools", "create", pool.Name,
+		"--quiet",
+		"--cluster="+clusterName,
+		"--project="+project,
+		loc,
+	)
+	if pool.Config.MachineType != "" {
+		args = append(args, "--machine-type="+pool.Config.MachineType)
+	}
+	if pool.Config.ImageType != "" {
+		args = append(args, "--image-type="+pool.Config.ImageType)
+	}
+	if pool.InitialNodeCount > 0 {
+		args = append(args, "--num-nodes="+strconv.Itoa(pool.InitialNodeCount))
+	}
+	args = append(args, d.alphaNodePoolArgs()...)
+	return args
+}
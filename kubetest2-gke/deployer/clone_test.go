@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyClusterSpec(t *testing.T) {
+	const description = `{
+  "currentMasterVersion": "1.27.3-gke.100",
+  "releaseChannel": {"channel": "REGULAR"},
+  "addonsConfig": {
+    "httpLoadBalancing": {},
+    "horizontalPodAutoscaling": {"disabled": true},
+    "dnsCacheConfig": {"enabled": true},
+    "gcePersistentDiskCsiDriverConfig": {"enabled": true},
+    "gcpFilestoreCsiDriverConfig": {}
+  },
+  "nodePools": [
+    {"name": "default-pool", "initialNodeCount": 2, "config": {"machineType": "e2-standard-8", "imageType": "COS_CONTAINERD"}},
+    {"name": "highmem", "initialNodeCount": 1, "config": {"machineType": "n2-highmem-4", "imageType": "UBUNTU_CONTAINERD"}}
+  ]
+}`
+	var spec clusterSpec
+	if err := json.Unmarshal([]byte(description), &spec); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	d := &deployer{
+		Version:     "",
+		machineType: "n2-standard-4",
+		nodes:       defaultNodePool.Nodes,
+		imageType:   defaultImage,
+	}
+	d.applyClusterSpec(&spec)
+
+	if d.Version != "1.27.3-gke.100" || d.ReleaseChannel != "regular" {
+		t.Errorf("expected the version and release channel to be cloned, got %q and %q", d.Version, d.ReleaseChannel)
+	}
+	// --machine-type is explicitly set.
+	if d.machineType != "n2-standard-4" {
+		t.Errorf("expected the machine type to not be cloned, got %q", d.machineType)
+	}
+	if d.nodes != 2 || d.imageType != "COS_CONTAINERD" {
+		t.Errorf("expected the default node pool to be cloned, got %d nodes of %q", d.nodes, d.imageType)
+	}
+	if diff := cmp.Diff([]string{"GcePersistentDiskCsiDriver", "HttpLoadBalancing", nodeLocalDNSAddon}, d.clonedAddons); diff != "" {
+		t.Errorf("cloned addons differ (-want, +got): %s", diff)
+	}
+	if len(d.clonedNodePools) != 1 || d.clonedNodePools[0].Name != "highmem" {
+		t.Errorf("expected the highmem node pool to be cloned, got %v", d.clonedNodePools)
+	}
+	// HorizontalPodAutoscaling is disabled on the cluster to clone, so it is
+	// left out of --addons.
+	d.cloneFromCluster = "p/us-central1/c"
+	if diff := cmp.Diff([]string{"GcePersistentDiskCsiDriver", "HttpLoadBalancing", nodeLocalDNSAddon}, d.addons()); diff != "" {
+		t.Errorf("addons differ (-want, +got): %s", diff)
+	}
+
+	var out bytes.Buffer
+	d.zone = "us-central1-c"
+	d.projects = []string{"p1"}
+	d.projectClustersLayout = map[string][]cluster{"p1": {{index: 0, name: "kt2-1"}}}
+	d.printUpPlan(&out)
+	if plan := out.String(); !strings.Contains(plan, "kt2-1 (highmem)") || !strings.Contains(plan, `release channel "regular"`) {
+		t.Errorf("expected the plan to show the cloned settings, got:\n%s", plan)
+	}
+}
+
+func TestAddons(t *testing.T) {
+	d := &deployer{}
+	if addons := d.addons(); len(addons) != 0 {
+		t.Errorf("expected no addons by default, got %v", addons)
+	}
+	// --addons disables the default addons it does not list.
+	d.nodeLocalDNSCache = true
+	if diff := cmp.Diff([]string{nodeLocalDNSAddon, "HorizontalPodAutoscaling", "HttpLoadBalancing"}, d.addons()); diff != "" {
+		t.Errorf("addons differ (-want, +got): %s", diff)
+	}
+}
+
+func TestParseCloneSource(t *testing.T) {
+	project, location, name, err := parseCloneSource("my-project/us-central1-a/my-cluster")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if project != "my-project" || location != "us-central1-a" || name != "my-cluster" {
+		t.Errorf("unexpected clone source %q, %q, %q", project, location, name)
+	}
+	if clusterLocationFlag(location) != "--zone=us-central1-a" || clusterLocationFlag("us-central1") != "--region=us-central1" {
+		t.Errorf("unexpected location flags %q and %q", clusterLocationFlag(location), clusterLocationFlag("us-central1"))
+	}
+	if _, _, _, err := parseCloneSource("my-project/my-cluster"); err == nil {
+		t.Error("expected error for a clone source without location")
+	}
+}
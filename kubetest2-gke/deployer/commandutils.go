@@ -17,18 +17,24 @@ limitations under the License.
 package deployer
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"gopkg.in/yaml.v2"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/kubetest2/pkg/credentials"
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
-func (d *deployer) prepareGcpIfNeeded(projectID string) error {
+// prepareGcpIfNeeded configures gcloud for the environment and the
+// credentials of the run, with the first project as its default project, and
+// prepares all the projects of the run.
+func (d *deployer) prepareGcpIfNeeded() error {
 	// TODO(RonWeber): This is an almost direct copy/paste from kubetest's prepareGcp()
 	// It badly needs refactored.
 
@@ -54,15 +60,26 @@ func (d *deployer) prepareGcpIfNeeded(projectID string) error {
 	if err := os.Setenv("CLOUDSDK_API_ENDPOINT_OVERRIDES_CONTAINER", endpoint); err != nil {
 		return err
 	}
+	if err := d.setPrivateGoogleAPIsEnv(); err != nil {
+		return err
+	}
 
+	projectID := d.projects[0]
 	if err := runWithOutput(exec.RawCommand("gcloud config set project " + projectID)); err != nil {
 		return fmt.Errorf("failed to set project %s: %w", projectID, err)
 	}
 
-	// gcloud creds may have changed
-	if err := activateServiceAccount(d.gcpServiceAccount); err != nil {
+	// No key needs to be activated in a pod with Workload Identity.
+	inCluster, err := d.useInClusterCredentials()
+	if err != nil {
 		return err
 	}
+	// gcloud creds may have changed
+	if !inCluster {
+		if err := activateServiceAccount(types.Context(d.commonOptions), d.gcpServiceAccount); err != nil {
+			return err
+		}
+	}
 
 	if !d.gcpSSHKeyIgnored {
 		// Ensure ssh keys exist
@@ -78,27 +95,72 @@ func (d *deployer) prepareGcpIfNeeded(projectID string) error {
 	}
 
 	//TODO(RonWeber): kubemark
-	return nil
+	return d.prepareProjects()
 }
 
 // Activate service account if set or do nothing.
-func activateServiceAccount(path string) error {
-	if path == "" {
-		return nil
+func activateServiceAccount(ctx context.Context, key string) error {
+	path, err := credentials.Path(ctx, key)
+	if err != nil || path == "" {
+		return err
+	}
+	return runWithOutput(exec.Command("gcloud", "auth", "activate-service-account", "--key-file="+path))
+}
+
+// gcloudJSON runs the gcloud command with --format=json and decodes its
+// output into v. The errors of the command are returned as is, so they can
+// be formatted with execError.
+func gcloudJSON(v interface{}, args ...string) error {
+	out, err := exec.Output(exec.Command("gcloud", append(args, "--format=json")...))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out, v); err != nil {
+		return fmt.Errorf("error parsing the output of gcloud %s: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// gkeCluster is the part of a GKE cluster resource read by the deployer.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/reference/rest/v1/projects.locations.clusters
+type gkeCluster struct {
+	Name                 string   `json:"name"`
+	Location             string   `json:"location"`
+	Status               string   `json:"status"`
+	Endpoint             string   `json:"endpoint"`
+	CurrentMasterVersion string   `json:"currentMasterVersion"`
+	CurrentNodeVersion   string   `json:"currentNodeVersion"`
+	InstanceGroupUrls    []string `json:"instanceGroupUrls"`
+	MasterAuth           struct {
+		ClusterCaCertificate string `json:"clusterCaCertificate"`
+	} `json:"masterAuth"`
+	PrivateClusterConfig struct {
+		MasterIpv4CidrBlock string `json:"masterIpv4CidrBlock"`
+		PrivateEndpoint     string `json:"privateEndpoint"`
+	} `json:"privateClusterConfig"`
+}
+
+// describeCluster describes the GKE cluster.
+func describeCluster(project, loc, name string) (*gkeCluster, error) {
+	var c gkeCluster
+	if err := gcloudJSON(&c, containerArgs("clusters", "describe", name, "--project="+project, loc)...); err != nil {
+		return nil, err
 	}
-	return runWithOutput(exec.RawCommand("gcloud auth activate-service-account --key-file=" + path))
+	return &c, nil
 }
 
 // Get the project number for the given project ID.
 func getProjectNumber(projectID string) (string, error) {
-	// Get the service project number.
-	projectNum, err := exec.Output(exec.RawCommand(
-		fmt.Sprintf("gcloud projects describe %s --format=value(projectNumber)", projectID)))
-	if err != nil {
+	var project struct {
+		ProjectNumber string `json:"projectNumber"`
+	}
+	if err := gcloudJSON(&project, "projects", "describe", projectID); err != nil {
 		return "", err
 	}
-
-	return strings.TrimSpace(string(projectNum)), nil
+	if project.ProjectNumber == "" {
+		return "", fmt.Errorf("project %s has no project number", projectID)
+	}
+	return project.ProjectNumber, nil
 }
 
 // home returns $HOME/part/part/part
@@ -107,11 +169,24 @@ func home(parts ...string) string {
 	return filepath.Join(p...)
 }
 
-func getClusterCredentials(project, loc, cluster string) error {
+// getClusterCredentialsInto is like getClusterCredentials, but writes the
+// credentials to the given kubeconfig instead of $KUBECONFIG, so it can be
+// called concurrently.
+func getClusterCredentialsInto(kubeconfig, project, loc, cluster string, extraArgs ...string) error {
+	args := append([]string{"clusters", "get-credentials", cluster, "--project=" + project, loc}, extraArgs...)
+	cmd := exec.Command("gcloud", containerArgs(args...)...)
+	cmd.SetEnv(append(os.Environ(), "KUBECONFIG="+kubeconfig)...)
+	if err := runWithOutput(cmd); err != nil {
+		return fmt.Errorf("error executing get-credentials: %v", err)
+	}
+
+	return nil
+}
+
+func getClusterCredentials(project, loc, cluster string, extraArgs ...string) error {
 	// Get gcloud to create the file.
-	if err := runWithOutput(exec.Command("gcloud",
-		containerArgs("clusters", "get-credentials", cluster, "--project="+project, loc)...),
-	); err != nil {
+	args := append([]string{"clusters", "get-credentials", cluster, "--project=" + project, loc}, extraArgs...)
+	if err := runWithOutput(exec.Command("gcloud", containerArgs(args...)...)); err != nil {
 		return fmt.Errorf("error executing get-credentials: %v", err)
 	}
 
@@ -121,25 +196,17 @@ func getClusterCredentials(project, loc, cluster string) error {
 // Resolve the current latest version in the given release channel.
 func resolveLatestVersionInChannel(loc, channelName string) (string, error) {
 	// Get the server config for the current location.
-	out, err := exec.Output(exec.RawCommand(
-		fmt.Sprintf("gcloud container get-server-config --format=\"yaml(channels:format='yaml(channel,validVersions)')\" %s", loc)))
-	if err != nil {
-		return "", fmt.Errorf("failed to get the server config: %w", err)
+	var config struct {
+		Channels []struct {
+			Name          string   `json:"channel"`
+			ValidVersions []string `json:"validVersions"`
+		} `json:"channels"`
 	}
-
-	type Channel struct {
-		Name          string   `yaml:"channel"`
-		ValidVersions []string `yaml:"validVersions"`
-	}
-	type Channels struct {
-		Channels []Channel `yaml:"channels"`
-	}
-	var cs Channels
-	if err = yaml.Unmarshal(out, &cs); err != nil {
-		return "", fmt.Errorf("failed to unmarshal the server config: %w", err)
+	if err := gcloudJSON(&config, "container", "get-server-config", loc); err != nil {
+		return "", fmt.Errorf("failed to get the server config: %w", err)
 	}
 
-	for _, channel := range cs.Channels {
+	for _, channel := range config.Channels {
 		if strings.EqualFold(channel.Name, channelName) {
 			if len(channel.ValidVersions) == 0 {
 				return "", fmt.Errorf("no valid versions for channel %q", channelName)
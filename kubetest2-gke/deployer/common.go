@@ -17,8 +17,13 @@ limitations under the License.
 package deployer
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
 	realexec "os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -27,6 +32,7 @@ import (
 
 	"sigs.k8s.io/kubetest2/pkg/boskos"
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
 const (
@@ -34,6 +40,8 @@ const (
 	defaultGKEProjectResourceType = "gke-project"
 )
 
+var invalidResourceNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
 func (d *deployer) init() error {
 	var err error
 	d.doInit.Do(func() { err = d.initialize() })
@@ -42,6 +50,10 @@ func (d *deployer) init() error {
 
 // initialize should only be called by init(), behind a sync.Once
 func (d *deployer) initialize() error {
+	if d.gcloudQPS > 0 || d.gcloudProjectQPS > 0 {
+		exec.DefaultCmder = newRateLimitedCmder(types.Context(d.commonOptions), exec.DefaultCmder, d.gcloudQPS, d.gcloudProjectQPS)
+	}
+
 	if d.commonOptions.ShouldUp() {
 		if err := d.verifyUpFlags(); err != nil {
 			return fmt.Errorf("init failed to verify flags for up: %w", err)
@@ -50,7 +62,7 @@ func (d *deployer) initialize() error {
 		if len(d.projects) == 0 {
 			klog.V(1).Infof("No GCP projects provided, acquiring from Boskos %d project/s", d.boskosProjectsRequested)
 
-			boskosClient, err := boskos.NewClient(d.boskosLocation)
+			boskosClient, err := boskos.NewClient(types.Context(d.commonOptions), d.boskosLocation, d.boskosUsername, d.boskosPassword)
 			if err != nil {
 				return fmt.Errorf("failed to make boskos client: %w", err)
 			}
@@ -72,20 +84,8 @@ func (d *deployer) initialize() error {
 			}
 		}
 
-		// Multi-cluster name adjustment
-		numProjects := len(d.projects)
-		d.projectClustersLayout = make(map[string][]cluster, numProjects)
-		if numProjects > 1 {
-			if err := buildProjectClustersLayout(d.projects, d.clusters, d.projectClustersLayout); err != nil {
-				return fmt.Errorf("failed to build the project clusters layout: %v", err)
-			}
-		} else {
-			// Backwards compatible construction
-			clusters := make([]cluster, len(d.clusters))
-			for i, clusterName := range d.clusters {
-				clusters[i] = cluster{i, clusterName}
-			}
-			d.projectClustersLayout[d.projects[0]] = clusters
+		if err := d.buildClustersLayout(); err != nil {
+			return err
 		}
 	}
 
@@ -93,11 +93,37 @@ func (d *deployer) initialize() error {
 		if err := d.verifyDownFlags(); err != nil {
 			return fmt.Errorf("init failed to verify flags for down: %w", err)
 		}
+		// The layout is only built by up if it's run in the same invocation.
+		if d.projectClustersLayout == nil {
+			if err := d.buildClustersLayout(); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// buildClustersLayout builds the project clusters layout of the deployer.
+func (d *deployer) buildClustersLayout() error {
+	// Multi-cluster name adjustment
+	numProjects := len(d.projects)
+	d.projectClustersLayout = make(map[string][]cluster, numProjects)
+	if numProjects > 1 {
+		if err := buildProjectClustersLayout(d.projects, d.clusters, d.projectClustersLayout); err != nil {
+			return fmt.Errorf("failed to build the project clusters layout: %v", err)
+		}
+	} else {
+		// Backwards compatible construction
+		clusters := make([]cluster, len(d.clusters))
+		for i, clusterName := range d.clusters {
+			clusters[i] = cluster{i, clusterName}
+		}
+		d.projectClustersLayout[d.projects[0]] = clusters
+	}
+	return nil
+}
+
 // buildProjectClustersLayout builds the projects and real cluster names mapping based on the provided --cluster-name flag.
 func buildProjectClustersLayout(projects, clusters []string, projectClustersLayout map[string][]cluster) error {
 	for i, clusterName := range clusters {
@@ -117,10 +143,87 @@ func buildProjectClustersLayout(projects, clusters []string, projectClustersLayo
 	return nil
 }
 
+// clusterNamesFromLayout generates the cluster names for the --layout flag, in
+// the name:projectIndex format of --cluster-name for the multi-project profile.
+// Each layout entry is project:count, where project is either one of the
+// projects or the index of a project in the list of projects.
+func clusterNamesFromLayout(layout, projects []string, numProjects int, runID string) ([]string, error) {
+	var projectIndices []int
+	numClusters := 0
+	counts := map[int]int{}
+	for _, entry := range layout {
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("layout entry does not follow expected format (project:count): %s", entry)
+		}
+		project, countStr := entry[:i], entry[i+1:]
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("layout entry does not contain a valid cluster count (project:count. E.g: my-project:2): %s", entry)
+		}
+		projectIndex := -1
+		for j, p := range projects {
+			if p == project {
+				projectIndex = j
+			}
+		}
+		if projectIndex < 0 {
+			if projectIndex, err = strconv.Atoi(project); err != nil || projectIndex < 0 || projectIndex >= numProjects {
+				return nil, fmt.Errorf("layout entry should refer to one of the %d projects by name or index: %s", numProjects, entry)
+			}
+		}
+		if _, ok := counts[projectIndex]; ok {
+			return nil, fmt.Errorf("project %s is specified more than once in the layout", project)
+		}
+		counts[projectIndex] = count
+		projectIndices = append(projectIndices, projectIndex)
+		numClusters += count
+	}
+
+	names := generateClusterNames(numClusters, runID)
+	if numProjects <= 1 {
+		return names, nil
+	}
+	n := 0
+	for _, projectIndex := range projectIndices {
+		for i := 0; i < counts[projectIndex]; i++ {
+			names[n] = fmt.Sprintf("%s:%d", names[n], projectIndex)
+			n++
+		}
+	}
+	return names, nil
+}
+
+// runResourceName returns the name of a GCP resource created for this run,
+// in the format of kt2-<run-id>-<suffix>.
+// The name starts with a letter, only contains lowercase letters, numbers and
+// hyphens, and is at most 63 characters if the suffix is at most 25 characters,
+// which is valid for most GCP resources.
+func runResourceName(runID, suffix string) string {
+	id := invalidResourceNameChars.ReplaceAllString(strings.ToLower(runID), "-")
+	const maxIDLength = 33
+	if len(id) > maxIDLength {
+		id = id[:maxIDLength]
+	}
+	return strings.Trim("kt2-"+id, "-") + "-" + invalidResourceNameChars.ReplaceAllString(strings.ToLower(suffix), "-")
+}
+
 func containerArgs(args ...string) []string {
 	return append(append([]string{}, "container"), args...)
 }
 
+// secretOutput returns the output of a command printing a secret. Unlike
+// the default Cmder, it does not tee the output into the artifacts. It is a
+// variable so that it can be faked in the tests.
+var secretOutput = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.Output((&exec.LocalCmder{}).CommandContext(ctx, name, args...))
+}
+
+// kubectlCommand returns a kubectl command run against the given kubeconfig.
+func kubectlCommand(kubeconfig string, args ...string) exec.Cmd {
+	return exec.Command("kubectl", append([]string{"--kubeconfig=" + kubeconfig}, args...)...)
+}
+
 func runWithNoOutput(cmd exec.Cmd) error {
 	exec.NoOutput(cmd)
 	return cmd.Run()
@@ -131,6 +234,27 @@ func runWithOutput(cmd exec.Cmd) error {
 	return cmd.Run()
 }
 
+// runWithCapturedStderr is like runWithOutput, but also returns the stderr
+// of the command, e.g. to diagnose its failure.
+func runWithCapturedStderr(cmd exec.Cmd) (string, error) {
+	var stderr bytes.Buffer
+	cmd.SetStdout(os.Stdout)
+	cmd.SetStderr(io.MultiWriter(os.Stderr, &stderr))
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+// startClusterCreation runs the cluster creation command with --async, and
+// returns the name of the creation operation and the stderr of the command.
+func startClusterCreation(ctx context.Context, args []string) (operation, stderr string, err error) {
+	var stdout, stderrBuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gcloud", append(args, "--async", "--format=value(name)")...)
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(io.MultiWriter(os.Stderr, &stderrBuf))
+	err = cmd.Run()
+	return strings.TrimSpace(stdout.String()), stderrBuf.String(), err
+}
+
 // execError returns a string format of err including stderr if the
 // err is an ExitError, useful for errors from e.g. exec.Cmd.Output().
 func execError(err error) string {
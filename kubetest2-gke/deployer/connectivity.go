@@ -0,0 +1,230 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+const (
+	connectivityName = "kubetest2-connectivity"
+	connectivityPort = "8080"
+	// connectivityWaitTimeout is how long the IPs of the echo server are
+	// waited for
+	connectivityWaitTimeout = 5 * time.Minute
+)
+
+// connectivityPollInterval is how often the IPs of the echo server are polled
+var connectivityPollInterval = 5 * time.Second
+
+// The echo server is exposed with an internal passthrough load balancer,
+// which is reachable from the other clusters in the network, unlike the
+// cluster IPs. Global access makes it reachable from the clusters in the
+// other regions too.
+const connectivityManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: ` + connectivityName + `
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+  namespace: ` + connectivityName + `
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: echo
+  template:
+    metadata:
+      labels:
+        app: echo
+    spec:
+      containers:
+      - name: echo
+        image: registry.k8s.io/e2e-test-images/agnhost:2.39
+        args: ["netexec", "--http-port=` + connectivityPort + `"]
+        ports:
+        - containerPort: ` + connectivityPort + `
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+  namespace: ` + connectivityName + `
+  annotations:
+    networking.gke.io/load-balancer-type: Internal
+    networking.gke.io/internal-load-balancer-allow-global-access: "true"
+spec:
+  type: LoadBalancer
+  selector:
+    app: echo
+  ports:
+  - port: ` + connectivityPort + `
+    targetPort: ` + connectivityPort + `
+`
+
+// connectivityEndpoints are the addresses of the echo server of a cluster.
+type connectivityEndpoints struct {
+	podIP string
+	lbIP  string
+}
+
+// verifyCrossClusterConnectivity deploys an echo server in each cluster, and
+// checks that each cluster reaches the pods and the services of the others
+// over the shared network, so a broken multi-cluster setup fails Up()
+// instead of the tests.
+func (d *deployer) verifyCrossClusterConnectivity() error {
+	if !d.verifyConnectivity || len(d.clusters) < 2 {
+		return nil
+	}
+
+	// The deletion is waited for, as the forwarding rules of the load
+	// balancers would otherwise outlive the namespace and block the deletion
+	// of the network in Down().
+	defer func() {
+		if err := d.forEachCluster(func(project string, cluster cluster) error {
+			return runWithOutput(kubectlCommand(d.clusterKubeconfig(project, cluster.name),
+				"delete", "namespace", connectivityName, "--ignore-not-found", "--wait=true", "--timeout=10m"))
+		}); err != nil {
+			klog.Warningf("Failed to delete the cross-cluster connectivity check: %v", err)
+		}
+	}()
+
+	ctx := types.Context(d.commonOptions)
+	endpoints := map[string]connectivityEndpoints{}
+	var lock sync.Mutex
+	if err := d.forEachCluster(func(project string, cluster cluster) error {
+		kubeconfig := d.clusterKubeconfig(project, cluster.name)
+		apply := kubectlCommand(kubeconfig, "apply", "-f", "-")
+		apply.SetStdin(bytes.NewReader([]byte(connectivityManifest)))
+		if err := runWithOutput(apply); err != nil {
+			return fmt.Errorf("error deploying the echo server: %w", err)
+		}
+		if err := runWithOutput(kubectlCommand(kubeconfig, "rollout", "status", "deployment/echo",
+			"--namespace="+connectivityName, "--timeout=5m")); err != nil {
+			return fmt.Errorf("error waiting for the echo server: %w", err)
+		}
+		podIP, err := waitForJSONPath(ctx, kubeconfig, "pods", "{.items[0].status.podIP}")
+		if err != nil {
+			return fmt.Errorf("error getting the IP of the echo pod: %w", err)
+		}
+		lbIP, err := waitForJSONPath(ctx, kubeconfig, "service/echo", "{.status.loadBalancer.ingress[0].ip}")
+		if err != nil {
+			return fmt.Errorf("error getting the IP of the echo service: %w", err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		endpoints[cluster.name] = connectivityEndpoints{podIP: podIP, lbIP: lbIP}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("error setting up the cross-cluster connectivity check: %w", err)
+	}
+
+	var failures []string
+	var failuresLock sync.Mutex
+	if err := d.forEachCluster(func(project string, cluster cluster) error {
+		kubeconfig := d.clusterKubeconfig(project, cluster.name)
+		for target, e := range endpoints {
+			if target == cluster.name {
+				continue
+			}
+			for _, check := range []struct{ kind, ip string }{{"pod", e.podIP}, {"service", e.lbIP}} {
+				kind, addr := check.kind, check.ip+":"+connectivityPort
+				klog.V(1).Infof("Checking that cluster %s reaches the %s of cluster %s at %s", cluster.name, kind, target, addr)
+				out, err := exec.CombinedOutputLines(kubectlCommand(kubeconfig, "exec", "deployment/echo",
+					"--namespace="+connectivityName, "--", "/agnhost", "connect", addr, "--timeout=10s"))
+				if err != nil {
+					failuresLock.Lock()
+					failures = append(failures, fmt.Sprintf("cluster %s cannot reach the %s of cluster %s at %s: %v %s",
+						cluster.name, kind, target, addr, err, strings.Join(out, " ")))
+					failuresLock.Unlock()
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		klog.V(0).Infof("All the %d clusters reach each other", len(d.clusters))
+		return nil
+	}
+	return metadata.NewJUnitError(
+		fmt.Errorf("cross-cluster connectivity check failed: %s", strings.Join(failures, "; ")),
+		d.connectivityDiagnostics())
+}
+
+// waitForJSONPath waits for the field of the resource in the connectivity
+// check namespace to be populated, and returns it.
+func waitForJSONPath(ctx context.Context, kubeconfig, resource, jsonPath string) (string, error) {
+	var value string
+	var lastErr error
+	err := poll(ctx, connectivityPollInterval, connectivityWaitTimeout, func() (bool, error) {
+		out, err := exec.Output(kubectlCommand(kubeconfig, "get", resource,
+			"--namespace="+connectivityName, "--output=jsonpath="+jsonPath))
+		lastErr = err
+		value = strings.TrimSpace(string(out))
+		return err == nil && value != "", nil
+	})
+	if err == nil {
+		return value, nil
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("%s", execError(lastErr))
+	}
+	return "", fmt.Errorf("error waiting for %s of %s: %w", jsonPath, resource, err)
+}
+
+// connectivityDiagnostics returns the firewall rules of the network and the
+// state of the echo servers, to debug the cross-cluster connectivity.
+func (d *deployer) connectivityDiagnostics() string {
+	var diagnostics []string
+	out, err := exec.CombinedOutputLines(exec.Command("gcloud", "compute", "firewall-rules", "list",
+		"--project="+d.projects[0],
+		"--filter=network:"+d.network,
+		"--format=table(name,direction,sourceRanges.list(),allowed[].map().firewall_rule().list(),targetTags.list())"))
+	if err != nil {
+		diagnostics = append(diagnostics, fmt.Sprintf("error listing the firewall rules of network %s: %v", d.network, err))
+	}
+	diagnostics = append(diagnostics, fmt.Sprintf("Firewall rules of network %s:", d.network))
+	diagnostics = append(diagnostics, out...)
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			out, err := exec.CombinedOutputLines(kubectlCommand(d.clusterKubeconfig(project, cluster.name),
+				"get", "pods,services", "--namespace="+connectivityName, "--output=wide"))
+			if err != nil {
+				diagnostics = append(diagnostics, fmt.Sprintf("error getting the echo server of cluster %s: %v", cluster.name, err))
+			}
+			diagnostics = append(diagnostics, fmt.Sprintf("Echo server of cluster %s:", cluster.name))
+			diagnostics = append(diagnostics, out...)
+		}
+	}
+	return strings.Join(diagnostics, "\n")
+}
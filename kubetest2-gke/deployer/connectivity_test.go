@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestConnectivityManifest(t *testing.T) {
+	var service struct {
+		Metadata struct {
+			Annotations map[string]string `yaml:"annotations"`
+		} `yaml:"metadata"`
+	}
+	found := false
+	for _, doc := range strings.Split(connectivityManifest, "\n---\n") {
+		var object struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &object); err != nil {
+			t.Fatalf("failed to parse the manifest: %v", err)
+		}
+		if object.Kind == "Service" {
+			found = true
+			if err := yaml.Unmarshal([]byte(doc), &service); err != nil {
+				t.Fatalf("failed to parse the service: %v", err)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("expected a service in the manifest")
+	}
+	for key, value := range map[string]string{
+		"networking.gke.io/load-balancer-type":                         "Internal",
+		"networking.gke.io/internal-load-balancer-allow-global-access": "true",
+	} {
+		if actual := service.Metadata.Annotations[key]; actual != value {
+			t.Errorf("expected the annotation %s=%s on the service, got %q", key, value, actual)
+		}
+	}
+}
+
+func TestVerifyCrossClusterConnectivity(t *testing.T) {
+	testCases := []struct {
+		name        string
+		unreachable string
+		expectError bool
+	}{
+		{
+			name: "all the clusters reach each other",
+		},
+		{
+			name:        "a service is unreachable",
+			unreachable: "10.1.0.2:" + connectivityPort,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, restore := useFakeCmder(func(args []string) (string, error) {
+				command := strings.Join(args, " ")
+				// each cluster has its own kubeconfig
+				n := "1"
+				if strings.Contains(command, "cluster-b") {
+					n = "2"
+				}
+				switch {
+				case strings.Contains(command, " get pods "):
+					return "10.0.0." + n, nil
+				case strings.Contains(command, " get service/echo "):
+					return "10.1.0." + n, nil
+				case tc.unreachable != "" && strings.Contains(command, " connect "+tc.unreachable+" "):
+					return "", errors.New("exit status 1")
+				}
+				return "", nil
+			})
+			defer restore()
+
+			d := &deployer{
+				verifyConnectivity: true,
+				clusters:           []string{"cluster-a", "cluster-b"},
+				projects:           []string{"project"},
+				projectClustersLayout: map[string][]cluster{
+					"project": {{index: 0, name: "cluster-a"}, {index: 1, name: "cluster-b"}},
+				},
+				kubecfgDir: "/kubeconfigs",
+				network:    "network",
+			}
+			err := d.verifyCrossClusterConnectivity()
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, got: %v", tc.expectError, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "cluster cluster-a cannot reach the service of cluster cluster-b") {
+				t.Errorf("expected the unreachable service in the error, got %v", err)
+			}
+
+			deleted := 0
+			for _, command := range f.ran() {
+				if strings.Contains(command, " delete namespace "+connectivityName) {
+					deleted++
+					if !strings.Contains(command, "--wait=true") {
+						t.Errorf("expected the deletion of the namespace to be waited for, got %q", command)
+					}
+				}
+			}
+			if deleted != 2 {
+				t.Errorf("expected the namespace to be deleted from the 2 clusters, got %d", deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/credentials"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// verifyCredentialsFlags validates the secret references of the credentials
+// flags, so that a typo does not only surface once the clusters are up.
+func (d *deployer) verifyCredentialsFlags() error {
+	if (d.boskosUsername == "") != (d.boskosPassword == "") {
+		return fmt.Errorf("--boskos-username and --boskos-password must be set together")
+	}
+	for _, f := range []struct {
+		name string
+		ref  string
+	}{
+		{name: "--gcp-service-account", ref: d.gcpServiceAccount},
+		{name: "--boskos-password", ref: d.boskosPassword},
+		{name: "--registry-mirror-password-file", ref: d.registryMirrorPasswordFile},
+		{name: "--registry-secret-key-file", ref: d.pullSecretKeyFile},
+	} {
+		if _, err := credentials.New(f.ref); err != nil {
+			return fmt.Errorf("invalid %s: %w", f.name, err)
+		}
+	}
+	// gcloud auth activate-service-account only takes service account keys,
+	// while the application default credentials may be those of a user, as
+	// written by gcloud auth application-default login.
+	if d.gcpServiceAccount == "adc" {
+		key, err := credentials.Read(types.Context(d.commonOptions), d.gcpServiceAccount)
+		if err != nil {
+			return fmt.Errorf("invalid --gcp-service-account: %w", err)
+		}
+		var adc struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(key), &adc); err != nil {
+			return fmt.Errorf("invalid --gcp-service-account: error parsing the application default credentials: %w", err)
+		}
+		if adc.Type != "service_account" {
+			return fmt.Errorf("--gcp-service-account=adc requires the application default credentials to be a service account key, found %q credentials", adc.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyCredentialsFlags(t *testing.T) {
+	testCases := []struct {
+		name        string
+		deployer    *deployer
+		expectError bool
+	}{
+		{
+			name:     "no credentials",
+			deployer: &deployer{},
+		},
+		{
+			name: "valid references",
+			deployer: &deployer{
+				gcpServiceAccount:          "/etc/service-account/key.json",
+				boskosUsername:             "kubetest2",
+				boskosPassword:             "env:BOSKOS_PASSWORD",
+				registryMirrorPasswordFile: "vault:secret/ci/mirror#password",
+				pullSecretKeyFile:          "gcp-secret-manager:projects/ci/secrets/registry-key",
+			},
+		},
+		{
+			name:        "boskos username without password",
+			deployer:    &deployer{boskosUsername: "kubetest2"},
+			expectError: true,
+		},
+		{
+			name:        "invalid vault reference",
+			deployer:    &deployer{gcpServiceAccount: "vault:secret/ci/gcp"},
+			expectError: true,
+		},
+		{
+			name:        "invalid secret manager reference",
+			deployer:    &deployer{pullSecretKeyFile: "gcp-secret-manager:registry-key"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			err := tc.deployer.verifyCredentialsFlags()
+			if tc.expectError && err == nil {
+				st.Error("expected an error")
+			}
+			if !tc.expectError && err != nil {
+				st.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyCredentialsFlagsADC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+
+	d := &deployer{gcpServiceAccount: "adc"}
+	for adc, expectError := range map[string]bool{
+		`{"type": "service_account", "client_email": "sa@project.iam.gserviceaccount.com"}`: false,
+		`{"type": "authorized_user", "refresh_token": "token"}`:                             true,
+		`not json`: true,
+	} {
+		path := filepath.Join(dir, "adc.json")
+		if err := ioutil.WriteFile(path, []byte(adc), 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+		if err := d.verifyCredentialsFlags(); (err != nil) != expectError {
+			t.Errorf("unexpected error for the application default credentials %s: %v", adc, err)
+		}
+	}
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(dir, "missing.json"))
+	if err := d.verifyCredentialsFlags(); err == nil {
+		t.Error("expected an error for missing application default credentials")
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/octago/sflags/gen/gpflag"
 	"github.com/spf13/pflag"
@@ -32,6 +33,8 @@ import (
 
 	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
 	"sigs.k8s.io/kubetest2/pkg/build"
+	"sigs.k8s.io/kubetest2/pkg/credentials"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
@@ -109,24 +112,137 @@ type deployer struct {
 	nodes                 int
 	machineType           string
 	imageType             string
+	windowsNodes          int
+	windowsMachineType    string
+	windowsImageType      string
+	nodeSystemConfig      string
 	network               string
 	subnetworkRanges      []string
 	environment           string
 	gcpServiceAccount     string
 
+	// pod and service IP ranges of the clusters
+	clusterIPv4CIDR            string
+	servicesIPv4CIDR           string
+	clusterSecondaryRangeName  string
+	servicesSecondaryRangeName string
+
 	gcloudCommandGroup string
 	autopilot          bool
 	gcloudExtraFlags   string
 	createCommandFlag  string
 
+	// default compute class and machine family for GKE Autopilot workloads
+	autopilotComputeClass  string
+	autopilotMachineFamily string
+	// number of nodes to pre-scale GKE Autopilot clusters to before testing
+	autopilotWarmupNodes   int
+	autopilotWarmupCPU     string
+	autopilotWarmupMemory  string
+	autopilotWarmupTimeout time.Duration
+
 	kubecfgPath  string
+	kubecfgDir   string
 	testPrepared bool
+	// whether all the projects of the run were prepared
+	projectsPrepared bool
+	// how the kubeconfig authenticates to the clusters
+	kubeconfigAuth string
+	// number of clusters set up for the tests concurrently
+	setupConcurrency int
 	// project -> cluster -> instance groups
 	instanceGroups map[string]map[string][]*ig
 
 	localLogsDir string
 	gcsLogsDir   string
 
+	// node log collection settings of DumpClusterLogs
+	nodeLogSSHUser      string
+	nodeLogSSHKey       string
+	nodeLogFiles        []string
+	nodeLogSystemdUnits []string
+	nodeLogMaxNodes     int
+
+	// registry mirrors configured for containerd on the cluster nodes
+	registryMirrors              []string
+	registryMirrorUsername       string
+	registryMirrorPasswordFile   string
+	registryMirrorInstallerImage string
+	registryHostsDir             string
+	// upstream registries mirrored by per-run Artifact Registry remote repositories
+	artifactRegistryMirrors []string
+
+	// imagePullSecret created in the clusters for private registries
+	pullSecretServer     string
+	pullSecretName       string
+	pullSecretKeyFile    string
+	pullSecretNamespaces []string
+
+	// number of clusters to create in each project, e.g. projA:2,projB:1
+	layout []string
+
+	// existing cluster whose settings are replayed into the new clusters
+	cloneFromCluster string
+	clonedAddons     []string
+	clonedNodePools  []clonedNodePool
+
+	// size the IP ranges, check the quotas and create the nodes in batches
+	// for large clusters, see scale.go
+	scale bool
+
+	// feature gates and APIs of the control plane, see featuregates.go
+	featureGates  string
+	runtimeConfig string
+
+	// check that the clusters reach each other after they are created
+	verifyConnectivity bool
+
+	// print the plan and prompt for confirmation before creating and
+	// deleting the clusters
+	interactive bool
+	upDeclined  bool
+
+	// machine types tried in order if --machine-type is out of capacity
+	machineTypeFallbacks []string
+
+	// image types of the default node pool, one cluster is created per image type
+	imageTypes []string
+
+	// GCE reservations consumed by the default node pool
+	reservationAffinity string
+	reservation         string
+
+	// firewall rule sets opened on the cluster nodes for the tests
+	firewallRuleSets []string
+
+	// DNS configuration of the clusters
+	// See the details in https://cloud.google.com/kubernetes-engine/docs/how-to/cloud-dns
+	clusterDNS        string
+	clusterDNSScope   string
+	clusterDNSDomain  string
+	nodeLocalDNSCache bool
+
+	// Backup for GKE backups taken before the tests
+	backupBeforeTest bool
+	backupNamespaces []string
+
+	// timeout of each cleanup operation, e.g. deleting a cluster
+	cleanupTimeout time.Duration
+
+	// what down tears down, the clusters or only the resources of the run in them
+	downMode          string
+	downLabelSelector string
+
+	// client-side rate limits of the gcloud commands, 0 means unlimited
+	gcloudQPS        float64
+	gcloudProjectQPS float64
+
+	// use the credentials of the pod if running in a GKE pod with Workload
+	// Identity, see incluster.go
+	inClusterCredentials bool
+	inClusterChecked     bool
+	inClusterAccount     string
+
 	// whether the GCP SSH key is required or not
 	gcpSSHKeyIgnored bool
 
@@ -138,8 +254,17 @@ type deployer struct {
 	// See the details in https://cloud.google.com/kubernetes-engine/docs/how-to/private-clusters
 	privateClusterAccessLevel    string
 	privateClusterMasterIPRanges []string
+	// supernet to allocate the private cluster master IP ranges from
+	privateClusterMasterIPSupernet string
+
+	// VPC Service Controls perimeter and the Private Service Connect endpoint
+	// for Google APIs the run operates in
+	vpcServiceControls    bool
+	pscGoogleAPIsEndpoint string
 
 	boskosLocation              string
+	boskosUsername              string
+	boskosPassword              string
 	boskosResourceType          string
 	boskosAcquireTimeoutSeconds int
 	// number of boskos projects to request if `projects` is empty
@@ -152,6 +277,18 @@ type deployer struct {
 	// this channel serves as a signal channel for the hearbeat goroutine
 	// so that it can be explicitly closed
 	boskosHeartbeatClose chan struct{}
+
+	// metadata about the provisioned clusters exposed to the tester
+	metadata *metadata.CustomJSON
+
+	// Whether to capture the GKE cluster notifications into the artifacts.
+	// See the details in https://cloud.google.com/kubernetes-engine/docs/concepts/cluster-notifications
+	clusterNotifications bool
+	// this channel serves as a signal channel for the notifications draining
+	// goroutine so that it can be explicitly closed
+	notificationsClose   chan struct{}
+	notificationsDrained sync.WaitGroup
+	notificationsLock    sync.Mutex
 }
 
 // assert that New implements types.NewDeployer
@@ -160,10 +297,20 @@ var _ types.NewDeployer = New
 // assert that deployer implements types.Deployer
 var _ types.Deployer = &deployer{}
 
+// assert that deployer implements types.DeployerWithMetadata
+var _ types.DeployerWithMetadata = &deployer{}
+
+// assert that deployer implements types.DeployerWithParallelBuild
+var _ types.DeployerWithParallelBuild = &deployer{}
+
 func (d *deployer) Provider() string {
 	return Name
 }
 
+func (d *deployer) Metadata() (*metadata.CustomJSON, error) {
+	return d.metadata, nil
+}
+
 // New implements deployer.New for gke
 func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
 	// create a deployer object and set fields that are not flag controlled
@@ -180,6 +327,7 @@ func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
 			NumClusters: 1,
 		},
 		localLogsDir: filepath.Join(opts.RunDir(), "logs"),
+		metadata:     metadata.NewCustomJSON(),
 		// Leave Version as empty to use the default cluster version.
 		Version: "",
 	}
@@ -211,6 +359,15 @@ func locationFlag(region, zone string) string {
 	return "--region=" + region
 }
 
+// clusterLocationFlag returns the location flag of a zone (e.g. us-central1-a)
+// or a region (e.g. us-central1), such as the location of a listed cluster.
+func clusterLocationFlag(location string) string {
+	if strings.Count(location, "-") == 2 {
+		return locationFlag("", location)
+	}
+	return locationFlag(location, "")
+}
+
 // regionFromLocation computes the region from the specified zone/region
 // used by some commands (such as subnets), which do not support zones.
 func regionFromLocation(region, zone string) string {
@@ -230,28 +387,123 @@ func bindFlags(d *deployer) *pflag.FlagSet {
 
 	flags.StringVar(&d.gcloudCommandGroup, "gcloud-command-group", "", "gcloud command group, can be one of empty, alpha, beta")
 	flags.BoolVar(&d.autopilot, "autopilot", false, "Whether to create GKE Autopilot clusters or not")
+	flags.StringVar(&d.autopilotComputeClass, "autopilot-compute-class", "", "The compute class workloads should use on GKE Autopilot clusters, e.g. Balanced, Scale-Out or Performance. "+
+		"It's recorded in the metadata, used as the node selector of the warm-up workload and exposed to the tester in $KUBETEST2_AUTOPILOT_NODE_SELECTOR.")
+	flags.StringVar(&d.autopilotMachineFamily, "autopilot-machine-family", "", "The machine family workloads should use on GKE Autopilot clusters, e.g. c3. Requires --autopilot-compute-class, and is applied along with it.")
+	flags.IntVar(&d.autopilotWarmupNodes, "autopilot-warmup-nodes", 0, "If larger than 0, deploy a placeholder workload to pre-scale GKE Autopilot clusters to this many nodes "+
+		"and wait for it before the tests start. The placeholder pods are preempted by the test workloads.")
+	flags.StringVar(&d.autopilotWarmupCPU, "autopilot-warmup-cpu", "500m", "CPU request of each placeholder pod of the Autopilot warm-up workload.")
+	flags.StringVar(&d.autopilotWarmupMemory, "autopilot-warmup-memory", "512Mi", "Memory request of each placeholder pod of the Autopilot warm-up workload.")
+	flags.DurationVar(&d.autopilotWarmupTimeout, "autopilot-warmup-timeout", 20*time.Minute, "How long to wait for the Autopilot warm-up workload to be ready.")
+	flags.DurationVar(&d.cleanupTimeout, "cleanup-timeout", 30*time.Minute, "How long to wait for each cleanup operation, e.g. deleting a cluster, before giving up on it.")
+	flags.StringVar(&d.downMode, "down-mode", downModeCluster, "What to tear down in the down phase, one of 'cluster', which deletes the clusters, and 'namespaces', "+
+		"which keeps the clusters and deletes the namespaces, CRDs and cluster-scoped resources created by the tests instead, so shared clusters can be reused between runs.")
+	flags.StringVar(&d.downLabelSelector, "down-label-selector", "", "Label selector of the resources deleted with --down-mode=namespaces, which requires it. "+
+		"The tests must put the labels on the resources they create.")
+	flags.Float64Var(&d.gcloudQPS, "gcloud-qps", 0, "Maximum number of gcloud commands started per second across all the projects, to stay within the API quotas of large parallel runs. "+
+		"Defaults to 0, which means unlimited.")
+	flags.Float64Var(&d.gcloudProjectQPS, "gcloud-project-qps", 0, "Maximum number of gcloud commands started per second for each project. Defaults to 0, which means unlimited.")
 	flags.StringVar(&d.gcloudExtraFlags, "gcloud-extra-flags", "", "Extra gcloud flags to pass when creating the clusters")
 	flags.StringVar(&d.createCommandFlag, "create-command", "", "gcloud subcommand and additional flags used to create a cluster, such as `container clusters create --quiet`."+
 		"If it's specified, --gcloud-command-group, --autopilot, --gcloud-extra-flags will be ignored.")
 	flags.StringSliceVar(&d.clusters, "cluster-name", []string{}, "Cluster names separated by comma. Must be set. "+
 		"For multi-project profile, it should be in the format of clusterA:0,clusterB:1,clusterC:2, where the index means the index of the project.")
-	flags.StringVar(&d.gcpServiceAccount, "gcp-service-account", "", "Service account to activate before using gcloud")
+	flags.StringSliceVar(&d.layout, "layout", []string{}, "Number of clusters to create in each project, in the format of projA:2,projB:1, where the project is either one of --project "+
+		"or the index of the project. Cluster names are generated as with --num-clusters. Cannot be used with --cluster-name.")
+	flags.StringVar(&d.gcpServiceAccount, "gcp-service-account", "", "Key of the service account to activate before using gcloud. "+credentials.Usage)
+	flags.BoolVar(&d.inClusterCredentials, "in-cluster-credentials", false, "If running in a GKE pod with Workload Identity, e.g. a Prow job on GKE, and --gcp-service-account is not set, "+
+		"use the credentials of the pod for gcloud, and warn about the roles missing from its service account on the projects.")
 	flags.StringVar(&d.network, "network", "default", "Cluster network. Defaults to the default network if not provided. For multi-project use cases, this will be the Shared VPC network name.")
 	flags.StringSliceVar(&d.subnetworkRanges, "subnetwork-ranges", []string{}, "Subnetwork ranges as required for shared VPC setup as described in https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#creating_a_network_and_two_subnets."+
 		"For multi-project profile, it is required and should be in the format of `10.0.4.0/22 10.0.32.0/20 10.4.0.0/14,172.16.4.0/22 172.16.16.0/20 172.16.4.0/22`, where the subnetworks configuration for different project"+
 		"are separated by comma, and the ranges of each subnetwork configuration is separated by space.")
+	flags.StringVar(&d.clusterIPv4CIDR, "cluster-ipv4-cidr", "", "The IP address range for the pods in the clusters, in CIDR notation, e.g. 10.0.0.0/14. Defaults to the range chosen by GKE.")
+	flags.StringVar(&d.servicesIPv4CIDR, "services-ipv4-cidr", "", "The IP address range for the services in the clusters, in CIDR notation, e.g. 10.4.0.0/19. Defaults to the range chosen by GKE.")
+	flags.StringVar(&d.clusterSecondaryRangeName, "cluster-secondary-range-name", "", "The name of an existing secondary range of the subnetwork to use for the pod IPs. Cannot be used with --cluster-ipv4-cidr.")
+	flags.StringVar(&d.servicesSecondaryRangeName, "services-secondary-range-name", "", "The name of an existing secondary range of the subnetwork to use for the service IPs. Cannot be used with --services-ipv4-cidr.")
+	flags.StringSliceVar(&d.firewallRuleSets, "firewall-rule-sets", []string{"e2e"}, "Firewall rule sets opened on the cluster nodes for the tests, in a non-default network of a single project. "+
+		"Each is one of e2e, nodeports, windows, windows-smb and konnectivity, or a custom rule set in the format of name=allow with the protocols separated by semicolon, e.g. dns=tcp:53;udp:53. "+
+		"The windows rule set is always included with --windows-num-nodes.")
+	flags.IntVar(&d.setupConcurrency, "setup-concurrency", 10, "Maximum number of clusters set up for the tests concurrently, e.g. getting the credentials and creating the firewall rules.")
+	flags.StringVar(&d.kubeconfigAuth, "kubeconfig-auth", "", "How the kubeconfig passed to the tester authenticates to the clusters, one of 'gcloud' (the gcloud auth provider), "+
+		"'exec-plugin' (the gke-gcloud-auth-plugin exec plugin) and 'token' (the long-lived token of a cluster-admin service account, for testers without gcloud). "+
+		"Defaults to the auth config gcloud writes by default.")
+	flags.StringVar(&d.clusterDNS, "cluster-dns", "", "DNS provider of the clusters, one of 'clouddns' and 'kubedns'. Defaults to the provider chosen by GKE.")
+	flags.StringVar(&d.clusterDNSScope, "cluster-dns-scope", "", "Scope of the Cloud DNS records of the clusters, one of 'cluster' and 'vpc'. Requires --cluster-dns=clouddns.")
+	flags.StringVar(&d.clusterDNSDomain, "cluster-dns-domain", "", "Domain of the Cloud DNS records of the clusters, required by --cluster-dns-scope=vpc.")
+	flags.BoolVar(&d.nodeLocalDNSCache, "enable-node-local-dns-cache", false, "Whether to enable NodeLocal DNSCache in the clusters. It cannot be set for GKE Autopilot clusters, which always enable it.")
 	flags.StringVar(&d.environment, "environment", "prod", "Container API endpoint to use, one of 'test', 'staging', 'prod', or a custom https:// URL. Defaults to prod if not provided")
 	flags.StringSliceVar(&d.projects, "project", []string{}, "Comma separated list of GCP Project(s) to use for creating the cluster.")
 	flags.StringVar(&d.region, "region", "", "For use with gcloud commands to specify the cluster region.")
 	flags.StringVar(&d.zone, "zone", "", "For use with gcloud commands to specify the cluster zone.")
-	flags.IntVar(&d.nodes, "num-nodes", defaultNodePool.Nodes, "For use with gcloud commands to specify the number of nodes for the cluster.")
+	flags.IntVar(&d.nodes, "num-nodes", defaultNodePool.Nodes, "For use with gcloud commands to specify the number of nodes for the cluster. Ignored for GKE Autopilot clusters.")
 	flags.StringVar(&d.machineType, "machine-type", defaultNodePool.MachineType, "For use with gcloud commands to specify the machine type for the cluster.")
+	flags.StringVar(&d.cloneFromCluster, "clone-from-cluster", "", "Existing cluster, in the format of project/location/name, whose version, release channel, node pools and addons are replayed "+
+		"into the created clusters. The flags set to non-default values take precedence over the settings of the cloned cluster.")
+	flags.BoolVar(&d.scale, "scale", false, "Scale-test profile for large --num-nodes: check the regional quotas of the projects, size the pod and node IP ranges to fit the nodes, "+
+		"raise the cluster creation and cleanup timeouts, and grow the default node pool in batches of 100 nodes per zone. Not supported for GKE Autopilot clusters.")
+	flags.StringVar(&d.featureGates, "feature-gates", "", "Comma separated list of Gate=true feature gates to enable, e.g. Gate1=true,Gate2=true. "+
+		"GKE does not allow setting individual feature gates, so alpha clusters with all the alpha features enabled are created instead. Not supported for GKE Autopilot clusters.")
+	flags.StringVar(&d.runtimeConfig, "runtime-config", "", "Comma separated list of group/version/resource=true beta APIs to enable, "+
+		"e.g. storage.k8s.io/v1beta1/csistoragecapacities=true. Translated into --enable-kubernetes-unstable-apis.")
+	flags.BoolVar(&d.verifyConnectivity, "verify-cross-cluster-connectivity", false, "If set with multiple clusters, deploy an echo server in each cluster after they are created, "+
+		"and fail the up phase with the network diagnostics unless each cluster reaches the pods and the internal load balancer services of the others.")
+	flags.BoolVar(&d.interactive, "interactive", false, "For local use, print the clusters, locations, machine types and estimated cost before creating the clusters, "+
+		"and the clusters before deleting them, and prompt for confirmation.")
+	flags.StringSliceVar(&d.machineTypeFallbacks, "machine-type-fallbacks", []string{}, "Comma separated list of machine types to fall back to, in order, "+
+		"if the cluster creation fails because --machine-type is out of capacity (stockout) in the location. The machine type of each cluster is recorded in the metadata.")
 	flags.StringVar(&d.imageType, "image-type", defaultImage, "The image type to use for the cluster.")
+	flags.StringSliceVar(&d.imageTypes, "image-types", []string{}, "Comma separated list of image types to qualify in a single run, e.g. cos_containerd,ubuntu_containerd. "+
+		"One cluster is created per image type, with the image type recorded in the metadata and the junit properties. Cannot be used with --image-type.")
+	flags.StringVar(&d.reservationAffinity, "reservation-affinity", "", "The GCE reservations the default node pool of the clusters consumes, one of 'any', 'none' and 'specific'. "+
+		"Defaults to the GKE default if not provided.")
+	flags.StringVar(&d.reservation, "reservation", "", "The name of the GCE reservation to consume with --reservation-affinity=specific, "+
+		"or projects/PROJECT/reservations/NAME for a shared reservation.")
+	flags.IntVar(&d.windowsNodes, "windows-num-nodes", 0, "Number of nodes in the Windows node pool created in each cluster. No Windows node pool is created if it's 0.")
+	flags.StringVar(&d.windowsMachineType, "windows-machine-type", "n1-standard-4", "The machine type to use for the Windows node pool.")
+	flags.StringVar(&d.windowsImageType, "windows-image-type", defaultWindowsImageType, "The image type to use for the Windows node pool.")
+	flags.StringVar(&d.nodeSystemConfig, "node-system-config", "", "Path to a node system configuration file (kubelet config and sysctls) passed to --system-config-from-file when creating the cluster. "+
+		"See https://cloud.google.com/kubernetes-engine/docs/how-to/node-system-config for the format.")
+	flags.StringSliceVar(&d.registryMirrors, "registry-mirror", []string{}, "Registry mirrors to configure for containerd on the cluster nodes, in the format of registry=endpoint, "+
+		"e.g. docker.io=https://mirror.example.com. Can be repeated or separated by comma.")
+	flags.StringVar(&d.registryMirrorUsername, "registry-mirror-username", "_json_key", "Username used to authenticate to the registry mirrors, only used with --registry-mirror-password-file.")
+	flags.StringVar(&d.registryMirrorPasswordFile, "registry-mirror-password-file", "", "The password (e.g. a service account key) used to authenticate to the registry mirrors. "+credentials.Usage)
+	flags.StringVar(&d.registryMirrorInstallerImage, "registry-mirror-installer-image", defaultRegistryInstaller, "Image of the DaemonSet that installs the registry mirror configuration on the nodes. "+
+		"It must provide sh and be pullable without the mirrors.")
+	flags.StringVar(&d.registryHostsDir, "registry-hosts-dir", defaultRegistryHostsDir, "The containerd registry hosts directory (config_path) on the cluster nodes.")
+	flags.StringSliceVar(&d.artifactRegistryMirrors, "artifact-registry-mirror", []string{}, "Upstream registries (docker.io and/or registry.k8s.io) to mirror with an Artifact Registry remote repository "+
+		"created for this run in the host project, configured as registry mirrors on the cluster nodes and deleted on down. The node service account needs read access to Artifact Registry.")
+	flags.BoolVar(&d.clusterNotifications, "cluster-notifications", false, "Whether to publish the GKE cluster notifications (e.g. upgrade events, security bulletins) to a per-run Pub/Sub topic "+
+		"and capture them into "+notificationsFile+" in the artifacts.")
+	flags.BoolVar(&d.backupBeforeTest, "backup-before-test", false, "Whether to enable the Backup for GKE agent and take a backup of the clusters once they are up. "+
+		"The backup names are recorded in the metadata, so destructive test suites can restore the state between test groups.")
+	flags.StringSliceVar(&d.backupNamespaces, "backup-namespaces", []string{}, "Namespaces to include in the backup taken with --backup-before-test. Defaults to all the namespaces if not provided.")
+	flags.StringVar(&d.pullSecretServer, "registry-secret-server", "", "If set, create a docker-registry secret for this private registry server (e.g. us-docker.pkg.dev) "+
+		"in the --registry-secret-namespaces of every cluster and add it to the imagePullSecrets of their default service account.")
+	flags.StringVar(&d.pullSecretName, "registry-secret-name", defaultPullSecretName, "Name of the docker-registry secret created for --registry-secret-server.")
+	flags.StringVar(&d.pullSecretKeyFile, "registry-secret-key-file", "", "The service account key used for the docker-registry secret. "+credentials.Usage+" "+
+		"If not set, a short-lived access token of the active gcloud account (e.g. bound via Workload Identity) is used.")
+	flags.StringSliceVar(&d.pullSecretNamespaces, "registry-secret-namespaces", []string{"default"}, "Namespaces to create the docker-registry secret in, separated by comma. They are created if they don't exist.")
+	flags.StringVar(&d.nodeLogSSHUser, "node-log-ssh-user", "", "User to ssh into the nodes as when dumping the node logs, required by --node-log-ssh-key.")
+	flags.StringVar(&d.nodeLogSSHKey, "node-log-ssh-key", "", "Path to the private key to ssh into the nodes with when dumping the node logs. "+
+		"If not set, gcloud compute ssh is used with the default GCP ssh key.")
+	flags.StringSliceVar(&d.nodeLogFiles, "node-log-files", []string{}, "Extra files under /var/log to collect from the nodes when dumping the node logs, separated by comma.")
+	flags.StringSliceVar(&d.nodeLogSystemdUnits, "node-log-systemd-units", []string{}, "Extra systemd units to collect the journal of from the nodes when dumping the node logs, separated by comma.")
+	flags.IntVar(&d.nodeLogMaxNodes, "node-log-max-nodes", 0, "Maximum number of nodes per project to collect the logs from when dumping the node logs, "+
+		"so that large clusters do not take hours to dump. Defaults to 0, which means all the nodes.")
 	flags.BoolVar(&d.gcpSSHKeyIgnored, "ignore-gcp-ssh-key", true, "Whether the GCP SSH key should be ignored or not for bringing up the cluster.")
 	flags.BoolVar(&d.workloadIdentityEnabled, "enable-workload-identity", false, "Whether enable workload identity for the cluster or not.")
 	flags.StringVar(&d.privateClusterAccessLevel, "private-cluster-access-level", "", "Private cluster access level, if not empty, must be one of 'no', 'limited' or 'unrestricted'")
 	flags.StringSliceVar(&d.privateClusterMasterIPRanges, "private-cluster-master-ip-range", []string{"172.16.0.32/28"}, "Private cluster master IP ranges. It should be IPv4 CIDR(s), and its length must be the same as the number of clusters if private cluster is requested.")
+	flags.StringVar(&d.privateClusterMasterIPSupernet, "private-cluster-master-ip-supernet", "", "If set, allocate a non-conflicting /28 master IP range for each private cluster from this IPv4 CIDR, "+
+		"e.g. 172.16.0.0/24, instead of using --private-cluster-master-ip-range. The allocated ranges are recorded in the metadata.")
+	flags.BoolVar(&d.vpcServiceControls, "vpc-service-controls", false, "Set if the run operates inside a VPC Service Controls perimeter without public internet access. "+
+		"The clusters are reached through their internal endpoints, and the nodes through their internal IPs. Requires --private-cluster-access-level.")
+	flags.StringVar(&d.pscGoogleAPIsEndpoint, "psc-googleapis-endpoint", "", "Name of the Private Service Connect endpoint for Google APIs, if set, "+
+		"gcloud calls the Google APIs through it, e.g. https://container-NAME.p.googleapis.com/ instead of https://container.googleapis.com/")
 	flags.StringVar(&d.boskosLocation, "boskos-location", defaultBoskosLocation, "If set, manually specifies the location of the Boskos server")
+	flags.StringVar(&d.boskosUsername, "boskos-username", "", "If set, the username to authenticate to the Boskos server with, requires --boskos-password")
+	flags.StringVar(&d.boskosPassword, "boskos-password", "", "The password of --boskos-username. "+credentials.Usage)
 	flags.StringVar(&d.boskosResourceType, "boskos-resource-type", defaultGKEProjectResourceType, "If set, manually specifies the resource type of GCP projects to acquire from Boskos")
 	flags.IntVar(&d.boskosAcquireTimeoutSeconds, "boskos-acquire-timeout-seconds", 300, "How long (in seconds) to hang on a request to Boskos to acquire a resource before erroring")
 	flags.IntVar(&d.boskosProjectsRequested, "projects-requested", 1, "Number of projects to request from Boskos. It is only respected if projects is empty, and must be larger than zero ")
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+// stockoutMessages are the messages of the errors returned when the location
+// is out of capacity for the machine type.
+var stockoutMessages = []string{
+	"ZONE_RESOURCE_POOL_EXHAUSTED",
+	"STOCKOUT",
+	"does not have enough resources available to fulfill the request",
+}
+
+// isStockout returns whether the stderr of a failed cluster creation reports
+// a stockout of the machine type.
+func isStockout(stderr string) bool {
+	for _, message := range stockoutMessages {
+		if strings.Contains(stderr, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterCreationError returns the error for the failed creation of the
+// cluster, with the detail of the failed GKE operation and the recent
+// warning logs of the cluster attached, and also writes them into the
+// artifacts.
+func (d *deployer) clusterCreationError(project, loc, clusterName string, err error, stderr string) error {
+	err = fmt.Errorf("error creating cluster %s: %v", clusterName, err)
+
+	var diagnostics strings.Builder
+	fmt.Fprintf(&diagnostics, "gcloud stderr:\n%s\n", stderr)
+
+	operation, opErr := exec.Output(exec.Command("gcloud", containerArgs("operations", "list",
+		"--project="+project,
+		loc,
+		fmt.Sprintf("--filter=targetLink~/clusters/%s$ AND operationType=CREATE_CLUSTER", clusterName),
+		"--sort-by=~startTime",
+		"--limit=1",
+		"--format=yaml")...))
+	if opErr != nil {
+		klog.Warningf("Failed to get the creation operation of cluster %s: %s", clusterName, execError(opErr))
+	} else {
+		fmt.Fprintf(&diagnostics, "\nCreation operation:\n%s\n", operation)
+	}
+
+	logs, logsErr := exec.Output(exec.Command("gcloud", "logging", "read",
+		fmt.Sprintf(`resource.type=("gke_cluster" OR "k8s_cluster") AND resource.labels.cluster_name=%q AND severity>=WARNING`, clusterName),
+		"--project="+project,
+		"--freshness=1h",
+		"--limit=50",
+		"--format=value(timestamp,severity,protoPayload.status.message,jsonPayload.message,textPayload)"))
+	if logsErr != nil {
+		klog.Warningf("Failed to read the logs of cluster %s: %s", clusterName, execError(logsErr))
+	} else {
+		fmt.Fprintf(&diagnostics, "\nRecent warning logs:\n%s\n", logs)
+	}
+
+	path := filepath.Join(d.commonOptions.RunDir(), fmt.Sprintf("creation-failure-%s-%s.txt", project, clusterName))
+	if writeErr := ioutil.WriteFile(path, []byte(diagnostics.String()), 0644); writeErr != nil {
+		klog.Warningf("Failed to write the creation failure diagnostics of cluster %s: %v", clusterName, writeErr)
+	} else {
+		klog.V(0).Infof("Wrote the creation failure diagnostics of cluster %s to %s", clusterName, path)
+	}
+	return metadata.NewJUnitError(err, diagnostics.String())
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+)
+
+const (
+	nodeLocalDNSAddon = "NodeLocalDNS"
+
+	cloudDNS = "clouddns"
+	kubeDNS  = "kubedns"
+)
+
+// verifyDNSFlags validates the DNS flags for up phase.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/cloud-dns
+func (d *deployer) verifyDNSFlags() error {
+	switch d.clusterDNS {
+	case "", kubeDNS:
+		if d.clusterDNSScope != "" || d.clusterDNSDomain != "" {
+			return fmt.Errorf("--cluster-dns-scope and --cluster-dns-domain can only be used with --cluster-dns=%s", cloudDNS)
+		}
+	case cloudDNS:
+		switch d.clusterDNSScope {
+		case "", "cluster":
+			if d.clusterDNSDomain != "" {
+				return fmt.Errorf("--cluster-dns-domain can only be used with --cluster-dns-scope=vpc")
+			}
+		case "vpc":
+			if d.clusterDNSDomain == "" {
+				return fmt.Errorf("--cluster-dns-domain must be set with --cluster-dns-scope=vpc")
+			}
+		default:
+			return fmt.Errorf("--cluster-dns-scope must be one of %v", []string{"cluster", "vpc"})
+		}
+	default:
+		return fmt.Errorf("--cluster-dns must be one of %v", []string{"", cloudDNS, kubeDNS})
+	}
+	// NodeLocal DNSCache is always enabled in GKE Autopilot clusters, which
+	// use Cloud DNS instead of kube-dns, so verifyDNSHealthy would wait for a
+	// kube-dns that is not deployed.
+	if d.autopilot && d.nodeLocalDNSCache {
+		return fmt.Errorf("--enable-node-local-dns-cache cannot be used with --autopilot, it is always enabled for GKE Autopilot clusters")
+	}
+	return nil
+}
+
+// clusterDNSArgs returns the args for the DNS provider needed for the cluster
+// creation command.
+func (d *deployer) clusterDNSArgs() []string {
+	args := []string{}
+	if d.clusterDNS != "" {
+		args = append(args, "--cluster-dns="+d.clusterDNS)
+	}
+	if d.clusterDNSScope != "" {
+		args = append(args, "--cluster-dns-scope="+d.clusterDNSScope)
+	}
+	if d.clusterDNSDomain != "" {
+		args = append(args, "--cluster-dns-domain="+d.clusterDNSDomain)
+	}
+	return args
+}
+
+// verifyDNSHealthy waits for the DNS addons of all the clusters to be rolled
+// out, so that DNS-focused tests do not start against a broken DNS.
+func (d *deployer) verifyDNSHealthy() error {
+	if d.clusterDNS == "" && !d.nodeLocalDNSCache {
+		return nil
+	}
+
+	var workloads []string
+	// kube-dns is not deployed in the clusters using Cloud DNS.
+	if d.clusterDNS != cloudDNS {
+		workloads = append(workloads, "deployment/kube-dns")
+	}
+	if d.nodeLocalDNSCache {
+		workloads = append(workloads, "daemonset/node-local-dns")
+	}
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			kubeconfig := d.clusterKubeconfig(project, cluster.name)
+			for _, workload := range workloads {
+				klog.V(1).Infof("Waiting for %s in cluster %s to be ready", workload, cluster.name)
+				if err := runWithOutput(kubectlCommand(kubeconfig, "rollout", "status", workload,
+					"--namespace=kube-system", "--timeout=10m")); err != nil {
+					return fmt.Errorf("error waiting for %s in cluster %s to be ready: %w", workload, cluster.name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVerifyDNSFlags(t *testing.T) {
+	testCases := []struct {
+		name      string
+		d         deployer
+		expectErr bool
+	}{
+		{
+			name: "default",
+			d:    deployer{},
+		},
+		{
+			name: "kube-dns",
+			d:    deployer{clusterDNS: kubeDNS},
+		},
+		{
+			name:      "kube-dns with a scope",
+			d:         deployer{clusterDNS: kubeDNS, clusterDNSScope: "cluster"},
+			expectErr: true,
+		},
+		{
+			name:      "domain without cloud dns",
+			d:         deployer{clusterDNSDomain: "example.com"},
+			expectErr: true,
+		},
+		{
+			name: "cloud dns",
+			d:    deployer{clusterDNS: cloudDNS},
+		},
+		{
+			name: "cloud dns with cluster scope",
+			d:    deployer{clusterDNS: cloudDNS, clusterDNSScope: "cluster"},
+		},
+		{
+			name:      "cloud dns with cluster scope and a domain",
+			d:         deployer{clusterDNS: cloudDNS, clusterDNSScope: "cluster", clusterDNSDomain: "example.com"},
+			expectErr: true,
+		},
+		{
+			name: "cloud dns with vpc scope",
+			d:    deployer{clusterDNS: cloudDNS, clusterDNSScope: "vpc", clusterDNSDomain: "example.com"},
+		},
+		{
+			name:      "cloud dns with vpc scope and no domain",
+			d:         deployer{clusterDNS: cloudDNS, clusterDNSScope: "vpc"},
+			expectErr: true,
+		},
+		{
+			name:      "cloud dns with an unknown scope",
+			d:         deployer{clusterDNS: cloudDNS, clusterDNSScope: "global"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown dns provider",
+			d:         deployer{clusterDNS: "coredns"},
+			expectErr: true,
+		},
+		{
+			name: "nodelocal dns",
+			d:    deployer{nodeLocalDNSCache: true},
+		},
+		{
+			name:      "nodelocal dns with autopilot",
+			d:         deployer{autopilot: true, nodeLocalDNSCache: true},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			err := tc.d.verifyDNSFlags()
+			if (err != nil) != tc.expectErr {
+				st.Errorf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestClusterDNSArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		d        deployer
+		expected []string
+	}{
+		{
+			name:     "default",
+			d:        deployer{},
+			expected: []string{},
+		},
+		{
+			name:     "kube-dns",
+			d:        deployer{clusterDNS: kubeDNS},
+			expected: []string{"--cluster-dns=kubedns"},
+		},
+		{
+			name:     "cloud dns with vpc scope",
+			d:        deployer{clusterDNS: cloudDNS, clusterDNSScope: "vpc", clusterDNSDomain: "example.com"},
+			expected: []string{"--cluster-dns=clouddns", "--cluster-dns-scope=vpc", "--cluster-dns-domain=example.com"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			if diff := cmp.Diff(tc.expected, tc.d.clusterDNSArgs()); diff != "" {
+				st.Errorf("args differ (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
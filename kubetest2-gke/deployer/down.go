@@ -17,45 +17,76 @@ limitations under the License.
 package deployer
 
 import (
+	"context"
 	"fmt"
-	"sync"
 
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
 func (d *deployer) Down() error {
 	if err := d.init(); err != nil {
 		return err
 	}
+	if d.upDeclined {
+		klog.V(0).Info("The creation of the clusters was not confirmed, nothing to delete")
+		return nil
+	}
 
 	if len(d.projects) > 0 {
-		if err := d.prepareGcpIfNeeded(d.projects[0]); err != nil {
+		if err := d.prepareGcpIfNeeded(); err != nil {
+			return err
+		}
+
+		if d.downMode == downModeNamespaces {
+			err := d.cleanupLeftovers()
+			// The service accounts are deleted along with the clusters
+			// otherwise.
+			if err := d.deleteStaticTokens(); err != nil {
+				klog.Errorf("Error deleting the service accounts of the kubeconfig token: %v", err)
+			}
+			// The resources created for the run are deleted even though the
+			// clusters are kept.
+			if err := d.deleteBackups(); err != nil {
+				klog.Errorf("Error deleting the cluster backups: %v", err)
+			}
+			if err := d.deleteNotificationsTopics(); err != nil {
+				klog.Errorf("Error deleting the cluster notifications topics: %v", err)
+			}
+			if err := d.deleteArtifactRegistryMirrors(); err != nil {
+				klog.Errorf("Error deleting the Artifact Registry mirrors: %v", err)
+			}
+			return err
+		}
+		if err := d.confirmDown(); err != nil {
 			return err
 		}
 
-		var wg sync.WaitGroup
+		if err := d.deleteBackups(); err != nil {
+			klog.Errorf("Error deleting the cluster backups: %v", err)
+		}
+
+		// Wait for all the clusters to be deleted before cleaning up the
+		// network resources they use.
+		var clusterDeletions operationTracker
 		for i := range d.projects {
 			project := d.projects[i]
 			for j := range d.projectClustersLayout[project] {
 				cluster := d.projectClustersLayout[project][j]
 				loc := locationFlag(d.region, d.zone)
 
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					// We best-effort try all of these and report errors as appropriate.
-					if err := runWithOutput(exec.Command(
+				// We best-effort try all of these and report errors as appropriate.
+				clusterDeletions.start(types.Context(d.commonOptions), "delete cluster "+cluster.name, d.cleanupTimeout, func(ctx context.Context) error {
+					return runWithOutput(exec.CommandContext(ctx,
 						"gcloud", containerArgs("clusters", "delete", "-q", cluster.name,
 							"--project="+project,
-							loc)...)); err != nil {
-						klog.Errorf("Error deleting cluster: %v", err)
-					}
-				}()
+							loc)...))
+				})
 			}
 		}
-		wg.Wait()
+		errDeleteClusters := clusterDeletions.wait()
 
 		numDeletedFWRules, errCleanFirewalls := d.cleanupNetworkFirewalls(d.projects[0], d.network)
 		if errCleanFirewalls != nil {
@@ -64,6 +95,13 @@ func (d *deployer) Down() error {
 			klog.V(1).Infof("Deleted %d network firewall rules", numDeletedFWRules)
 		}
 
+		if err := d.deleteNotificationsTopics(); err != nil {
+			klog.Errorf("Error deleting the cluster notifications topics: %v", err)
+		}
+		if err := d.deleteArtifactRegistryMirrors(); err != nil {
+			klog.Errorf("Error deleting the Artifact Registry mirrors: %v", err)
+		}
+
 		if err := d.teardownNetwork(); err != nil {
 			return err
 		}
@@ -73,6 +111,9 @@ func (d *deployer) Down() error {
 		if err := d.deleteNetwork(); err != nil {
 			return err
 		}
+		if errDeleteClusters != nil {
+			return fmt.Errorf("error deleting clusters: %w", errDeleteClusters)
+		}
 	}
 
 	return nil
@@ -89,5 +130,9 @@ func (d *deployer) verifyDownFlags() error {
 	if err := d.verifyLocationFlags(); err != nil {
 		return err
 	}
+	if err := d.verifyDownModeFlags(); err != nil {
+		return err
+	}
+	d.raiseScaleCleanupTimeout()
 	return nil
 }
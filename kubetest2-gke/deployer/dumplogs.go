@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
 // DumpClusterLogs for GKE generates a small script that wraps
@@ -36,15 +37,18 @@ func (d *deployer) DumpClusterLogs() error {
 	// gkeLogDumpTemplate is a template of a shell script where
 	// - %[1]s is the project
 	// - %[2]s is the zone
-	// - %[3]s is a filter composed of the instance groups
-	// - %[4]s is the log-dump.sh command line
+	// - %[3]s is the node OS distribution
+	// - %[4]s is a filter composed of the instance groups
+	// - %[5]s are extra args for listing the instances
+	// - %[6]s are extra environment variables for log-dump.sh
+	// - %[7]s is the log-dump.sh command line
 	const gkeLogDumpTemplate = `
 function log_dump_custom_get_instances() {
   if [[ $1 == "master" ]]; then
     return 0
   fi
 
-  gcloud compute instances list '--project=%[1]s' '--filter=%[4]s' '--format=get(name)'
+  gcloud compute instances list '--project=%[1]s' '--filter=%[4]s' %[5]s
 }
 export -f log_dump_custom_get_instances
 # Set below vars that log-dump.sh expects in order to use scp with gcloud.
@@ -52,7 +56,8 @@ export PROJECT=%[1]s
 export ZONE='%[2]s'
 export KUBERNETES_PROVIDER=gke
 export KUBE_NODE_OS_DISTRIBUTION='%[3]s'
-%[5]s
+%[6]s
+%[7]s
 `
 	for _, project := range d.projects {
 		// Prevent an obvious injection.
@@ -71,17 +76,24 @@ export KUBE_NODE_OS_DISTRIBUTION='%[3]s'
 			}
 		}
 
+		listArgs, env, err := d.nodeLogsArgs()
+		if err != nil {
+			return err
+		}
+
 		// Generate the log-dump.sh command-line
 		dumpCmd := fmt.Sprintf("./cluster/log-dump/log-dump.sh '%s'", d.localLogsDir)
 		if d.gcsLogsDir != "" {
 			dumpCmd += " " + d.gcsLogsDir
 		}
 
-		cmd := exec.Command("bash", "-c", fmt.Sprintf(gkeLogDumpTemplate,
+		cmd := exec.CommandContext(types.Context(d.commonOptions), "bash", "-c", fmt.Sprintf(gkeLogDumpTemplate,
 			project,
 			d.zone,
 			os.Getenv("NODE_OS_DISTRIBUTION"),
 			strings.Join(filters, " OR "),
+			listArgs,
+			env,
 			dumpCmd))
 		cmd.SetDir(d.RepoRoot)
 		if err := runWithOutput(cmd); err != nil {
@@ -91,3 +103,49 @@ export KUBE_NODE_OS_DISTRIBUTION='%[3]s'
 
 	return nil
 }
+
+// nodeLogsArgs returns the extra args for listing the nodes to collect the
+// logs from, and the extra log-dump.sh environment variables to collect the
+// configured files and systemd units.
+func (d *deployer) nodeLogsArgs() (string, string, error) {
+	values := append([]string{d.nodeLogSSHUser, d.nodeLogSSHKey}, d.nodeLogFiles...)
+	values = append(values, d.nodeLogSystemdUnits...)
+	for _, v := range values {
+		// Prevent an obvious injection.
+		if strings.ContainsAny(v, "'\n") {
+			return "", "", fmt.Errorf("%q contains single quotes or newlines - nice try", v)
+		}
+	}
+
+	if d.nodeLogSSHKey != "" && d.nodeLogSSHUser == "" {
+		return "", "", fmt.Errorf("--node-log-ssh-user must be set with --node-log-ssh-key")
+	}
+
+	listArgs := []string{"'--format=get(name)'"}
+	var env []string
+	if d.nodeLogSSHKey != "" {
+		// log-dump.sh only uses gcloud to ssh into the nodes for the gce/gke
+		// providers, so use a provider-agnostic one to ssh with the key
+		// directly, which requires the addresses of the nodes.
+		listArgs = []string{"'--format=get(networkInterfaces[0].accessConfigs[0].natIP)'"}
+		// The nodes have no public IPs inside a VPC Service Controls perimeter.
+		if d.vpcServiceControls {
+			listArgs = []string{"'--format=get(networkInterfaces[0].networkIP)'"}
+		}
+		env = append(env,
+			"export KUBERNETES_PROVIDER=skeleton",
+			fmt.Sprintf("export LOG_DUMP_SSH_KEY='%s'", d.nodeLogSSHKey),
+			fmt.Sprintf("export LOG_DUMP_SSH_USER='%s'", d.nodeLogSSHUser),
+		)
+	}
+	if d.nodeLogMaxNodes > 0 {
+		listArgs = append(listArgs, fmt.Sprintf("'--limit=%d'", d.nodeLogMaxNodes))
+	}
+	if len(d.nodeLogFiles) > 0 {
+		env = append(env, fmt.Sprintf("export LOG_DUMP_EXTRA_FILES='%s'", strings.Join(d.nodeLogFiles, " ")))
+	}
+	if len(d.nodeLogSystemdUnits) > 0 {
+		env = append(env, fmt.Sprintf("export LOG_DUMP_SAVE_SERVICES='%s'", strings.Join(d.nodeLogSystemdUnits, " ")))
+	}
+	return strings.Join(listArgs, " "), strings.Join(env, "\n"), nil
+}
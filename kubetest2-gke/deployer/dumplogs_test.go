@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import "testing"
+
+func TestNodeLogsArgs(t *testing.T) {
+	testCases := []struct {
+		name             string
+		d                deployer
+		expectedListArgs string
+		expectedEnv      string
+		expectErr        bool
+	}{
+		{
+			name:             "default",
+			d:                deployer{},
+			expectedListArgs: "'--format=get(name)'",
+			expectedEnv:      "",
+		},
+		{
+			name:             "files, units and max nodes",
+			d:                deployer{nodeLogFiles: []string{"containerd.log", "audit.log"}, nodeLogSystemdUnits: []string{"kubelet"}, nodeLogMaxNodes: 5},
+			expectedListArgs: "'--format=get(name)' '--limit=5'",
+			expectedEnv:      "export LOG_DUMP_EXTRA_FILES='containerd.log audit.log'\nexport LOG_DUMP_SAVE_SERVICES='kubelet'",
+		},
+		{
+			name:             "ssh key",
+			d:                deployer{nodeLogSSHUser: "prow", nodeLogSSHKey: "/etc/ssh-key"},
+			expectedListArgs: "'--format=get(networkInterfaces[0].accessConfigs[0].natIP)'",
+			expectedEnv:      "export KUBERNETES_PROVIDER=skeleton\nexport LOG_DUMP_SSH_KEY='/etc/ssh-key'\nexport LOG_DUMP_SSH_USER='prow'",
+		},
+		{
+			name:             "ssh key with vpc service controls",
+			d:                deployer{nodeLogSSHUser: "prow", nodeLogSSHKey: "/etc/ssh-key", vpcServiceControls: true},
+			expectedListArgs: "'--format=get(networkInterfaces[0].networkIP)'",
+			expectedEnv:      "export KUBERNETES_PROVIDER=skeleton\nexport LOG_DUMP_SSH_KEY='/etc/ssh-key'\nexport LOG_DUMP_SSH_USER='prow'",
+		},
+		{
+			name:      "ssh key without user",
+			d:         deployer{nodeLogSSHKey: "/etc/ssh-key"},
+			expectErr: true,
+		},
+		{
+			name:      "single quote",
+			d:         deployer{nodeLogFiles: []string{"a'; rm -rf /; '"}},
+			expectErr: true,
+		},
+		{
+			name:      "newline",
+			d:         deployer{nodeLogSystemdUnits: []string{"kubelet\nreboot"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			listArgs, env, err := tc.d.nodeLogsArgs()
+			if (err != nil) != tc.expectErr {
+				st.Fatalf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if tc.expectErr {
+				return
+			}
+			if listArgs != tc.expectedListArgs {
+				st.Errorf("expected list args %q, got %q", tc.expectedListArgs, listArgs)
+			}
+			if env != tc.expectedEnv {
+				st.Errorf("expected env %q, got %q", tc.expectedEnv, env)
+			}
+		})
+	}
+}
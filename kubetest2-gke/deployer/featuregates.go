@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/featuregates"
+)
+
+// verifyFeatureGatesFlags validates --feature-gates and --runtime-config.
+// GKE does not allow setting the feature gates of the control plane, so the
+// enabled gates are translated into an alpha cluster, which enables all the
+// alpha features, and the enabled APIs into --enable-kubernetes-unstable-apis.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/concepts/alpha-clusters
+func (d *deployer) verifyFeatureGatesFlags() error {
+	gates, err := featuregates.Parse(d.featureGates)
+	if err != nil {
+		return fmt.Errorf("invalid --feature-gates: %w", err)
+	}
+	runtimeConfig, err := featuregates.ParseRuntimeConfig(d.runtimeConfig)
+	if err != nil {
+		return fmt.Errorf("invalid --runtime-config: %w", err)
+	}
+	if len(gates) == 0 && len(runtimeConfig) == 0 {
+		return nil
+	}
+
+	for gate, enabled := range gates {
+		if !enabled {
+			return fmt.Errorf("feature gate %q cannot be disabled on GKE clusters", gate)
+		}
+	}
+	for api, value := range runtimeConfig {
+		if value != "true" {
+			return fmt.Errorf("API %q cannot be disabled on GKE clusters", api)
+		}
+		// The unstable APIs are enabled per resource, e.g. storage.k8s.io/v1beta1/csistoragecapacities.
+		if strings.Count(api, "/") != 2 {
+			return fmt.Errorf("API %q of --runtime-config must be in the format of group/version/resource for GKE clusters", api)
+		}
+	}
+	if len(gates) > 0 {
+		if d.autopilot {
+			return fmt.Errorf("--feature-gates is not supported for GKE Autopilot clusters")
+		}
+		klog.V(0).Infof("--feature-gates specified, creating alpha clusters with all the alpha features enabled, not only %s", d.featureGates)
+	}
+	return nil
+}
+
+// featureGatesArgs returns the args for the feature gates and the runtime
+// config needed for the cluster creation command.
+func (d *deployer) featureGatesArgs() []string {
+	args := []string{}
+	// The flags are validated in verifyFeatureGatesFlags.
+	gates, _ := featuregates.Parse(d.featureGates)
+	if len(gates) > 0 {
+		args = append(args, "--enable-kubernetes-alpha")
+		args = append(args, d.alphaNodePoolArgs()...)
+	}
+	runtimeConfig, _ := featuregates.ParseRuntimeConfig(d.runtimeConfig)
+	if len(runtimeConfig) > 0 {
+		var apis []string
+		for api := range runtimeConfig {
+			apis = append(apis, api)
+		}
+		sort.Strings(apis)
+		args = append(args, "--enable-kubernetes-unstable-apis="+strings.Join(apis, ","))
+	}
+	return args
+}
+
+// alphaNodePoolArgs returns the args needed for creating the node pools of
+// alpha clusters, which do not support node auto-repair and auto-upgrade.
+func (d *deployer) alphaNodePoolArgs() []string {
+	// The flags are validated in verifyFeatureGatesFlags.
+	if gates, _ := featuregates.Parse(d.featureGates); len(gates) == 0 {
+		return nil
+	}
+	return []string{"--no-enable-autorepair", "--no-enable-autoupgrade"}
+}
+
+// recordFeatureGates records the requested feature gates and runtime config
+// in the metadata, so that the results of the alpha clusters can be told apart.
+func (d *deployer) recordFeatureGates() {
+	if d.featureGates != "" {
+		d.metadata.Add("feature_gates", d.featureGates)
+	}
+	if d.runtimeConfig != "" {
+		d.metadata.Add("runtime_config", d.runtimeConfig)
+	}
+}
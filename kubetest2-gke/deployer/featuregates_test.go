@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFeatureGatesArgs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		d         *deployer
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "no feature gates",
+			d:        &deployer{},
+			expected: []string{},
+		},
+		{
+			name:     "feature gates create alpha clusters",
+			d:        &deployer{featureGates: "GateA=true,GateB=true"},
+			expected: []string{"--enable-kubernetes-alpha", "--no-enable-autorepair", "--no-enable-autoupgrade"},
+		},
+		{
+			name:     "runtime config enables the unstable APIs",
+			d:        &deployer{runtimeConfig: "storage.k8s.io/v1beta1/csistoragecapacities=true,batch/v1beta1/cronjobs"},
+			expected: []string{"--enable-kubernetes-unstable-apis=batch/v1beta1/cronjobs,storage.k8s.io/v1beta1/csistoragecapacities"},
+		},
+		{
+			name:      "disabled feature gate",
+			d:         &deployer{featureGates: "GateA=false"},
+			expectErr: true,
+		},
+		{
+			name:      "disabled API",
+			d:         &deployer{runtimeConfig: "batch/v1beta1/cronjobs=false"},
+			expectErr: true,
+		},
+		{
+			name:      "API without resource",
+			d:         &deployer{runtimeConfig: "batch/v1beta1"},
+			expectErr: true,
+		},
+		{
+			name:      "feature gates with autopilot",
+			d:         &deployer{featureGates: "GateA=true", autopilot: true},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			err := tc.d.verifyFeatureGatesFlags()
+			if (err != nil) != tc.expectErr {
+				st.Fatalf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if tc.expectErr {
+				return
+			}
+			if diff := cmp.Diff(tc.expected, tc.d.featureGatesArgs()); diff != "" {
+				st.Errorf("args differ (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAlphaNodePoolArgs(t *testing.T) {
+	d := &deployer{
+		featureGates:       "GateA=true",
+		windowsImageType:   "WINDOWS_LTSC_CONTAINERD",
+		windowsMachineType: "n1-standard-4",
+		windowsNodes:       1,
+	}
+	alphaArgs := []string{"--no-enable-autorepair", "--no-enable-autoupgrade"}
+	for name, args := range map[string][]string{
+		"windows": d.windowsNodePoolArgs("project", "--zone=us-central1-c", "cluster"),
+		"cloned":  d.clonedNodePoolArgs("project", "--zone=us-central1-c", "cluster", clonedNodePool{Name: "pool"}),
+	} {
+		if diff := cmp.Diff(alphaArgs, args[len(args)-len(alphaArgs):]); diff != "" {
+			t.Errorf("%s node pool args of an alpha cluster differ (-want, +got):\n%s", name, diff)
+		}
+	}
+	if args := (&deployer{}).alphaNodePoolArgs(); len(args) != 0 {
+		t.Errorf("expected no args without feature gates, got %v", args)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"k8s.io/klog"
@@ -27,6 +28,78 @@ import (
 	"sigs.k8s.io/kubetest2/pkg/exec"
 )
 
+// firewallRuleSet is a set of ports opened on the cluster nodes for the tests.
+type firewallRuleSet struct {
+	name  string
+	allow string
+}
+
+// predefinedFirewallRuleSets are the rule sets selectable with
+// --firewall-rule-sets.
+var predefinedFirewallRuleSets = map[string]string{
+	// SSH, HTTP and the NodePort range, used by the e2e tests.
+	"e2e": e2eAllow,
+	// The NodePort range only.
+	"nodeports": "tcp:30000-32767,udp:30000-32767",
+	// RDP and WinRM of the Windows nodes.
+	"windows": windowsAllow,
+	// SMB of the Windows nodes, used by the Windows storage tests.
+	"windows-smb": "tcp:445",
+	// The konnectivity agent port.
+	"konnectivity": "tcp:8132",
+}
+
+// parseFirewallRuleSets parses the --firewall-rule-sets flag, which contains
+// either predefined rule set names or custom rule sets in the format of
+// name=allow, e.g. dns=tcp:53,udp:53 (in which the protocols are separated by
+// semicolon when the flag is comma separated, e.g. dns=tcp:53;udp:53).
+func parseFirewallRuleSets(values []string) ([]firewallRuleSet, error) {
+	var ruleSets []firewallRuleSet
+	seen := map[string]bool{}
+	for _, value := range values {
+		var ruleSet firewallRuleSet
+		if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+			if invalidResourceNameChars.MatchString(parts[0]) || parts[1] == "" {
+				return nil, fmt.Errorf("custom firewall rule set %q should be in the format of name=allow, e.g. dns=tcp:53;udp:53", value)
+			}
+			ruleSet = firewallRuleSet{name: parts[0], allow: strings.ReplaceAll(parts[1], ";", ",")}
+		} else if allow, ok := predefinedFirewallRuleSets[value]; ok {
+			ruleSet = firewallRuleSet{name: value, allow: allow}
+		} else {
+			names := make([]string, 0, len(predefinedFirewallRuleSets))
+			for name := range predefinedFirewallRuleSets {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown firewall rule set %q, must be one of %v or name=allow", value, names)
+		}
+		if seen[ruleSet.name] {
+			return nil, fmt.Errorf("firewall rule set %q is specified more than once", ruleSet.name)
+		}
+		seen[ruleSet.name] = true
+		ruleSets = append(ruleSets, ruleSet)
+	}
+	return ruleSets, nil
+}
+
+// clusterFirewallRuleSets returns the rule sets to create for each cluster.
+// The Windows rule set is always included if Windows nodes are requested.
+func (d *deployer) clusterFirewallRuleSets() ([]firewallRuleSet, error) {
+	ruleSets, err := parseFirewallRuleSets(d.firewallRuleSets)
+	if err != nil {
+		return nil, err
+	}
+	if d.windowsNodes > 0 {
+		for _, ruleSet := range ruleSets {
+			if ruleSet.name == "windows" {
+				return ruleSets, nil
+			}
+		}
+		ruleSets = append(ruleSets, firewallRuleSet{name: "windows", allow: windowsAllow})
+	}
+	return ruleSets, nil
+}
+
 func (d *deployer) ensureFirewallRules() error {
 	// Do not modify the firewall rules for the default network
 	if d.network == "default" {
@@ -34,46 +107,72 @@ func (d *deployer) ensureFirewallRules() error {
 	}
 
 	if len(d.projects) == 1 {
-		project := d.projects[0]
-		return ensureFirewallRulesForSingleProject(project, d.network, d.projectClustersLayout[project], d.instanceGroups)
+		ruleSets, err := d.clusterFirewallRuleSets()
+		if err != nil {
+			return err
+		}
+		return d.forEachCluster(func(project string, cluster cluster) error {
+			return ensureClusterFirewallRules(project, project, d.network, cluster.name, d.instanceGroups, ruleSets)
+		})
 	}
 
-	return ensureFirewallRulesForMultiProjects(d.projects, d.network, d.subnetworkRanges)
+	if err := ensureFirewallRulesForMultiProjects(d.projects, d.network, d.subnetworkRanges); err != nil {
+		return err
+	}
+	// The Windows nodes are reached from outside of the shared VPC, whose
+	// firewall rules are created in the host project.
+	if d.windowsNodes == 0 {
+		return nil
+	}
+	ruleSets := []firewallRuleSet{{name: "windows", allow: windowsAllow}}
+	return d.forEachCluster(func(project string, cluster cluster) error {
+		return ensureClusterFirewallRules(d.projects[0], project, d.network, cluster.name, d.instanceGroups, ruleSets)
+	})
 }
 
-// Ensure firewall rules for e2e testing for all clusters in one single project.
-func ensureFirewallRulesForSingleProject(project, network string, clusters []cluster, instanceGroups map[string]map[string][]*ig) error {
-	for _, cluster := range clusters {
-		clusterName := cluster.name
-		klog.V(1).Infof("Ensuring firewall rules for cluster %s in %s", clusterName, project)
-		firewall := clusterFirewallName(project, clusterName, instanceGroups)
-		if runWithNoOutput(exec.Command("gcloud", "compute", "firewall-rules", "describe", firewall,
-			"--project="+project,
+// Ensure firewall rules for e2e testing for a cluster in the project, created
+// in the project of the network. A firewall rule is created for each of the
+// rule sets.
+func ensureClusterFirewallRules(networkProject, project, network, clusterName string, instanceGroups map[string]map[string][]*ig, ruleSets []firewallRuleSet) error {
+	klog.V(1).Infof("Ensuring firewall rules for cluster %s in %s", clusterName, project)
+	firewall := clusterFirewallName(project, clusterName, instanceGroups)
+	// The network tag is only looked up if a rule needs to be created.
+	tag := ""
+	for _, ruleSet := range ruleSets {
+		rule := ruleSetFirewallName(firewall, ruleSet.name)
+		if runWithNoOutput(exec.Command("gcloud", "compute", "firewall-rules", "describe", rule,
+			"--project="+networkProject,
 			"--format=value(name)")) == nil {
 			// Assume that if this unique firewall exists, it's good to go.
 			continue
 		}
-		klog.V(1).Infof("Couldn't describe firewall '%s', assuming it doesn't exist and creating it", firewall)
+		klog.V(1).Infof("Couldn't describe firewall '%s', assuming it doesn't exist and creating it", rule)
 
-		tagOut, err := exec.Output(exec.Command("gcloud", "compute", "instances", "list",
-			"--project="+project,
-			"--filter=metadata.created-by:*"+instanceGroups[project][clusterName][0].path,
-			"--limit=1",
-			"--format=get(tags.items)"))
-		if err != nil {
-			return fmt.Errorf("instances list failed: %s", execError(err))
-		}
-		tag := strings.TrimSpace(string(tagOut))
 		if tag == "" {
-			return fmt.Errorf("instances list returned no instances (or instance has no tags)")
+			var instances []struct {
+				Tags struct {
+					Items []string `json:"items"`
+				} `json:"tags"`
+			}
+			if err := gcloudJSON(&instances, "compute", "instances", "list",
+				"--project="+project,
+				"--filter=metadata.created-by:*"+instanceGroups[project][clusterName][0].path,
+				"--limit=1"); err != nil {
+				return fmt.Errorf("instances list failed: %s", execError(err))
+			}
+			if len(instances) == 0 || len(instances[0].Tags.Items) == 0 {
+				return fmt.Errorf("instances list returned no instances (or instance has no tags)")
+			}
+			tag = instances[0].Tags.Items[0]
 		}
 
-		if err := runWithOutput(exec.Command("gcloud", "compute", "firewall-rules", "create", firewall,
-			"--project="+project,
+		// GKE uses the same network tag for all the node pools of a cluster.
+		if err := runWithOutput(exec.Command("gcloud", "compute", "firewall-rules", "create", rule,
+			"--project="+networkProject,
 			"--network="+network,
-			"--allow="+e2eAllow,
+			"--allow="+ruleSet.allow,
 			"--target-tags="+tag)); err != nil {
-			return fmt.Errorf("error creating e2e firewall rule: %v", err)
+			return fmt.Errorf("error creating %s firewall rule: %v", ruleSet.name, err)
 		}
 	}
 	return nil
@@ -87,6 +186,15 @@ func clusterFirewallName(project, cluster string, instanceGroups map[string]map[
 	return "e2e-ports-" + instanceGroups[project][cluster][0].uniq
 }
 
+// ruleSetFirewallName returns the name of the firewall rule of the rule set,
+// the e2e rule set keeps the name of the cluster firewall rule.
+func ruleSetFirewallName(firewall, ruleSet string) string {
+	if ruleSet == "e2e" {
+		return firewall
+	}
+	return firewall + "-" + ruleSet
+}
+
 // Ensure firewall rules for multi-project profile.
 // Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#creating_additional_firewall_rules
 // Please note we are not including the firewall rule for SSH connection as it's not needed for testing.
@@ -125,15 +233,19 @@ func (d *deployer) cleanupNetworkFirewalls(hostProject, network string) (int, er
 	}
 
 	klog.V(1).Infof("Cleaning up network firewall rules for network %s in %s", network, hostProject)
-	fws, err := exec.Output(exec.Command("gcloud", "compute", "firewall-rules", "list",
-		"--format=value(name)",
+	var fws []struct {
+		Name string `json:"name"`
+	}
+	if err := gcloudJSON(&fws, "compute", "firewall-rules", "list",
 		"--project="+hostProject,
-		"--filter=network:"+network))
-	if err != nil {
+		"--filter=network:"+network); err != nil {
 		return 0, fmt.Errorf("firewall rules list failed: %s", execError(err))
 	}
 	if len(fws) > 0 {
-		fwList := strings.Split(strings.TrimSpace(string(fws)), "\n")
+		var fwList []string
+		for _, fw := range fws {
+			fwList = append(fwList, fw.Name)
+		}
 		klog.V(1).Infof("Network %s has %v undeleted firewall rules %v", network, len(fwList), fwList)
 		commandArgs := []string{"compute", "firewall-rules", "delete", "-q"}
 		commandArgs = append(commandArgs, fwList...)
@@ -157,41 +269,46 @@ func (d *deployer) getInstanceGroups() error {
 	}
 
 	// Initialize project instance groups structure
-	d.instanceGroups = map[string]map[string][]*ig{}
-
-	location := locationFlag(d.region, d.zone)
-
+	instanceGroups := map[string]map[string][]*ig{}
 	for _, project := range d.projects {
-		d.instanceGroups[project] = map[string][]*ig{}
+		instanceGroups[project] = map[string][]*ig{}
+	}
 
-		for _, cluster := range d.projectClustersLayout[project] {
-			clusterName := cluster.name
+	location := locationFlag(d.region, d.zone)
+	var lock sync.Mutex
+	if err := d.forEachCluster(func(project string, cluster cluster) error {
+		clusterName := cluster.name
 
-			igs, err := exec.Output(exec.Command("gcloud", containerArgs("clusters", "describe", clusterName,
-				"--format=value(instanceGroupUrls)",
-				"--project="+project,
-				location)...))
-			if err != nil {
-				return fmt.Errorf("instance group URL fetch failed: %s", execError(err))
-			}
-			igURLs := strings.Split(strings.TrimSpace(string(igs)), ";")
-			if len(igURLs) == 0 {
-				return fmt.Errorf("no instance group URLs returned by gcloud, output %q", string(igs))
-			}
-			sort.Strings(igURLs)
+		c, err := describeCluster(project, location, clusterName)
+		if err != nil {
+			return fmt.Errorf("instance group URL fetch failed: %s", execError(err))
+		}
+		igURLs := c.InstanceGroupUrls
+		if len(igURLs) == 0 {
+			return fmt.Errorf("no instance group URLs returned by gcloud for cluster %s", clusterName)
+		}
+		sort.Strings(igURLs)
 
-			// Initialize cluster instance groups
-			d.instanceGroups[project][clusterName] = make([]*ig, 0)
+		// Initialize cluster instance groups
+		clusterIGs := make([]*ig, 0)
 
-			for _, igURL := range igURLs {
-				m := poolRe.FindStringSubmatch(igURL)
-				if len(m) == 0 {
-					return fmt.Errorf("instanceGroupUrl %q did not match regex %v", igURL, poolRe)
-				}
-				d.instanceGroups[project][clusterName] = append(d.instanceGroups[project][clusterName], &ig{path: m[0], zone: m[1], name: m[2], uniq: m[3]})
+		for _, igURL := range igURLs {
+			m := poolRe.FindStringSubmatch(igURL)
+			if len(m) == 0 {
+				return fmt.Errorf("instanceGroupUrl %q did not match regex %v", igURL, poolRe)
 			}
+			clusterIGs = append(clusterIGs, &ig{path: m[0], zone: m[1], name: m[2], uniq: m[3]})
 		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		instanceGroups[project][clusterName] = clusterIGs
+		return nil
+	}); err != nil {
+		return err
 	}
 
+	// Only set once complete, so that a failure is retried on the next call.
+	d.instanceGroups = instanceGroups
 	return nil
 }
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseFirewallRuleSets(t *testing.T) {
+	testCases := []struct {
+		name        string
+		values      []string
+		expected    []firewallRuleSet
+		expectError bool
+	}{
+		{
+			name:     "default",
+			values:   []string{"e2e"},
+			expected: []firewallRuleSet{{name: "e2e", allow: e2eAllow}},
+		},
+		{
+			name:   "predefined and custom rule sets",
+			values: []string{"nodeports", "windows-smb", "dns=tcp:53;udp:53"},
+			expected: []firewallRuleSet{
+				{name: "nodeports", allow: "tcp:30000-32767,udp:30000-32767"},
+				{name: "windows-smb", allow: "tcp:445"},
+				{name: "dns", allow: "tcp:53,udp:53"},
+			},
+		},
+		{
+			name:        "unknown rule set",
+			values:      []string{"e2e", "unknown"},
+			expectError: true,
+		},
+		{
+			name:        "invalid custom rule set name",
+			values:      []string{"My_DNS=tcp:53"},
+			expectError: true,
+		},
+		{
+			name:        "duplicate rule set",
+			values:      []string{"windows", "windows=tcp:3389"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			actual, err := parseFirewallRuleSets(tc.values)
+			if tc.expectError {
+				if err == nil {
+					st.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				st.Errorf("unexpected error %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, actual, cmp.AllowUnexported(firewallRuleSet{})); diff != "" {
+				st.Errorf("firewall rule sets differ (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestRuleSetFirewallName(t *testing.T) {
+	if actual := ruleSetFirewallName("e2e-ports-abc", "e2e"); actual != "e2e-ports-abc" {
+		t.Errorf("expected the e2e rule set to keep the cluster firewall name, got %q", actual)
+	}
+	if actual := ruleSetFirewallName("e2e-ports-abc", "windows"); actual != "e2e-ports-abc-windows" {
+		t.Errorf("expected the windows rule set to be suffixed, got %q", actual)
+	}
+}
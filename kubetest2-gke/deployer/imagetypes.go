@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+)
+
+// verifyImageTypesFlags validates the --image-types flag, and sets the number
+// of clusters to create to one per image type if the cluster names are not
+// explicitly specified.
+func (d *deployer) verifyImageTypesFlags() error {
+	if len(d.imageTypes) == 0 {
+		return nil
+	}
+	// The node image is managed by GKE in Autopilot mode.
+	if d.autopilot {
+		return fmt.Errorf("--image-types is not supported for GKE Autopilot clusters")
+	}
+	if d.imageType != defaultImage {
+		return fmt.Errorf("--image-type cannot be used with --image-types")
+	}
+	if len(d.clusters) == 0 {
+		if d.UpOptions.NumClusters != 1 && d.UpOptions.NumClusters != len(d.imageTypes) {
+			return fmt.Errorf("--num-clusters must match the number of --image-types, got %d and %d", d.UpOptions.NumClusters, len(d.imageTypes))
+		}
+		klog.V(0).Infof("--image-types specified, creating one cluster per image type")
+		d.UpOptions.NumClusters = len(d.imageTypes)
+	} else if len(d.clusters) != len(d.imageTypes) {
+		return fmt.Errorf("the number of --cluster-name must match the number of --image-types, got %d and %d", len(d.clusters), len(d.imageTypes))
+	}
+	return nil
+}
+
+// clusterImageType returns the image type of the default node pool of the
+// cluster. With --image-types, the clusters use the image types in order.
+func (d *deployer) clusterImageType(c cluster) string {
+	if len(d.imageTypes) > 0 {
+		return d.imageTypes[c.index]
+	}
+	return d.imageType
+}
+
+// recordImageTypes records the image type of each cluster in the metadata and
+// in the junit properties, so the results of a node image matrix run can be
+// told apart.
+func (d *deployer) recordImageTypes() {
+	if d.autopilot {
+		return
+	}
+	imageTypes := make(map[string]string)
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			imageType := d.clusterImageType(cluster)
+			imageTypes[cluster.name] = imageType
+			d.metadata.AddJUnitProperty("image-type/"+cluster.name, imageType)
+		}
+	}
+	d.metadata.Add("image-types", imageTypes)
+}
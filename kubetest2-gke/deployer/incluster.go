@@ -0,0 +1,195 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// metadataServerURL is the URL of the GKE metadata server, which serves the
+// credentials of the Google service account bound to the Kubernetes service
+// account of the pod with Workload Identity.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/concepts/workload-identity#metadata_server
+var metadataServerURL = "http://metadata.google.internal/computeMetadata/v1"
+
+// inClusterRoles are the roles the Google service account of the pod needs on
+// the projects to run the deployer, with the reason they are needed.
+var inClusterRoles = map[string]string{
+	"roles/container.admin":        "create and delete the clusters and their RBAC resources",
+	"roles/compute.networkAdmin":   "create the networks and subnetworks",
+	"roles/compute.securityAdmin":  "create the firewall rules",
+	"roles/iam.serviceAccountUser": "use the service accounts of the nodes",
+}
+
+// inClusterServiceAccount returns the email of the Google service account of
+// the pod, if kubetest2 runs in a GKE pod with Workload Identity, e.g. a Prow
+// job on GKE.
+// The metadata server also answers in the pods without Workload Identity,
+// with the service account of the node. Only the GKE metadata server of
+// Workload Identity lists the identity namespace of the cluster,
+// PROJECT.svc.id.goog, among the service accounts.
+func inClusterServiceAccount() (string, bool) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return "", false
+	}
+	accounts, err := getMetadata("/instance/service-accounts/")
+	if err != nil {
+		klog.V(2).Infof("Failed to reach the metadata server, assuming Workload Identity is not used: %v", err)
+		return "", false
+	}
+	if !hasIdentityNamespace(accounts) {
+		klog.V(2).Infof("No identity namespace in the metadata server, Workload Identity is not used")
+		return "", false
+	}
+	email, err := getMetadata("/instance/service-accounts/default/email")
+	if err != nil {
+		klog.V(2).Infof("Failed to get the service account of the pod: %v", err)
+		return "", false
+	}
+	// The compute default service account is the one of the nodes, never
+	// the one bound to a Kubernetes service account.
+	if email == "" || strings.HasSuffix(email, "-compute@developer.gserviceaccount.com") {
+		return "", false
+	}
+	return email, true
+}
+
+// hasIdentityNamespace returns whether the service accounts listed by the
+// metadata server include the identity namespace of Workload Identity.
+func hasIdentityNamespace(accounts string) bool {
+	for _, account := range strings.Fields(accounts) {
+		if strings.HasSuffix(strings.TrimSuffix(account, "/"), ".svc.id.goog") {
+			return true
+		}
+	}
+	return false
+}
+
+// getMetadata returns the value at path of the metadata server.
+func getMetadata(path string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataServerURL+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// useInClusterCredentials makes gcloud use the credentials of the pod, if
+// kubetest2 runs in a GKE pod with Workload Identity and no
+// --gcp-service-account is set, so that no key needs to be mounted into the
+// pod. It returns false if the pod's credentials are not used.
+func (d *deployer) useInClusterCredentials() (bool, error) {
+	if !d.inClusterCredentials {
+		return false, nil
+	}
+	// An explicit key is always activated.
+	if d.gcpServiceAccount != "" {
+		if !d.inClusterChecked {
+			d.inClusterChecked = true
+			klog.V(1).Infof("--in-cluster-credentials ignored, activating --gcp-service-account")
+		}
+		return false, nil
+	}
+	if !d.inClusterChecked {
+		d.inClusterChecked = true
+		email, ok := inClusterServiceAccount()
+		if !ok {
+			return false, nil
+		}
+		klog.V(0).Infof("Running in a pod with Workload Identity, using the credentials of service account %s", email)
+		d.inClusterAccount = email
+		d.verifyInClusterRoles()
+	}
+	if d.inClusterAccount == "" {
+		return false, nil
+	}
+	// The application default credentials of the pod come from the metadata
+	// server, make sure gcloud does not use another account or key.
+	if err := os.Setenv("CLOUDSDK_CORE_ACCOUNT", d.inClusterAccount); err != nil {
+		return false, err
+	}
+	if err := os.Unsetenv("CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verifyInClusterRoles warns about the roles missing from the service account
+// of the pod on the projects, along with the command to grant them. The roles
+// granted through groups or on the folders are not seen, hence only a warning.
+func (d *deployer) verifyInClusterRoles() {
+	member := "serviceAccount:" + d.inClusterAccount
+	for _, project := range d.projects {
+		var policy []struct {
+			Bindings struct {
+				Role string `json:"role"`
+			} `json:"bindings"`
+		}
+		if err := gcloudJSON(&policy, "projects", "get-iam-policy", project,
+			"--flatten=bindings[].members",
+			"--filter=bindings.members="+member); err != nil {
+			klog.Warningf("Failed to check the roles of %s on project %s: %s", d.inClusterAccount, project, execError(err))
+			continue
+		}
+		granted := map[string]bool{}
+		for _, p := range policy {
+			granted[p.Bindings.Role] = true
+		}
+		for _, missing := range missingInClusterRoles(granted) {
+			klog.Warningf("Service account %s may be missing role %s on project %s to %s, grant it with: "+
+				"gcloud projects add-iam-policy-binding %s --member=%s --role=%s",
+				d.inClusterAccount, missing, project, inClusterRoles[missing], project, member, missing)
+		}
+	}
+}
+
+// missingInClusterRoles returns the roles of inClusterRoles not granted, the
+// basic owner and editor roles granting all of them.
+func missingInClusterRoles(granted map[string]bool) []string {
+	if granted["roles/owner"] || granted["roles/editor"] {
+		return nil
+	}
+	var missing []string
+	for role := range inClusterRoles {
+		if !granted[role] {
+			missing = append(missing, role)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
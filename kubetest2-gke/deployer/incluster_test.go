@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInClusterServiceAccount(t *testing.T) {
+	var accounts, email string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/instance/service-accounts/":
+			w.Write([]byte(accounts))
+		case "/instance/service-accounts/default/email":
+			w.Write([]byte(email))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func(url string) { metadataServerURL = url }(metadataServerURL)
+	metadataServerURL = server.URL
+	defer func(host string) { os.Setenv("KUBERNETES_SERVICE_HOST", host) }(os.Getenv("KUBERNETES_SERVICE_HOST"))
+
+	testCases := []struct {
+		name     string
+		host     string
+		accounts string
+		email    string
+		expected string
+	}{
+		{
+			name:     "outside of a pod",
+			accounts: "default/\nproject.svc.id.goog/\n",
+			email:    "prow@project.iam.gserviceaccount.com\n",
+		},
+		{
+			name:     "workload identity",
+			host:     "10.0.0.1",
+			accounts: "default/\nproject.svc.id.goog/\n",
+			email:    "prow@project.iam.gserviceaccount.com\n",
+			expected: "prow@project.iam.gserviceaccount.com",
+		},
+		{
+			name:     "node service account",
+			host:     "10.0.0.1",
+			accounts: "123-compute@developer.gserviceaccount.com/\ndefault/\n",
+			email:    "123-compute@developer.gserviceaccount.com\n",
+		},
+		{
+			name:     "custom node service account",
+			host:     "10.0.0.1",
+			accounts: "nodes@project.iam.gserviceaccount.com/\ndefault/\n",
+			email:    "nodes@project.iam.gserviceaccount.com\n",
+		},
+		{
+			name:     "compute default service account with an identity namespace",
+			host:     "10.0.0.1",
+			accounts: "default/\nproject.svc.id.goog/\n",
+			email:    "123-compute@developer.gserviceaccount.com\n",
+		},
+	}
+
+	// the test cases share the fake metadata server, they are not parallel
+	for _, tc := range testCases {
+		os.Setenv("KUBERNETES_SERVICE_HOST", tc.host)
+		accounts, email = tc.accounts, tc.email
+		got, ok := inClusterServiceAccount()
+		if got != tc.expected || ok != (tc.expected != "") {
+			t.Errorf("%s: expected the service account %q, got %q, %v", tc.name, tc.expected, got, ok)
+		}
+	}
+}
+
+func TestUseInClusterCredentialsWithKey(t *testing.T) {
+	defer func(host string) { os.Setenv("KUBERNETES_SERVICE_HOST", host) }(os.Getenv("KUBERNETES_SERVICE_HOST"))
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	d := &deployer{inClusterCredentials: true, gcpServiceAccount: "/etc/key.json"}
+	if inCluster, err := d.useInClusterCredentials(); inCluster || err != nil {
+		t.Errorf("expected --gcp-service-account to be activated, got %v, %v", inCluster, err)
+	}
+}
+
+func TestMissingInClusterRoles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		granted  map[string]bool
+		expected []string
+	}{
+		{
+			name:     "owner",
+			granted:  map[string]bool{"roles/owner": true},
+			expected: nil,
+		},
+		{
+			name:     "some roles",
+			granted:  map[string]bool{"roles/container.admin": true, "roles/compute.networkAdmin": true},
+			expected: []string{"roles/compute.securityAdmin", "roles/iam.serviceAccountUser"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			if diff := cmp.Diff(tc.expected, missingInClusterRoles(tc.granted)); diff != "" {
+				st.Errorf("missing roles differ (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// clusterManagementFee is the hourly GKE cluster management fee.
+const clusterManagementFee = 0.10
+
+// machineFamilyPrices are the approximate hourly on-demand prices per vCPU
+// and per GB of memory of the machine families in us-central1, used to
+// preview the cost of the clusters.
+// Reference: https://cloud.google.com/compute/vm-instance-pricing
+var machineFamilyPrices = map[string]struct{ vCPU, memoryGB float64 }{
+	"e2":  {0.021811, 0.002923},
+	"n1":  {0.031611, 0.004237},
+	"n2":  {0.031611, 0.004237},
+	"n2d": {0.027502, 0.003686},
+	"c2":  {0.03398, 0.00455},
+}
+
+// memoryPerVCPU returns the GB of memory per vCPU of the predefined machine
+// types of the family and class.
+func memoryPerVCPU(family, class string) (float64, bool) {
+	if family == "n1" {
+		switch class {
+		case "standard":
+			return 3.75, true
+		case "highmem":
+			return 6.5, true
+		case "highcpu":
+			return 0.9, true
+		}
+		return 0, false
+	}
+	switch class {
+	case "standard":
+		return 4, true
+	case "highmem":
+		return 8, true
+	case "highcpu":
+		return 1, true
+	}
+	return 0, false
+}
+
+// machineTypeHourlyCost estimates the hourly cost of a node of the predefined
+// machine type, e.g. e2-standard-4. It returns false for the machine types
+// it cannot estimate, such as the shared-core and custom ones.
+func machineTypeHourlyCost(machineType string) (float64, bool) {
+	parts := strings.Split(machineType, "-")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	prices, ok := machineFamilyPrices[parts[0]]
+	if !ok {
+		return 0, false
+	}
+	memory, ok := memoryPerVCPU(parts[0], parts[1])
+	if !ok {
+		return 0, false
+	}
+	vCPUs, ok := machineTypeVCPUs(machineType)
+	if !ok {
+		return 0, false
+	}
+	return float64(vCPUs) * (prices.vCPU + memory*prices.memoryGB), true
+}
+
+// printUpPlan prints the clusters about to be created and an estimate of
+// their hourly cost.
+func (d *deployer) printUpPlan(out io.Writer) {
+	location := d.region
+	// --num-nodes is the number of nodes in each zone of a regional cluster,
+	// which has 3 zones by default.
+	zones := 3
+	if d.zone != "" {
+		location = d.zone
+		zones = 1
+	}
+
+	cost := 0.0
+	estimated := true
+	addPool := func(machineType string, nodes int) {
+		nodeCost, ok := machineTypeHourlyCost(machineType)
+		if !ok {
+			estimated = false
+		}
+		cost += nodeCost * float64(nodes*zones)
+	}
+
+	fmt.Fprintln(out, "The following clusters will be created:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PROJECT\tCLUSTER\tLOCATION\tMACHINE TYPE\tIMAGE TYPE\tNODES")
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			cost += clusterManagementFee
+			if d.autopilot {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", project, cluster.name, location, "autopilot", "-", "-")
+				continue
+			}
+			// The fallback machine types are tried in order if the location
+			// runs out of capacity, the cost is estimated for the first one.
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%d\n", project, cluster.name, location, strings.Join(d.machineTypes(), ","), d.clusterImageType(cluster), d.nodes*zones)
+			addPool(d.machineType, d.nodes)
+			if d.windowsNodes > 0 {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%d\n", project, cluster.name+" (windows)", location, d.windowsMachineType, d.windowsImageType, d.windowsNodes*zones)
+				addPool(d.windowsMachineType, d.windowsNodes)
+			}
+			for _, pool := range d.clonedNodePools {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%d\n", project, cluster.name+" ("+pool.Name+")", location, pool.Config.MachineType, pool.Config.ImageType, pool.InitialNodeCount*zones)
+				addPool(pool.Config.MachineType, pool.InitialNodeCount)
+			}
+		}
+	}
+	w.Flush()
+	if d.cloneFromCluster != "" {
+		fmt.Fprintf(out, "Cloned from %s: version %q, release channel %q, addons %v\n", d.cloneFromCluster, d.Version, d.ReleaseChannel, d.addons())
+	}
+
+	note := "on-demand list prices in us-central1, excluding disks, network and licenses"
+	switch {
+	case d.autopilot:
+		note = "cluster management fees only, the pods are billed for their resource requests"
+	case !estimated:
+		note += ", and the machine types without a known price"
+	}
+	fmt.Fprintf(out, "Estimated cost: $%.2f/hour (%s)\n", cost, note)
+}
+
+// printDownPlan prints the clusters about to be deleted.
+func (d *deployer) printDownPlan(out io.Writer) {
+	location := d.region
+	if d.zone != "" {
+		location = d.zone
+	}
+	fmt.Fprintln(out, "The following clusters and their network resources will be deleted:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PROJECT\tCLUSTER\tLOCATION")
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", project, cluster.name, location)
+		}
+	}
+	w.Flush()
+}
+
+// confirm asks the question, and returns whether it was answered with yes.
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("error reading the answer: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// confirmUp prints the plan of the clusters and asks for the confirmation to
+// create them with --interactive.
+func (d *deployer) confirmUp() error {
+	if !d.interactive {
+		return nil
+	}
+	d.printUpPlan(os.Stdout)
+	ok, err := confirm(os.Stdin, os.Stdout, fmt.Sprintf("Create %d cluster(s)?", len(d.clusters)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		d.upDeclined = true
+		return fmt.Errorf("the creation of the clusters was not confirmed")
+	}
+	return nil
+}
+
+// confirmDown prints the clusters and asks for the confirmation to delete
+// them with --interactive.
+func (d *deployer) confirmDown() error {
+	if !d.interactive {
+		return nil
+	}
+	d.printDownPlan(os.Stdout)
+	ok, err := confirm(os.Stdin, os.Stdout, fmt.Sprintf("Delete %d cluster(s)?", len(d.clusters)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("the deletion of the clusters was not confirmed")
+	}
+	return nil
+}
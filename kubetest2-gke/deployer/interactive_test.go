@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMachineTypeHourlyCost(t *testing.T) {
+	testCases := []struct {
+		machineType string
+		expected    float64
+		expectOK    bool
+	}{
+		{machineType: "e2-standard-4", expected: 4 * (0.021811 + 4*0.002923), expectOK: true},
+		{machineType: "n1-standard-2", expected: 2 * (0.031611 + 3.75*0.004237), expectOK: true},
+		{machineType: "n2-highmem-8", expected: 8 * (0.031611 + 8*0.004237), expectOK: true},
+		{machineType: "e2-medium"},
+		{machineType: "n2-custom-4-16384"},
+		{machineType: "a2-highgpu-1g"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.machineType, func(st *testing.T) {
+			st.Parallel()
+			cost, ok := machineTypeHourlyCost(tc.machineType)
+			if ok != tc.expectOK {
+				st.Fatalf("expected ok=%v, got %v", tc.expectOK, ok)
+			}
+			if math.Abs(cost-tc.expected) > 1e-9 {
+				st.Errorf("expected cost %v, got %v", tc.expected, cost)
+			}
+		})
+	}
+}
+
+func TestPrintUpPlan(t *testing.T) {
+	d := &deployer{
+		projects:    []string{"p1"},
+		zone:        "us-central1-c",
+		machineType: "e2-standard-4",
+		imageType:   "cos_containerd",
+		nodes:       3,
+		projectClustersLayout: map[string][]cluster{
+			"p1": {{index: 0, name: "kt2-1"}, {index: 1, name: "kt2-2"}},
+		},
+	}
+	var out bytes.Buffer
+	d.printUpPlan(&out)
+	plan := out.String()
+	if !regexp.MustCompile(`kt2-2\s+us-central1-c\s+e2-standard-4\s+cos_containerd\s+3`).MatchString(plan) {
+		t.Errorf("expected the plan to list the clusters, got:\n%s", plan)
+	}
+	// 2 management fees and 6 e2-standard-4 nodes
+	if !strings.Contains(plan, "Estimated cost: $1.00/hour") {
+		t.Errorf("expected the plan to estimate the cost, got:\n%s", plan)
+	}
+
+	d.machineTypeFallbacks = []string{"n2-standard-4"}
+	out.Reset()
+	d.printUpPlan(&out)
+	if plan := out.String(); !regexp.MustCompile(`kt2-1\s+us-central1-c\s+e2-standard-4,n2-standard-4\s+`).MatchString(plan) {
+		t.Errorf("expected the plan to list the fallback machine types, got:\n%s", plan)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for answer, expected := range map[string]bool{"y\n": true, "Yes\n": true, "n\n": false, "\n": false, "": false} {
+		var out bytes.Buffer
+		ok, err := confirm(strings.NewReader(answer), &out, "Create 1 cluster(s)?")
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", answer, err)
+		}
+		if ok != expected {
+			t.Errorf("expected %v for %q, got %v", expected, answer, ok)
+		}
+	}
+}
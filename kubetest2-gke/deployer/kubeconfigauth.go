@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// the kubeconfig authenticates with the gcloud auth provider
+	kubeconfigAuthGcloud = "gcloud"
+	// the kubeconfig authenticates with the gke-gcloud-auth-plugin exec plugin
+	kubeconfigAuthExecPlugin = "exec-plugin"
+	// the kubeconfig authenticates with the long-lived token of a service account
+	kubeconfigAuthToken = "token"
+
+	tokenServiceAccountName = "kubetest2-admin"
+	tokenUserName           = "kubetest2-admin"
+)
+
+// The token of a kubernetes.io/service-account-token secret does not expire.
+const tokenManifest = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: ` + tokenServiceAccountName + `
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: ` + tokenServiceAccountName + `
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+- kind: ServiceAccount
+  name: ` + tokenServiceAccountName + `
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: ` + tokenServiceAccountName + `
+  namespace: kube-system
+  annotations:
+    kubernetes.io/service-account.name: ` + tokenServiceAccountName + `
+type: kubernetes.io/service-account-token
+`
+
+func (d *deployer) verifyKubeconfigAuthFlags() error {
+	switch d.kubeconfigAuth {
+	case "", kubeconfigAuthGcloud, kubeconfigAuthExecPlugin, kubeconfigAuthToken:
+		return nil
+	default:
+		return fmt.Errorf("--kubeconfig-auth must be one of %v", []string{"", kubeconfigAuthGcloud, kubeconfigAuthExecPlugin, kubeconfigAuthToken})
+	}
+}
+
+// setKubeconfigAuthEnv makes gcloud get-credentials write the auth config of
+// the selected mode.
+// Reference: https://cloud.google.com/blog/products/containers-kubernetes/kubectl-auth-changes-in-gke
+func (d *deployer) setKubeconfigAuthEnv() error {
+	switch d.kubeconfigAuth {
+	case kubeconfigAuthGcloud:
+		return os.Setenv("USE_GKE_GCLOUD_AUTH_PLUGIN", "False")
+	case kubeconfigAuthExecPlugin:
+		return os.Setenv("USE_GKE_GCLOUD_AUTH_PLUGIN", "True")
+	}
+	// The gcloud default is kept by default, and for the token mode which
+	// only needs working credentials to create the service account.
+	return nil
+}
+
+// useStaticToken creates a cluster-admin service account in the cluster, and
+// switches the kubeconfig to its long-lived token, so the kubeconfig can be
+// used without gcloud. The token is kept in a file next to the kubeconfig,
+// so that it is never in the output or the arguments of a command, which are
+// logged and teed into the artifacts.
+func useStaticToken(ctx context.Context, kubeconfig, clusterName string) error {
+	klog.V(1).Infof("Creating the service account %s in cluster %s for the kubeconfig token", tokenServiceAccountName, clusterName)
+	apply := kubectlCommand(kubeconfig, "apply", "-f", "-")
+	apply.SetStdin(strings.NewReader(tokenManifest))
+	if err := runWithOutput(apply); err != nil {
+		return fmt.Errorf("error creating the service account for the kubeconfig token: %w", err)
+	}
+
+	// The token is populated asynchronously by the token controller.
+	var token []byte
+	if err := poll(ctx, 2*time.Second, time.Minute, func() (bool, error) {
+		out, err := secretOutput(ctx, "kubectl", "--kubeconfig="+kubeconfig, "get", "secret", tokenServiceAccountName,
+			"--namespace=kube-system", "--output=jsonpath={.data.token}")
+		if err != nil || len(out) == 0 {
+			return false, nil
+		}
+		token, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+		return true, err
+	}); err != nil {
+		return fmt.Errorf("error getting the kubeconfig token: %w", err)
+	}
+
+	tokenFile := kubeconfig + "-token"
+	if err := ioutil.WriteFile(tokenFile, token, 0600); err != nil {
+		return fmt.Errorf("error writing the kubeconfig token: %w", err)
+	}
+	if err := runWithOutput(kubectlCommand(kubeconfig, "config", "set-credentials", tokenUserName)); err != nil {
+		return fmt.Errorf("error adding the kubeconfig token user: %w", err)
+	}
+	if err := runWithOutput(kubectlCommand(kubeconfig, "config", "set", "users."+tokenUserName+".tokenFile", tokenFile)); err != nil {
+		return fmt.Errorf("error setting the kubeconfig token: %w", err)
+	}
+	if err := runWithOutput(kubectlCommand(kubeconfig, "config", "set-context", "--current", "--user="+tokenUserName)); err != nil {
+		return fmt.Errorf("error switching the kubeconfig to the token: %w", err)
+	}
+	return nil
+}
+
+// deleteStaticTokens deletes the cluster-admin service accounts created for
+// the kubeconfig token, whose token never expires, from the clusters which
+// are kept. They are deleted with the credentials of gcloud, as the token
+// stops working half way through.
+func (d *deployer) deleteStaticTokens() error {
+	if d.kubeconfigAuth != kubeconfigAuthToken {
+		return nil
+	}
+	dir, err := ioutil.TempDir("", "kubetest2-gke-token")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	return d.forEachCluster(func(project string, cluster cluster) error {
+		kubeconfig := filepath.Join(dir, fmt.Sprintf("kubecfg-%s-%s", project, cluster.name))
+		if err := getClusterCredentialsInto(kubeconfig, project, locationFlag(d.region, d.zone), cluster.name, d.credentialsArgs()...); err != nil {
+			return err
+		}
+		klog.V(1).Infof("Deleting the service account %s of the kubeconfig token in cluster %s", tokenServiceAccountName, cluster.name)
+		del := kubectlCommand(kubeconfig, "delete", "-f", "-", "--ignore-not-found")
+		del.SetStdin(strings.NewReader(tokenManifest))
+		if err := runWithOutput(del); err != nil {
+			return fmt.Errorf("error deleting the service account of the kubeconfig token: %w", err)
+		}
+		return nil
+	})
+}
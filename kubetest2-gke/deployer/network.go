@@ -17,9 +17,11 @@ limitations under the License.
 package deployer
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"strings"
 
@@ -43,7 +45,15 @@ func (d *deployer) verifyNetworkFlags() error {
 		d.privateClusterAccessLevel != string(limited) && d.privateClusterAccessLevel != string(unrestricted) {
 		return fmt.Errorf("--private-cluster-access-level must be one of %v", []string{"", string(no), string(limited), string(unrestricted)})
 	}
-	if d.privateClusterAccessLevel != "" && len(d.clusters) != len(d.privateClusterMasterIPRanges) {
+	if d.privateClusterMasterIPSupernet != "" {
+		if _, err := masterIPRangeCandidates(d.privateClusterMasterIPSupernet); err != nil {
+			return err
+		}
+		// The master IP ranges are only allocated for private clusters.
+		if d.privateClusterAccessLevel != "" {
+			klog.V(0).Infof("--private-cluster-master-ip-supernet specified, ignoring --private-cluster-master-ip-range")
+		}
+	} else if d.privateClusterAccessLevel != "" && len(d.clusters) != len(d.privateClusterMasterIPRanges) {
 		return fmt.Errorf("--private-cluster-master-ip-range must have the same length as the number of clusters when requesting private cluster(s)")
 	}
 
@@ -51,6 +61,9 @@ func (d *deployer) verifyNetworkFlags() error {
 	if numProjects == 0 {
 		numProjects = d.boskosProjectsRequested
 	}
+	if err := verifyClusterIPFlags(numProjects, len(d.clusters), d.clusterIPv4CIDR, d.servicesIPv4CIDR, d.clusterSecondaryRangeName, d.servicesSecondaryRangeName); err != nil {
+		return err
+	}
 	// For single project, no other verification is needed.
 	if numProjects == 1 {
 		return nil
@@ -76,6 +89,82 @@ func (d *deployer) verifyNetworkFlags() error {
 	return nil
 }
 
+// verifyClusterIPFlags validates the flags for the pod and service IP ranges.
+func verifyClusterIPFlags(numProjects, numClusters int, clusterCIDR, servicesCIDR, clusterRangeName, servicesRangeName string) error {
+	if clusterCIDR != "" && clusterRangeName != "" {
+		return errors.New("--cluster-ipv4-cidr and --cluster-secondary-range-name cannot both be set")
+	}
+	if servicesCIDR != "" && servicesRangeName != "" {
+		return errors.New("--services-ipv4-cidr and --services-secondary-range-name cannot both be set")
+	}
+	// For multi-project profile, the secondary ranges are created along with
+	// the subnetworks from --subnetwork-ranges.
+	if numProjects > 1 && (clusterCIDR != "" || servicesCIDR != "" || clusterRangeName != "" || servicesRangeName != "") {
+		return errors.New("the cluster IP ranges are configured by --subnetwork-ranges for multi-project profile")
+	}
+	// The clusters share the network, so they cannot all use the same ranges.
+	if numClusters > 1 && (clusterCIDR != "" || servicesCIDR != "" || clusterRangeName != "" || servicesRangeName != "") {
+		return errors.New("the cluster IP ranges can only be set when creating a single cluster, as the clusters share the network")
+	}
+
+	var nets []*net.IPNet
+	for flag, cidr := range map[string]string{"--cluster-ipv4-cidr": clusterCIDR, "--services-ipv4-cidr": servicesCIDR} {
+		if cidr == "" {
+			continue
+		}
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("%s must be in CIDR notation: %w", flag, err)
+		}
+		if ip.To4() == nil {
+			return fmt.Errorf("%s must be an IPv4 range, got %q", flag, cidr)
+		}
+		nets = append(nets, ipNet)
+	}
+	if len(nets) == 2 && (nets[0].Contains(nets[1].IP) || nets[1].Contains(nets[0].IP)) {
+		return fmt.Errorf("--cluster-ipv4-cidr %q and --services-ipv4-cidr %q must not overlap", clusterCIDR, servicesCIDR)
+	}
+	return nil
+}
+
+// clusterIPArgs returns the args for the pod and service IP ranges needed for
+// the cluster creation command.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/alias-ips
+func clusterIPArgs(clusterCIDR, servicesCIDR, clusterRangeName, servicesRangeName string) []string {
+	args := []string{}
+	if clusterCIDR != "" {
+		args = append(args, "--cluster-ipv4-cidr="+clusterCIDR)
+	}
+	if servicesCIDR != "" {
+		args = append(args, "--services-ipv4-cidr="+servicesCIDR)
+	}
+	if clusterRangeName != "" {
+		args = append(args, "--cluster-secondary-range-name="+clusterRangeName)
+	}
+	if servicesRangeName != "" {
+		args = append(args, "--services-secondary-range-name="+servicesRangeName)
+	}
+	return args
+}
+
+// ipAliasArgs returns the args making the cluster of the project VPC-native,
+// which the shared VPC subnetworks, the services and secondary ranges, the
+// private clusters, the subnetworks of the scale runs and the Windows node
+// pools require.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/alias-ips
+func (d *deployer) ipAliasArgs(projectIndex int) []string {
+	// GKE in Autopilot mode does not support --enable-ip-alias flag as it's always VPC-native.
+	// https://cloud.google.com/sdk/gcloud/reference/container/clusters/create-auto
+	if d.autopilot {
+		return []string{}
+	}
+	if projectIndex > 0 || d.servicesIPv4CIDR != "" || d.clusterSecondaryRangeName != "" || d.servicesSecondaryRangeName != "" ||
+		d.privateClusterAccessLevel != "" || d.scale || d.windowsNodes > 0 {
+		return []string{"--enable-ip-alias"}
+	}
+	return []string{}
+}
+
 func (d *deployer) createNetwork() error {
 	// Create network if it doesn't exist.
 	// For single project profile, the subnet-mode could be auto for simplicity.
@@ -177,7 +266,7 @@ func transformNetworkName(projects []string, network string) string {
 
 // Returns the sub network args needed for the cluster creation command.
 // Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#creating_a_cluster_in_your_first_service_project
-func subNetworkArgs(autopilot bool, projects []string, region, network string, projectIndex int) []string {
+func subNetworkArgs(projects []string, region, network string, projectIndex int) []string {
 	// No sub network args need to be added for creating clusters in the host project.
 	if projectIndex == 0 {
 		return []string{}
@@ -187,16 +276,11 @@ func subNetworkArgs(autopilot bool, projects []string, region, network string, p
 	hostProject := projects[0]
 	curtProject := projects[projectIndex]
 	subnetName := network + "-" + curtProject
-	args := []string{
+	return []string{
 		fmt.Sprintf("--subnetwork=projects/%s/regions/%s/subnetworks/%s", hostProject, region, subnetName),
 		fmt.Sprintf("--cluster-secondary-range-name=%s-pods", subnetName),
 		fmt.Sprintf("--services-secondary-range-name=%s-services", subnetName),
 	}
-	// GKE in Autopilot mode does not support --enable-ip-alias flag - https://cloud.google.com/sdk/gcloud/reference/container/clusters/create-auto
-	if !autopilot {
-		args = append(args, "--enable-ip-alias")
-	}
-	return args
 }
 
 func (d *deployer) setupNetwork() error {
@@ -372,6 +456,112 @@ func removeHostServiceAgentUserRole(projects []string) error {
 	return nil
 }
 
+// masterIPRangeSize is the prefix length of the private cluster master IP
+// ranges required by GKE.
+const masterIPRangeSize = 28
+
+// masterIPRangeCandidates returns all the /28 ranges in the supernet.
+func masterIPRangeCandidates(supernet string) ([]*net.IPNet, error) {
+	ip, ipNet, err := net.ParseCIDR(supernet)
+	if err != nil {
+		return nil, fmt.Errorf("--private-cluster-master-ip-supernet must be in CIDR notation: %w", err)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("--private-cluster-master-ip-supernet must be an IPv4 range, got %q", supernet)
+	}
+	ones, _ := ipNet.Mask.Size()
+	if ones > masterIPRangeSize {
+		return nil, fmt.Errorf("--private-cluster-master-ip-supernet must be at least a /%d range, got %q", masterIPRangeSize, supernet)
+	}
+
+	base := binary.BigEndian.Uint32(ipNet.IP.To4())
+	count := uint32(1) << uint(masterIPRangeSize-ones)
+	candidates := make([]*net.IPNet, 0, count)
+	for i := uint32(0); i < count; i++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, base+i<<(32-masterIPRangeSize))
+		candidates = append(candidates, &net.IPNet{IP: ip, Mask: net.CIDRMask(masterIPRangeSize, 32)})
+	}
+	return candidates, nil
+}
+
+// allocateMasterIPRanges returns n /28 ranges in the supernet that do not
+// overlap with the used ranges.
+func allocateMasterIPRanges(supernet string, n int, used []string) ([]string, error) {
+	candidates, err := masterIPRangeCandidates(supernet)
+	if err != nil {
+		return nil, err
+	}
+	var usedNets []*net.IPNet
+	for _, u := range used {
+		_, usedNet, err := net.ParseCIDR(u)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the used master IP range %q: %w", u, err)
+		}
+		usedNets = append(usedNets, usedNet)
+	}
+
+	ranges := make([]string, 0, n)
+	for _, candidate := range candidates {
+		if len(ranges) == n {
+			break
+		}
+		overlaps := false
+		for _, usedNet := range usedNets {
+			if usedNet.Contains(candidate.IP) || candidate.Contains(usedNet.IP) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			ranges = append(ranges, candidate.String())
+		}
+	}
+	if len(ranges) < n {
+		return nil, fmt.Errorf("only %d of the %d master IP ranges required could be allocated from %q", len(ranges), n, supernet)
+	}
+	return ranges, nil
+}
+
+// allocatePrivateClusterMasterIPRanges allocates the master IP ranges of the
+// private clusters from --private-cluster-master-ip-supernet, avoiding the
+// ranges of the existing private clusters in the projects, and records them
+// in the metadata.
+func (d *deployer) allocatePrivateClusterMasterIPRanges() error {
+	if d.privateClusterAccessLevel == "" || d.privateClusterMasterIPSupernet == "" {
+		return nil
+	}
+
+	var used []string
+	for _, project := range d.projects {
+		var clusters []gkeCluster
+		if err := gcloudJSON(&clusters, containerArgs("clusters", "list", "--project="+project)...); err != nil {
+			return fmt.Errorf("error listing the existing clusters in project %s: %s", project, execError(err))
+		}
+		for _, c := range clusters {
+			if block := c.PrivateClusterConfig.MasterIpv4CidrBlock; block != "" {
+				used = append(used, block)
+			}
+		}
+	}
+
+	ranges, err := allocateMasterIPRanges(d.privateClusterMasterIPSupernet, len(d.clusters), used)
+	if err != nil {
+		return err
+	}
+	d.privateClusterMasterIPRanges = ranges
+
+	allocated := make(map[string]string)
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			allocated[cluster.name] = ranges[cluster.index]
+		}
+	}
+	klog.V(1).Infof("Allocated private cluster master IP ranges: %v", allocated)
+	d.metadata.Add("private-cluster-master-ip-ranges", allocated)
+	return nil
+}
+
 // This function returns the args required for creating a private cluster.
 // Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/private-clusters#top_of_page
 func privateClusterArgs(projects []string, network, accessLevel string, masterIPRanges []string, clusterInfo cluster) []string {
@@ -380,7 +570,6 @@ func privateClusterArgs(projects []string, network, accessLevel string, masterIP
 	}
 
 	common := []string{
-		"--enable-ip-alias",
 		"--enable-private-nodes",
 		"--no-enable-basic-auth",
 		"--master-ipv4-cidr=" + masterIPRanges[clusterInfo.index],
@@ -58,7 +58,6 @@ func TestPrivateClusterArgs(t *testing.T) {
 			masterIPRanges: []string{"172.16.0.32/28"},
 			clusterInfo:    cluster{index: 0, name: "cluster1"},
 			expected: []string{
-				"--enable-ip-alias",
 				"--enable-private-nodes",
 				"--no-enable-basic-auth",
 				"--master-ipv4-cidr=172.16.0.32/28",
@@ -76,7 +75,6 @@ func TestPrivateClusterArgs(t *testing.T) {
 			masterIPRanges: []string{"173.16.0.32/28"},
 			clusterInfo:    cluster{index: 0, name: "cluster2"},
 			expected: []string{
-				"--enable-ip-alias",
 				"--enable-private-nodes",
 				"--no-enable-basic-auth",
 				"--master-ipv4-cidr=173.16.0.32/28",
@@ -93,7 +91,6 @@ func TestPrivateClusterArgs(t *testing.T) {
 			masterIPRanges: []string{"173.16.0.32/28", "175.16.0.32/22"},
 			clusterInfo:    cluster{index: 1, name: "cluster3"},
 			expected: []string{
-				"--enable-ip-alias",
 				"--enable-private-nodes",
 				"--no-enable-basic-auth",
 				"--master-ipv4-cidr=175.16.0.32/22",
@@ -110,7 +107,6 @@ func TestPrivateClusterArgs(t *testing.T) {
 			masterIPRanges: []string{"173.16.0.32/28", "175.16.0.32/22"},
 			clusterInfo:    cluster{index: 1, name: "cluster3"},
 			expected: []string{
-				"--enable-ip-alias",
 				"--enable-private-nodes",
 				"--no-enable-basic-auth",
 				"--master-ipv4-cidr=175.16.0.32/22",
@@ -131,3 +127,258 @@ func TestPrivateClusterArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyClusterIPFlags(t *testing.T) {
+	testCases := []struct {
+		desc              string
+		numProjects       int
+		numClusters       int
+		clusterCIDR       string
+		servicesCIDR      string
+		clusterRangeName  string
+		servicesRangeName string
+		expectError       bool
+	}{
+		{
+			desc:        "no flags are set",
+			numProjects: 1,
+		},
+		{
+			desc:         "valid pod and service ranges",
+			numProjects:  1,
+			clusterCIDR:  "10.0.0.0/14",
+			servicesCIDR: "10.4.0.0/19",
+		},
+		{
+			desc:              "valid secondary range names",
+			numProjects:       1,
+			clusterRangeName:  "pods",
+			servicesRangeName: "services",
+		},
+		{
+			desc:        "invalid CIDR",
+			numProjects: 1,
+			clusterCIDR: "10.0.0.0",
+			expectError: true,
+		},
+		{
+			desc:         "IPv6 CIDR",
+			numProjects:  1,
+			servicesCIDR: "fd00::/108",
+			expectError:  true,
+		},
+		{
+			desc:         "overlapping ranges",
+			numProjects:  1,
+			clusterCIDR:  "10.0.0.0/14",
+			servicesCIDR: "10.2.0.0/20",
+			expectError:  true,
+		},
+		{
+			desc:             "CIDR and secondary range name are both set",
+			numProjects:      1,
+			clusterCIDR:      "10.0.0.0/14",
+			clusterRangeName: "pods",
+			expectError:      true,
+		},
+		{
+			desc:        "ranges are set for multi-project profile",
+			numProjects: 2,
+			clusterCIDR: "10.0.0.0/14",
+			expectError: true,
+		},
+		{
+			desc:         "ranges are set for multiple clusters",
+			numProjects:  1,
+			numClusters:  2,
+			servicesCIDR: "10.4.0.0/19",
+			expectError:  true,
+		},
+		{
+			desc:             "secondary range names are set for multiple clusters",
+			numProjects:      1,
+			numClusters:      2,
+			clusterRangeName: "pods",
+			expectError:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(st *testing.T) {
+			st.Parallel()
+			err := verifyClusterIPFlags(tc.numProjects, tc.numClusters, tc.clusterCIDR, tc.servicesCIDR, tc.clusterRangeName, tc.servicesRangeName)
+			if tc.expectError && err == nil {
+				st.Error("expected an error but got none")
+			} else if !tc.expectError && err != nil {
+				st.Error("unexpected error", err)
+			}
+		})
+	}
+}
+
+func TestClusterIPArgs(t *testing.T) {
+	testCases := []struct {
+		desc              string
+		clusterCIDR       string
+		servicesCIDR      string
+		clusterRangeName  string
+		servicesRangeName string
+		expected          []string
+	}{
+		{
+			desc:     "no args are needed if no ranges are set",
+			expected: []string{},
+		},
+		{
+			desc:        "only the pod range is set",
+			clusterCIDR: "10.0.0.0/14",
+			expected:    []string{"--cluster-ipv4-cidr=10.0.0.0/14"},
+		},
+		{
+			desc:         "pod and service ranges",
+			clusterCIDR:  "10.0.0.0/14",
+			servicesCIDR: "10.4.0.0/19",
+			expected:     []string{"--cluster-ipv4-cidr=10.0.0.0/14", "--services-ipv4-cidr=10.4.0.0/19"},
+		},
+		{
+			desc:              "secondary range names",
+			clusterRangeName:  "pods",
+			servicesRangeName: "services",
+			expected:          []string{"--cluster-secondary-range-name=pods", "--services-secondary-range-name=services"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(st *testing.T) {
+			st.Parallel()
+			actual := clusterIPArgs(tc.clusterCIDR, tc.servicesCIDR, tc.clusterRangeName, tc.servicesRangeName)
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				st.Error("Got cluster IP args (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestIPAliasArgs(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		d            *deployer
+		projectIndex int
+		expected     []string
+	}{
+		{
+			desc:     "routes-based clusters are kept by default",
+			d:        &deployer{},
+			expected: []string{},
+		},
+		{
+			desc:         "shared VPC subnetwork of a service project",
+			d:            &deployer{},
+			projectIndex: 1,
+			expected:     []string{"--enable-ip-alias"},
+		},
+		{
+			desc:     "services range",
+			d:        &deployer{servicesIPv4CIDR: "10.4.0.0/19"},
+			expected: []string{"--enable-ip-alias"},
+		},
+		{
+			desc:     "private cluster with Windows nodes",
+			d:        &deployer{privateClusterAccessLevel: string(no), windowsNodes: 1},
+			expected: []string{"--enable-ip-alias"},
+		},
+		{
+			desc:     "scale runs",
+			d:        &deployer{scale: true},
+			expected: []string{"--enable-ip-alias"},
+		},
+		{
+			desc:         "autopilot clusters are always VPC-native",
+			d:            &deployer{autopilot: true, clusterSecondaryRangeName: "pods"},
+			projectIndex: 1,
+			expected:     []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(st *testing.T) {
+			st.Parallel()
+			if diff := cmp.Diff(tc.expected, tc.d.ipAliasArgs(tc.projectIndex)); diff != "" {
+				st.Error("Got IP alias args (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestAllocateMasterIPRanges(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		supernet    string
+		n           int
+		used        []string
+		expected    []string
+		expectError bool
+	}{
+		{
+			desc:     "allocate consecutive ranges",
+			supernet: "172.16.0.0/24",
+			n:        3,
+			expected: []string{"172.16.0.0/28", "172.16.0.16/28", "172.16.0.32/28"},
+		},
+		{
+			desc:     "skip the used ranges",
+			supernet: "172.16.0.0/24",
+			n:        2,
+			used:     []string{"172.16.0.0/28", "172.16.0.32/27"},
+			expected: []string{"172.16.0.16/28", "172.16.0.64/28"},
+		},
+		{
+			desc:     "the supernet is a single range",
+			supernet: "10.0.0.16/28",
+			n:        1,
+			expected: []string{"10.0.0.16/28"},
+		},
+		{
+			desc:        "not enough free ranges",
+			supernet:    "172.16.0.0/27",
+			n:           2,
+			used:        []string{"172.16.0.0/28"},
+			expectError: true,
+		},
+		{
+			desc:        "the supernet is smaller than a /28",
+			supernet:    "172.16.0.0/29",
+			n:           1,
+			expectError: true,
+		},
+		{
+			desc:        "the supernet is not an IPv4 range",
+			supernet:    "fd00::/64",
+			n:           1,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(st *testing.T) {
+			st.Parallel()
+			actual, err := allocateMasterIPRanges(tc.supernet, tc.n, tc.used)
+			if tc.expectError {
+				if err == nil {
+					st.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				st.Fatal("unexpected error", err)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				st.Error("Got master IP ranges (-want, +got) =", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	notificationsFile         = "notifications.jsonl"
+	notificationsDrainBackoff = 30 * time.Second
+)
+
+// notificationsTopic returns the name of the per-run Pub/Sub topic the
+// cluster notifications are published to.
+func (d *deployer) notificationsTopic() string {
+	return runResourceName(d.commonOptions.RunID(), "notifications")
+}
+
+func (d *deployer) notificationsSubscription() string {
+	return d.notificationsTopic() + "-sub"
+}
+
+// notificationConfigArgs returns the args for enabling cluster notifications
+// in the cluster creation command.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-notifications
+func (d *deployer) notificationConfigArgs(project string) []string {
+	if !d.clusterNotifications {
+		return []string{}
+	}
+	return []string{fmt.Sprintf("--notification-config=pubsub=ENABLED,pubsub-topic=projects/%s/topics/%s", project, d.notificationsTopic())}
+}
+
+// createNotificationsTopics creates the Pub/Sub topic and subscription the
+// cluster notifications are received from in each project, and starts
+// draining them into the artifacts.
+func (d *deployer) createNotificationsTopics() error {
+	if !d.clusterNotifications {
+		return nil
+	}
+	for _, project := range d.projects {
+		if err := runWithOutput(exec.Command("gcloud", "pubsub", "topics", "create", d.notificationsTopic(),
+			"--project="+project)); err != nil {
+			return fmt.Errorf("error creating the cluster notifications topic in project %s: %w", project, err)
+		}
+		if err := runWithOutput(exec.Command("gcloud", "pubsub", "subscriptions", "create", d.notificationsSubscription(),
+			"--project="+project,
+			"--topic="+d.notificationsTopic())); err != nil {
+			return fmt.Errorf("error creating the cluster notifications subscription in project %s: %w", project, err)
+		}
+	}
+
+	d.notificationsClose = make(chan struct{})
+	d.notificationsDrained.Add(1)
+	go func() {
+		defer d.notificationsDrained.Done()
+		for {
+			select {
+			case <-d.notificationsClose:
+				return
+			case <-time.After(notificationsDrainBackoff):
+				if err := d.drainNotifications(); err != nil {
+					klog.Warningf("Error draining the cluster notifications: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// deleteNotificationsTopics stops the background draining, drains the
+// remaining notifications and deletes the topics and subscriptions.
+func (d *deployer) deleteNotificationsTopics() error {
+	if !d.clusterNotifications {
+		return nil
+	}
+	if d.notificationsClose != nil {
+		close(d.notificationsClose)
+		d.notificationsDrained.Wait()
+		d.notificationsClose = nil
+	}
+	if err := d.drainNotifications(); err != nil {
+		klog.Warningf("Error draining the cluster notifications: %v", err)
+	}
+	for _, project := range d.projects {
+		if err := runWithOutput(exec.Command("gcloud", "pubsub", "subscriptions", "delete", d.notificationsSubscription(),
+			"--project="+project, "--quiet")); err != nil {
+			return fmt.Errorf("error deleting the cluster notifications subscription in project %s: %w", project, err)
+		}
+		if err := runWithOutput(exec.Command("gcloud", "pubsub", "topics", "delete", d.notificationsTopic(),
+			"--project="+project, "--quiet")); err != nil {
+			return fmt.Errorf("error deleting the cluster notifications topic in project %s: %w", project, err)
+		}
+	}
+	return nil
+}
+
+type pubsubMessage struct {
+	Message struct {
+		Attributes  map[string]string `json:"attributes"`
+		Data        string            `json:"data"`
+		MessageID   string            `json:"messageId"`
+		PublishTime string            `json:"publishTime"`
+	} `json:"message"`
+}
+
+type notification struct {
+	Project     string            `json:"project"`
+	MessageID   string            `json:"messageId"`
+	PublishTime string            `json:"publishTime"`
+	Attributes  map[string]string `json:"attributes"`
+	Data        string            `json:"data"`
+}
+
+// drainNotifications pulls the pending cluster notifications of all the
+// projects and appends them to notifications.jsonl in the artifacts.
+func (d *deployer) drainNotifications() error {
+	d.notificationsLock.Lock()
+	defer d.notificationsLock.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(d.commonOptions.RunDir(), notificationsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, project := range d.projects {
+		for {
+			out, err := exec.Output(exec.Command("gcloud", "pubsub", "subscriptions", "pull", d.notificationsSubscription(),
+				"--project="+project,
+				"--auto-ack",
+				"--limit=100",
+				"--format=json"))
+			if err != nil {
+				return fmt.Errorf("error pulling the cluster notifications in project %s: %s", project, execError(err))
+			}
+			var messages []pubsubMessage
+			if err := json.Unmarshal(out, &messages); err != nil {
+				return fmt.Errorf("error parsing the cluster notifications: %w", err)
+			}
+			if len(messages) == 0 {
+				break
+			}
+			for _, m := range messages {
+				// The notification payload is a human readable description of the event.
+				data, err := base64.StdEncoding.DecodeString(m.Message.Data)
+				if err != nil {
+					data = []byte(m.Message.Data)
+				}
+				if err := encoder.Encode(notification{
+					Project:     project,
+					MessageID:   m.Message.MessageID,
+					PublishTime: m.Message.PublishTime,
+					Attributes:  m.Message.Attributes,
+					Data:        string(data),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// operationTracker tracks the asynchronous cleanup operations, so that the
+// next step only begins once they are finished, and their failures are
+// reported together instead of being lost in a bare goroutine.
+type operationTracker struct {
+	wg   sync.WaitGroup
+	lock sync.Mutex
+	errs []string
+}
+
+// start runs the operation in the background. The context passed to it is
+// cancelled after the timeout, or when ctx is done.
+func (t *operationTracker) start(ctx context.Context, name string, timeout time.Duration, op func(ctx context.Context) error) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		opCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		klog.V(1).Infof("Starting operation %q", name)
+		err := op(opCtx)
+		if err == nil && opCtx.Err() != nil {
+			err = opCtx.Err()
+		}
+		if err != nil {
+			if opCtx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %v: %w", timeout, err)
+			}
+			klog.Errorf("Operation %q failed: %v", name, err)
+			t.lock.Lock()
+			t.errs = append(t.errs, fmt.Sprintf("%s: %v", name, err))
+			t.lock.Unlock()
+			return
+		}
+		klog.V(1).Infof("Operation %q finished", name)
+	}()
+}
+
+// wait waits for all the started operations to finish, and returns an error
+// listing the failed ones, if any.
+func (t *operationTracker) wait() error {
+	t.wg.Wait()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.errs) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%d operation(s) failed: %s", len(t.errs), strings.Join(t.errs, "; "))
+	t.errs = nil
+	return err
+}
+
+// poll calls condition every interval until it returns true or an error, or
+// until the timeout passes or ctx is done.
+func poll(ctx context.Context, interval, timeout time.Duration, condition func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("timed out after %v", timeout)
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
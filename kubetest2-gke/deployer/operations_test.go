@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOperationTracker(t *testing.T) {
+	var tracker operationTracker
+	tracker.start(context.Background(), "succeeds", time.Minute, func(ctx context.Context) error {
+		return nil
+	})
+	tracker.start(context.Background(), "fails", time.Minute, func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	tracker.start(context.Background(), "times out", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	err := tracker.wait()
+	if err == nil {
+		t.Fatal("expected an error but got none")
+	}
+	for _, expected := range []string{"2 operation(s) failed", "fails: boom", "times out: timed out after 10ms"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q to contain %q", err, expected)
+		}
+	}
+	if strings.Contains(err.Error(), "succeeds") {
+		t.Errorf("expected error %q to not contain the succeeded operation", err)
+	}
+
+	if err := tracker.wait(); err != nil {
+		t.Error("expected the errors to be reset after wait, but got", err)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	calls := 0
+	if err := poll(context.Background(), time.Millisecond, time.Minute, func() (bool, error) {
+		calls++
+		return calls == 3, nil
+	}); err != nil || calls != 3 {
+		t.Errorf("expected to poll until the condition is met, got %d calls, %v", calls, err)
+	}
+
+	if err := poll(context.Background(), time.Millisecond, time.Minute, func() (bool, error) {
+		return false, errors.New("boom")
+	}); err == nil || err.Error() != "boom" {
+		t.Errorf("expected the error of the condition, got %v", err)
+	}
+
+	if err := poll(context.Background(), time.Millisecond, 10*time.Millisecond, func() (bool, error) {
+		return false, nil
+	}); err == nil || err.Error() != "timed out after 10ms" {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := poll(ctx, time.Millisecond, time.Minute, func() (bool, error) {
+		return false, nil
+	}); err != context.Canceled {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
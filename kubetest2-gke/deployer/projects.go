@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const projectReadinessFile = "project-readiness.json"
+
+// projectReadiness is the result of preparing a single GCP project.
+type projectReadiness struct {
+	Project string `json:"project"`
+	Ready   bool   `json:"ready"`
+	// EnabledServices are the services enabled by the preparation.
+	EnabledServices []string `json:"enabledServices,omitempty"`
+	Duration        string   `json:"duration"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// requiredServices returns the GCP services the projects need for the flags
+// of this run.
+func (d *deployer) requiredServices() []string {
+	services := []string{"compute.googleapis.com", "container.googleapis.com"}
+	if len(d.artifactRegistryMirrors) > 0 {
+		services = append(services, "artifactregistry.googleapis.com")
+	}
+	if d.clusterNotifications {
+		services = append(services, "pubsub.googleapis.com")
+	}
+	if d.backupBeforeTest {
+		services = append(services, "gkebackup.googleapis.com")
+	}
+	return services
+}
+
+// prepareProjects concurrently checks that all the projects are accessible
+// and enables the required services that are not enabled yet, then writes a
+// readiness report into the artifacts. The projects are only prepared once.
+func (d *deployer) prepareProjects() error {
+	if d.projectsPrepared {
+		return nil
+	}
+	required := d.requiredServices()
+	results := make([]projectReadiness, len(d.projects))
+	var wg sync.WaitGroup
+	for i := range d.projects {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			enabled, err := prepareProject(d.projects[i], required)
+			results[i] = projectReadiness{
+				Project:         d.projects[i],
+				Ready:           err == nil,
+				EnabledServices: enabled,
+				Duration:        time.Since(start).Round(time.Second).String(),
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var errs []string
+	for _, r := range results {
+		if r.Ready {
+			klog.V(1).Infof("Project %s is ready (took %s, enabled services: %v)", r.Project, r.Duration, r.EnabledServices)
+		} else {
+			klog.Errorf("Project %s is not ready: %s", r.Project, r.Error)
+			errs = append(errs, fmt.Sprintf("%s: %s", r.Project, r.Error))
+		}
+	}
+
+	report, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(d.commonOptions.RunDir(), projectReadinessFile), report, 0644); err != nil {
+		klog.Warningf("Failed to write the project readiness report: %v", err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to prepare the GCP projects: %s", strings.Join(errs, "; "))
+	}
+	d.projectsPrepared = true
+	return nil
+}
+
+// prepareProject checks the project is active and accessible with the
+// current credentials, and enables the required services missing in it.
+// It returns the services that were enabled.
+func prepareProject(project string, required []string) ([]string, error) {
+	var p struct {
+		LifecycleState string `json:"lifecycleState"`
+	}
+	if err := gcloudJSON(&p, "projects", "describe", project); err != nil {
+		return nil, fmt.Errorf("error describing the project: %s", execError(err))
+	}
+	if p.LifecycleState != "ACTIVE" {
+		return nil, fmt.Errorf("the project is in %s state", p.LifecycleState)
+	}
+
+	var services []struct {
+		Config struct {
+			Name string `json:"name"`
+		} `json:"config"`
+	}
+	if err := gcloudJSON(&services, "services", "list", "--enabled",
+		"--project="+project); err != nil {
+		return nil, fmt.Errorf("error listing the enabled services: %s", execError(err))
+	}
+	enabled := make(map[string]bool, len(services))
+	for _, service := range services {
+		enabled[service.Config.Name] = true
+	}
+	missing := make([]string, 0)
+	for _, service := range required {
+		if !enabled[service] {
+			missing = append(missing, service)
+		}
+	}
+	if len(missing) == 0 {
+		return missing, nil
+	}
+	sort.Strings(missing)
+
+	klog.V(1).Infof("Enabling services %v in project %s", missing, project)
+	if err := runWithOutput(exec.Command("gcloud", append([]string{"services", "enable", "--project=" + project}, missing...)...)); err != nil {
+		return nil, fmt.Errorf("error enabling services %v: %w", missing, err)
+	}
+	return missing, nil
+}
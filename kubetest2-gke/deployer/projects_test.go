@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// fakeCmder fakes the commands with the output returned by run for their
+// name and args, and records them.
+type fakeCmder struct {
+	run      func(args []string) (string, error)
+	lock     sync.Mutex
+	commands []string
+}
+
+func (f *fakeCmder) Command(name string, args ...string) exec.Cmd {
+	return f.CommandContext(context.Background(), name, args...)
+}
+
+func (f *fakeCmder) CommandContext(_ context.Context, name string, args ...string) exec.Cmd {
+	return &fakeCmd{cmder: f, args: append([]string{name}, args...)}
+}
+
+// ran returns the commands run, as space separated strings
+func (f *fakeCmder) ran() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string{}, f.commands...)
+}
+
+// useFakeCmder makes the commands run by the deployer use the fake until the
+// returned func is called
+func useFakeCmder(run func(args []string) (string, error)) (*fakeCmder, func()) {
+	f := &fakeCmder{run: run}
+	cmder := exec.DefaultCmder
+	exec.DefaultCmder = f
+	return f, func() { exec.DefaultCmder = cmder }
+}
+
+type fakeCmd struct {
+	cmder  *fakeCmder
+	args   []string
+	stdout io.Writer
+}
+
+func (c *fakeCmd) Run() error {
+	c.cmder.lock.Lock()
+	c.cmder.commands = append(c.cmder.commands, strings.Join(c.args, " "))
+	c.cmder.lock.Unlock()
+	out, err := c.cmder.run(c.args)
+	if c.stdout != nil {
+		io.WriteString(c.stdout, out)
+	}
+	return err
+}
+
+func (c *fakeCmd) SetEnv(...string) exec.Cmd      { return c }
+func (c *fakeCmd) SetStdin(io.Reader) exec.Cmd    { return c }
+func (c *fakeCmd) SetStdout(w io.Writer) exec.Cmd { c.stdout = w; return c }
+func (c *fakeCmd) SetStderr(io.Writer) exec.Cmd   { return c }
+func (c *fakeCmd) SetDir(string) exec.Cmd         { return c }
+
+func TestRequiredServices(t *testing.T) {
+	d := &deployer{clusterNotifications: true}
+	expected := []string{"compute.googleapis.com", "container.googleapis.com", "pubsub.googleapis.com"}
+	if diff := cmp.Diff(expected, d.requiredServices()); diff != "" {
+		t.Errorf("services differ (-want, +got):\n%s", diff)
+	}
+}
+
+func TestPrepareProject(t *testing.T) {
+	testCases := []struct {
+		name             string
+		project          string
+		services         string
+		describeErr      error
+		expectedEnabled  []string
+		expectedCommands int
+		expectErr        bool
+	}{
+		{
+			name:             "all the services are enabled",
+			project:          `{"projectId": "p", "lifecycleState": "ACTIVE"}`,
+			services:         `[{"config": {"name": "compute.googleapis.com"}}, {"config": {"name": "container.googleapis.com"}}]`,
+			expectedEnabled:  []string{},
+			expectedCommands: 2,
+		},
+		{
+			name:             "missing services are enabled",
+			project:          `{"projectId": "p", "lifecycleState": "ACTIVE"}`,
+			services:         `[{"config": {"name": "compute.googleapis.com"}}]`,
+			expectedEnabled:  []string{"container.googleapis.com"},
+			expectedCommands: 3,
+		},
+		{
+			name:      "deleted project",
+			project:   `{"projectId": "p", "lifecycleState": "DELETE_REQUESTED"}`,
+			expectErr: true,
+		},
+		{
+			name:        "inaccessible project",
+			describeErr: errors.New("exit status 1"),
+			expectErr:   true,
+		},
+		{
+			name:      "unparsable services",
+			project:   `{"projectId": "p", "lifecycleState": "ACTIVE"}`,
+			services:  `compute.googleapis.com`,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, restore := useFakeCmder(func(args []string) (string, error) {
+				switch {
+				case args[1] == "projects":
+					return tc.project, tc.describeErr
+				case args[1] == "services" && args[2] == "list":
+					return tc.services, nil
+				}
+				return "", nil
+			})
+			defer restore()
+			enabled, err := prepareProject("p", []string{"compute.googleapis.com", "container.googleapis.com"})
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if tc.expectErr {
+				return
+			}
+			if diff := cmp.Diff(tc.expectedEnabled, enabled); diff != "" {
+				t.Errorf("enabled services differ (-want, +got):\n%s", diff)
+			}
+			if commands := f.ran(); len(commands) != tc.expectedCommands {
+				t.Errorf("expected %d commands, got %v", tc.expectedCommands, commands)
+			}
+		})
+	}
+}
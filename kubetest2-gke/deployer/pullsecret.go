@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/credentials"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+const (
+	defaultPullSecretName = "kubetest2-registry"
+	// username for authenticating to Google registries with a service account key
+	jsonKeyUsername = "_json_key"
+	// username for authenticating to Google registries with an OAuth2 access token
+	accessTokenUsername = "oauth2accesstoken"
+)
+
+var pullSecretManifestTemplate = template.Must(template.New("pull-secret").Parse(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Namespace}}
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+type: kubernetes.io/dockerconfigjson
+data:
+  .dockerconfigjson: {{.DockerConfig}}
+`))
+
+// dockerConfigJSON returns the base64 encoded content of a
+// kubernetes.io/dockerconfigjson secret for the registry server.
+func dockerConfigJSON(server, username, password string) (string, error) {
+	type auth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	config := struct {
+		Auths map[string]auth `json:"auths"`
+	}{
+		Auths: map[string]auth{
+			server: {
+				Username: username,
+				Password: password,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// pullSecretCredentials returns the credentials for the private registry,
+// either from the service account key file, or an access token of the active
+// gcloud account, which also works with Workload Identity.
+func (d *deployer) pullSecretCredentials() (string, string, error) {
+	if d.pullSecretKeyFile != "" {
+		key, err := credentials.Read(types.Context(d.commonOptions), d.pullSecretKeyFile)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read --registry-secret-key-file: %w", err)
+		}
+		return jsonKeyUsername, key, nil
+	}
+	klog.Warningf("--registry-secret-key-file not provided, using an access token of the active account, which is only valid for about an hour")
+	token, err := secretOutput(types.Context(d.commonOptions), "gcloud", "auth", "print-access-token")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get an access token for the registry secret: %s", execError(err))
+	}
+	return accessTokenUsername, strings.TrimSpace(string(token)), nil
+}
+
+// ensurePullSecrets creates the docker-registry secret in the selected
+// namespaces of all the clusters, and adds it to the imagePullSecrets of the
+// default service account of each namespace.
+func (d *deployer) ensurePullSecrets() error {
+	if d.pullSecretServer == "" {
+		return nil
+	}
+
+	username, password, err := d.pullSecretCredentials()
+	if err != nil {
+		return err
+	}
+	dockerConfig, err := dockerConfigJSON(d.pullSecretServer, username, password)
+	if err != nil {
+		return err
+	}
+	patch := fmt.Sprintf(`{"imagePullSecrets":[{"name":%q}]}`, d.pullSecretName)
+
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			kubeconfig := d.clusterKubeconfig(project, cluster.name)
+			for _, namespace := range d.pullSecretNamespaces {
+				klog.V(1).Infof("Creating registry secret %s in namespace %s of cluster %s", d.pullSecretName, namespace, cluster.name)
+				var manifest bytes.Buffer
+				if err := pullSecretManifestTemplate.Execute(&manifest, struct {
+					Name         string
+					Namespace    string
+					DockerConfig string
+				}{
+					Name:         d.pullSecretName,
+					Namespace:    namespace,
+					DockerConfig: dockerConfig,
+				}); err != nil {
+					return err
+				}
+				// Do not print the manifest as it contains the credentials.
+				apply := kubectlCommand(kubeconfig, "apply", "-f", "-")
+				apply.SetStdin(&manifest)
+				if err := runWithNoOutput(apply); err != nil {
+					return fmt.Errorf("error creating the registry secret in namespace %s of cluster %s: %w", namespace, cluster.name, err)
+				}
+
+				// The default service account is created asynchronously for new namespaces.
+				if err := waitForServiceAccount(types.Context(d.commonOptions), kubeconfig, namespace, "default"); err != nil {
+					return fmt.Errorf("error waiting for the default service account in namespace %s of cluster %s: %w", namespace, cluster.name, err)
+				}
+				if err := runWithOutput(kubectlCommand(kubeconfig, "patch", "serviceaccount", "default",
+					"--namespace="+namespace, "--patch="+patch)); err != nil {
+					return fmt.Errorf("error adding the registry secret to the default service account in namespace %s of cluster %s: %w", namespace, cluster.name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func waitForServiceAccount(ctx context.Context, kubeconfig, namespace, name string) error {
+	return poll(ctx, 2*time.Second, time.Minute, func() (bool, error) {
+		return runWithNoOutput(kubectlCommand(kubeconfig, "get", "serviceaccount", name, "--namespace="+namespace)) == nil, nil
+	})
+}
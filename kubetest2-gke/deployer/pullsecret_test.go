@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDockerConfigJSON(t *testing.T) {
+	encoded, err := dockerConfigJSON("us-docker.pkg.dev", jsonKeyUsername, `{"type": "service_account"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("expected the docker config to be base64 encoded: %v", err)
+	}
+	var config struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(decoded, &config); err != nil {
+		t.Fatalf("failed to parse the docker config %s: %v", decoded, err)
+	}
+	auth, ok := config.Auths["us-docker.pkg.dev"]
+	if !ok || len(config.Auths) != 1 {
+		t.Fatalf("expected a single auth for the registry, got %s", decoded)
+	}
+	if auth.Username != jsonKeyUsername || auth.Password != `{"type": "service_account"}` {
+		t.Errorf("unexpected username %q and password %q", auth.Username, auth.Password)
+	}
+	if expected := base64.StdEncoding.EncodeToString([]byte(jsonKeyUsername + `:{"type": "service_account"}`)); auth.Auth != expected {
+		t.Errorf("expected the auth %q, got %q", expected, auth.Auth)
+	}
+}
+
+func TestPullSecretCredentials(t *testing.T) {
+	var commands []string
+	defer func(o func(context.Context, string, ...string) ([]byte, error)) { secretOutput = o }(secretOutput)
+	secretOutput = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return []byte("ya29.token\n"), nil
+	}
+
+	d := &deployer{}
+	username, password, err := d.pullSecretCredentials()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if username != accessTokenUsername || password != "ya29.token" {
+		t.Errorf("expected the access token of the active account, got %q and %q", username, password)
+	}
+	if !reflect.DeepEqual(commands, []string{"gcloud auth print-access-token"}) {
+		t.Errorf("unexpected commands %v", commands)
+	}
+
+	os.Setenv("KUBETEST2_TEST_REGISTRY_KEY", `{"type": "service_account"}`)
+	defer os.Unsetenv("KUBETEST2_TEST_REGISTRY_KEY")
+	d.pullSecretKeyFile = "env:KUBETEST2_TEST_REGISTRY_KEY"
+	username, password, err = d.pullSecretCredentials()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if username != jsonKeyUsername || password != `{"type": "service_account"}` {
+		t.Errorf("expected the service account key, got %q and %q", username, password)
+	}
+	if len(commands) != 1 {
+		t.Errorf("expected no command to read the key, got %v", commands)
+	}
+}
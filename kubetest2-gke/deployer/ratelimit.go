@@ -0,0 +1,192 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// rateLimiter spaces out the calls so that at most qps calls are made per
+// second. A nil rateLimiter does not limit the calls.
+type rateLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(qps float64) *rateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / qps)}
+}
+
+// wait blocks until the next call is allowed or the context is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	now := time.Now()
+	t := l.next
+	if t.Before(now) {
+		t = now
+	}
+	l.next = t.Add(l.interval)
+	l.lock.Unlock()
+
+	delay := time.Until(t)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedCmder wraps a Cmder and rate limits the gcloud commands globally
+// and per project, so that massively parallel runs do not exceed the API
+// quotas. The commands created without a context wait under the context of
+// the run, so that they do not outlive it.
+type rateLimitedCmder struct {
+	exec.Cmder
+	ctx           context.Context
+	global        *rateLimiter
+	perProjectQPS float64
+
+	lock     sync.Mutex
+	projects map[string]*rateLimiter
+}
+
+func newRateLimitedCmder(ctx context.Context, cmder exec.Cmder, qps, perProjectQPS float64) *rateLimitedCmder {
+	return &rateLimitedCmder{
+		Cmder:         cmder,
+		ctx:           ctx,
+		global:        newRateLimiter(qps),
+		perProjectQPS: perProjectQPS,
+		projects:      map[string]*rateLimiter{},
+	}
+}
+
+var _ exec.CmderWithFailedOutputs = &rateLimitedCmder{}
+
+// FailedOutputs keeps the output of the failed commands in the triage bundle.
+func (c *rateLimitedCmder) FailedOutputs() []string {
+	return exec.FailedOutputs(c.Cmder)
+}
+
+func (c *rateLimitedCmder) Command(name string, args ...string) exec.Cmd {
+	return c.wrap(c.ctx, c.Cmder.Command(name, args...), name, args)
+}
+
+func (c *rateLimitedCmder) CommandContext(ctx context.Context, name string, args ...string) exec.Cmd {
+	return c.wrap(ctx, c.Cmder.CommandContext(ctx, name, args...), name, args)
+}
+
+func (c *rateLimitedCmder) wrap(ctx context.Context, cmd exec.Cmd, name string, args []string) exec.Cmd {
+	if filepath.Base(name) != "gcloud" {
+		return cmd
+	}
+	project := c.projectLimiter(projectFromArgs(args))
+	return &rateLimitedCmd{
+		Cmd: cmd,
+		wait: func() error {
+			if err := c.global.wait(ctx); err != nil {
+				return err
+			}
+			return project.wait(ctx)
+		},
+	}
+}
+
+func (c *rateLimitedCmder) projectLimiter(project string) *rateLimiter {
+	if project == "" || c.perProjectQPS <= 0 {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.projects[project]; !ok {
+		c.projects[project] = newRateLimiter(c.perProjectQPS)
+	}
+	return c.projects[project]
+}
+
+// projectFromArgs returns the value of the --project flag in the gcloud args,
+// or empty if the command uses the default project.
+func projectFromArgs(args []string) string {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--project=") {
+			return strings.TrimPrefix(arg, "--project=")
+		}
+		if arg == "--project" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// rateLimitedCmd waits for the rate limiters before running the command.
+type rateLimitedCmd struct {
+	exec.Cmd
+	wait func() error
+}
+
+func (cmd *rateLimitedCmd) Run() error {
+	if err := cmd.wait(); err != nil {
+		return err
+	}
+	return cmd.Cmd.Run()
+}
+
+// The setters are overridden so that chained calls keep the rate limiting.
+
+func (cmd *rateLimitedCmd) SetEnv(env ...string) exec.Cmd {
+	cmd.Cmd.SetEnv(env...)
+	return cmd
+}
+
+func (cmd *rateLimitedCmd) SetStdin(r io.Reader) exec.Cmd {
+	cmd.Cmd.SetStdin(r)
+	return cmd
+}
+
+func (cmd *rateLimitedCmd) SetStdout(w io.Writer) exec.Cmd {
+	cmd.Cmd.SetStdout(w)
+	return cmd
+}
+
+func (cmd *rateLimitedCmd) SetStderr(w io.Writer) exec.Cmd {
+	cmd.Cmd.SetStderr(w)
+	return cmd
+}
+
+func (cmd *rateLimitedCmd) SetDir(dir string) exec.Cmd {
+	cmd.Cmd.SetDir(dir)
+	return cmd
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+func TestProjectFromArgs(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		args     []string
+		expected string
+	}{
+		{
+			desc:     "no project flag",
+			args:     []string{"container", "clusters", "list"},
+			expected: "",
+		},
+		{
+			desc:     "project flag with equal sign",
+			args:     []string{"container", "clusters", "create", "--project=test-project", "kt2-1"},
+			expected: "test-project",
+		},
+		{
+			desc:     "project flag with separate value",
+			args:     []string{"compute", "networks", "list", "--project", "test-project"},
+			expected: "test-project",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(st *testing.T) {
+			st.Parallel()
+			if actual := projectFromArgs(tc.args); actual != tc.expected {
+				st.Errorf("expected project %q, but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(20)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	// the first call is not delayed, the following 4 are spaced out by 50ms
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected 5 calls to take at least 200ms, but took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newRateLimiter(0.001)
+	_ = l.wait(ctx)
+	if err := l.wait(ctx); err == nil {
+		t.Error("expected an error when the context is done")
+	}
+
+	if err := newRateLimiter(0).wait(context.Background()); err != nil {
+		t.Error("unexpected error for an unlimited rate limiter", err)
+	}
+}
+
+type fakeFailedOutputsCmder struct {
+	exec.Cmder
+}
+
+func (fakeFailedOutputsCmder) FailedOutputs() []string {
+	return []string{"0001-gcloud.log"}
+}
+
+func TestRateLimitedCmderFailedOutputs(t *testing.T) {
+	cmder := newRateLimitedCmder(context.Background(), fakeFailedOutputsCmder{}, 1, 1)
+	if failed := exec.FailedOutputs(cmder); !reflect.DeepEqual(failed, []string{"0001-gcloud.log"}) {
+		t.Errorf("expected the failed outputs of the wrapped cmder, got %v", failed)
+	}
+}
+
+func TestRateLimitedCmderRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f := &fakeCmder{run: func([]string) (string, error) { return "", nil }}
+	cmder := newRateLimitedCmder(ctx, f, 0.001, 0)
+	_ = cmder.Command("gcloud", "projects", "list").Run()
+	if err := cmder.Command("gcloud", "projects", "list").Run(); err == nil {
+		t.Error("expected an error when the run is cancelled")
+	}
+	if ran := f.ran(); len(ran) != 1 {
+		t.Errorf("expected only the first command to run, got %v", ran)
+	}
+}
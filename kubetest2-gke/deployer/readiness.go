@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+const (
+	// readinessTimeout is how long the readiness of a cluster is measured
+	// for after it's created
+	readinessTimeout = 30 * time.Minute
+)
+
+// readinessPollInterval is how often the readiness of the clusters is polled
+var readinessPollInterval = 10 * time.Second
+
+// readinessRecorder collects the readiness of the clusters created
+// concurrently.
+type readinessRecorder struct {
+	lock      sync.Mutex
+	readiness []metadata.ClusterReadiness
+}
+
+func (r *readinessRecorder) add(readiness metadata.ClusterReadiness) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.readiness = append(r.readiness, readiness)
+}
+
+// record records the readiness of the clusters in the metadata, from which
+// kubetest2 copies it to the run summary, and as junit properties.
+func (r *readinessRecorder) record(m *metadata.CustomJSON) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	sort.Slice(r.readiness, func(i, j int) bool {
+		if r.readiness[i].Project != r.readiness[j].Project {
+			return r.readiness[i].Project < r.readiness[j].Project
+		}
+		return r.readiness[i].Cluster < r.readiness[j].Cluster
+	})
+	m.Add(metadata.ReadinessKey, r.readiness)
+	for _, c := range r.readiness {
+		for name, seconds := range map[string]float64{
+			"api-available-seconds":    c.APIAvailableSeconds,
+			"first-node-ready-seconds": c.FirstNodeReadySeconds,
+			"all-nodes-ready-seconds":  c.AllNodesReadySeconds,
+		} {
+			// the clusters of different projects may have the same name
+			m.AddJUnitProperty(fmt.Sprintf("readiness/%s/%s/%s", c.Project, c.Cluster, name), fmt.Sprintf("%.1f", seconds))
+		}
+	}
+}
+
+// nodeReadiness returns the number of ready nodes and the number of nodes in
+// the output of kubectl get nodes --output=json.
+func nodeReadiness(output []byte) (ready, total int, err error) {
+	var nodes struct {
+		Items []struct {
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &nodes); err != nil {
+		return 0, 0, fmt.Errorf("error parsing the nodes: %w", err)
+	}
+	for _, node := range nodes.Items {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				ready++
+			}
+		}
+	}
+	return ready, len(nodes.Items), nil
+}
+
+// readinessWatcher measures the readiness of a cluster in the background
+// while it's being created.
+type readinessWatcher struct {
+	cancel    context.CancelFunc
+	done      chan struct{}
+	readiness metadata.ClusterReadiness
+}
+
+// watchReadiness starts measuring the readiness of the cluster whose
+// creation started at start.
+func (d *deployer) watchReadiness(ctx context.Context, project, loc, clusterName string, start time.Time, expectedNodes int) *readinessWatcher {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	w := &readinessWatcher{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		w.readiness = d.measureReadiness(ctx, project, loc, clusterName, start, expectedNodes)
+	}()
+	return w
+}
+
+// stop stops measuring the readiness, e.g. when the creation failed.
+func (w *readinessWatcher) stop() {
+	w.cancel()
+	<-w.done
+}
+
+// wait waits until all the milestones are reached or the timeout passes, and
+// returns the readiness.
+func (w *readinessWatcher) wait() metadata.ClusterReadiness {
+	<-w.done
+	w.cancel()
+	return w.readiness
+}
+
+// measureReadiness polls the cluster, whose creation started at start, until
+// the API is available and the expected number of nodes are ready, and
+// returns how long each milestone took. The cluster is created with --async,
+// so the milestones reached before the creation operation is done are
+// measured too. The milestones not reached before ctx is done are left at 0,
+// as measuring the readiness does not fail the up phase.
+func (d *deployer) measureReadiness(ctx context.Context, project, loc, clusterName string, start time.Time, expectedNodes int) metadata.ClusterReadiness {
+	readiness := metadata.ClusterReadiness{Project: project, Cluster: clusterName}
+	f, err := ioutil.TempFile("", "kubetest2-gke-readiness")
+	if err != nil {
+		klog.Warningf("Failed to measure the readiness of cluster %s: %v", clusterName, err)
+		return readiness
+	}
+	kubeconfig := f.Name()
+	f.Close()
+	defer os.Remove(kubeconfig)
+
+	hasCredentials := false
+	for {
+		// The credentials can only be fetched once the cluster endpoint is
+		// provisioned.
+		if !hasCredentials {
+			hasCredentials = getClusterCredentialsInto(kubeconfig, project, loc, clusterName, d.credentialsArgs()...) == nil
+		}
+		if hasCredentials && readiness.APIAvailableSeconds == 0 {
+			if _, err := exec.Output(kubectlCommand(kubeconfig, "get", "--raw", "/readyz", "--request-timeout=30s")); err == nil {
+				readiness.APIAvailableSeconds = time.Since(start).Seconds()
+				klog.V(1).Infof("The API of cluster %s is available after %.1fs", clusterName, readiness.APIAvailableSeconds)
+			}
+		}
+		if readiness.APIAvailableSeconds > 0 {
+			if output, err := exec.Output(kubectlCommand(kubeconfig, "get", "nodes", "--output=json", "--request-timeout=30s")); err == nil {
+				ready, total, err := nodeReadiness(output)
+				if err != nil {
+					klog.Warningf("Failed to measure the node readiness of cluster %s: %v", clusterName, err)
+					return readiness
+				}
+				if ready > 0 && readiness.FirstNodeReadySeconds == 0 {
+					readiness.FirstNodeReadySeconds = time.Since(start).Seconds()
+				}
+				if ready == total && total >= expectedNodes && (ready > 0 || expectedNodes == 0) {
+					readiness.AllNodesReadySeconds = time.Since(start).Seconds()
+					klog.V(1).Infof("Cluster %s is ready: %+v", clusterName, readiness)
+					return readiness
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				klog.Warningf("Cluster %s did not reach readiness in %v: %+v", clusterName, readinessTimeout, readiness)
+			}
+			return readiness
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+func TestNodeReadiness(t *testing.T) {
+	ready, total, err := nodeReadiness([]byte(`{"items": [
+		{"status": {"conditions": [{"type": "MemoryPressure", "status": "False"}, {"type": "Ready", "status": "True"}]}},
+		{"status": {"conditions": [{"type": "Ready", "status": "False"}]}},
+		{"status": {}}
+	]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready != 1 || total != 3 {
+		t.Errorf("expected 1 of 3 nodes ready, got %d of %d", ready, total)
+	}
+	if _, _, err := nodeReadiness([]byte("not json")); err == nil {
+		t.Errorf("expected an error parsing invalid output")
+	}
+}
+
+func TestReadinessRecorder(t *testing.T) {
+	r := &readinessRecorder{}
+	r.add(metadata.ClusterReadiness{Project: "p2", Cluster: "a", APIAvailableSeconds: 1})
+	r.add(metadata.ClusterReadiness{Project: "p1", Cluster: "b", APIAvailableSeconds: 2, FirstNodeReadySeconds: 3, AllNodesReadySeconds: 3.5})
+	r.add(metadata.ClusterReadiness{Project: "p1", Cluster: "a", APIAvailableSeconds: 4})
+	m := metadata.NewCustomJSON()
+	r.record(m)
+
+	readiness, _ := m.Get(metadata.ReadinessKey)
+	expected := []metadata.ClusterReadiness{
+		{Project: "p1", Cluster: "a", APIAvailableSeconds: 4},
+		{Project: "p1", Cluster: "b", APIAvailableSeconds: 2, FirstNodeReadySeconds: 3, AllNodesReadySeconds: 3.5},
+		{Project: "p2", Cluster: "a", APIAvailableSeconds: 1},
+	}
+	if diff := cmp.Diff(expected, readiness); diff != "" {
+		t.Errorf("readiness differs (-want, +got):\n%s", diff)
+	}
+	_, values := m.JUnitProperties()
+	for name, expected := range map[string]string{
+		"readiness/p1/b/api-available-seconds":    "2.0",
+		"readiness/p1/b/first-node-ready-seconds": "3.0",
+		"readiness/p1/b/all-nodes-ready-seconds":  "3.5",
+		"readiness/p2/a/api-available-seconds":    "1.0",
+		"readiness/p1/a/all-nodes-ready-seconds":  "0.0",
+	} {
+		if v := values[name]; v != expected {
+			t.Errorf("expected junit property %s to be %q, got %q", name, expected, v)
+		}
+	}
+}
+
+func TestMeasureReadiness(t *testing.T) {
+	pollInterval := readinessPollInterval
+	readinessPollInterval = time.Millisecond
+	defer func() { readinessPollInterval = pollInterval }()
+
+	// The endpoint is provisioned on the second poll, the API is available on
+	// the third, and the nodes become ready one at a time after that.
+	polls := map[string]int{}
+	nodes := []string{
+		`{"items": [{"status": {"conditions": [{"type": "Ready", "status": "False"}]}}, {"status": {}}]}`,
+		`{"items": [{"status": {"conditions": [{"type": "Ready", "status": "True"}]}}, {"status": {}}]}`,
+		`{"items": [{"status": {"conditions": [{"type": "Ready", "status": "True"}]}}, {"status": {"conditions": [{"type": "Ready", "status": "True"}]}}]}`,
+	}
+	f, restore := useFakeCmder(func(args []string) (string, error) {
+		command := strings.Join(args, " ")
+		switch {
+		case strings.Contains(command, "get-credentials"):
+			polls["credentials"]++
+			if polls["credentials"] < 2 {
+				return "", errors.New("no endpoint")
+			}
+		case strings.Contains(command, "/readyz"):
+			polls["api"]++
+			if polls["api"] < 2 {
+				return "", errors.New("connection refused")
+			}
+			return "ok", nil
+		case strings.Contains(command, "get nodes"):
+			polls["nodes"]++
+			return nodes[polls["nodes"]-1], nil
+		}
+		return "", nil
+	})
+	defer restore()
+
+	d := &deployer{}
+	readiness := d.measureReadiness(context.Background(), "p", "--zone=z", "c", time.Now(), 2)
+	if readiness.Project != "p" || readiness.Cluster != "c" {
+		t.Errorf("unexpected cluster %s/%s", readiness.Project, readiness.Cluster)
+	}
+	if !(0 < readiness.APIAvailableSeconds && readiness.APIAvailableSeconds <= readiness.FirstNodeReadySeconds && readiness.FirstNodeReadySeconds <= readiness.AllNodesReadySeconds) {
+		t.Errorf("expected the milestones to be reached in order, got %+v", readiness)
+	}
+	if diff := cmp.Diff(map[string]int{"credentials": 2, "api": 2, "nodes": 3}, polls); diff != "" {
+		t.Errorf("polls differ (-want, +got):\n%s\ncommands: %v", diff, f.ran())
+	}
+}
+
+func TestMeasureReadinessCancelled(t *testing.T) {
+	_, restore := useFakeCmder(func(args []string) (string, error) {
+		return "", errors.New("no endpoint")
+	})
+	defer restore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := &deployer{}
+	expected := metadata.ClusterReadiness{Project: "p", Cluster: "c"}
+	if diff := cmp.Diff(expected, d.measureReadiness(ctx, "p", "--zone=z", "c", time.Now(), 1)); diff != "" {
+		t.Errorf("readiness differs (-want, +got):\n%s", diff)
+	}
+}
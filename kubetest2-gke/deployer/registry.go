@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/credentials"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+const (
+	registryMirrorsName      = "kubetest2-registry-mirrors"
+	defaultRegistryHostsDir  = "/etc/containerd/hosts.d"
+	defaultRegistryInstaller = "registry.k8s.io/e2e-test-images/busybox:1.29-4"
+)
+
+type registryMirror struct {
+	// registry is the upstream registry host, e.g. docker.io
+	registry string
+	// endpoint is the mirror URL images are pulled from instead
+	endpoint string
+}
+
+// hostsTomlTemplate is the containerd hosts.toml for a single registry, see
+// https://github.com/containerd/containerd/blob/main/docs/hosts.md
+var hostsTomlTemplate = template.Must(template.New("hosts.toml").Parse(`[host."{{.Endpoint}}"]
+  capabilities = ["pull", "resolve"]
+{{- if .OverridePath}}
+  override_path = true
+{{- end}}
+{{- if .Auth}}
+  [host."{{.Endpoint}}".header]
+    authorization = "Basic {{.Auth}}"
+{{- end}}
+`))
+
+// registryMirrorsManifestTemplate installs the rendered hosts.toml files on
+// every Linux node. containerd reads the hosts directory on each pull, so no
+// restart is needed.
+var registryMirrorsManifestTemplate = template.Must(template.New("registry-mirrors").Parse(`apiVersion: v1
+kind: Secret
+metadata:
+  name: {{.Name}}
+  namespace: kube-system
+type: Opaque
+data:
+{{- range $key, $value := .Files}}
+  {{$key}}: {{$value}}
+{{- end}}
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: {{.Name}}
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      name: {{.Name}}
+  template:
+    metadata:
+      labels:
+        name: {{.Name}}
+    spec:
+      nodeSelector:
+        kubernetes.io/os: linux
+      tolerations:
+      - operator: Exists
+      initContainers:
+      - name: install
+        image: {{.Image}}
+        command:
+        - sh
+        - -c
+        - |
+          set -o errexit
+          for f in /config/*; do
+            # Secret keys cannot contain ":", so ports are encoded with "_".
+            dir="/host/$(basename "${f}" | tr _ :)"
+            mkdir -p "${dir}"
+            cp "${f}" "${dir}/hosts.toml"
+          done
+        volumeMounts:
+        - name: config
+          mountPath: /config
+        - name: hosts
+          mountPath: /host
+      containers:
+      - name: pause
+        image: {{.Image}}
+        command: ["sh", "-c", "sleep 2147483647"]
+      volumes:
+      - name: config
+        secret:
+          secretName: {{.Name}}
+      - name: hosts
+        hostPath:
+          path: {{.HostsDir}}
+          type: DirectoryOrCreate
+`))
+
+// verifyRegistryMirrorFlags validates the --registry-mirror flags for up
+// phase. The mirrors are installed by a DaemonSet mounting the containerd
+// hosts directory with hostPath, which GKE Autopilot clusters reject.
+func (d *deployer) verifyRegistryMirrorFlags() error {
+	if _, err := parseRegistryMirrors(d.registryMirrors); err != nil {
+		return err
+	}
+	if d.autopilot && len(d.registryMirrors) > 0 {
+		return fmt.Errorf("--registry-mirror is not supported for GKE Autopilot clusters")
+	}
+	// The Artifact Registry mirrors are installed by the same DaemonSet.
+	if d.autopilot && len(d.artifactRegistryMirrors) > 0 {
+		return fmt.Errorf("--artifact-registry-mirror is not supported for GKE Autopilot clusters")
+	}
+	return nil
+}
+
+// parseRegistryMirrors parses the --registry-mirror values, which are in the
+// format of registry=endpoint.
+func parseRegistryMirrors(values []string) ([]registryMirror, error) {
+	mirrors := make([]registryMirror, 0, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("registry mirror %q does not follow the expected format (registry=endpoint)", v)
+		}
+		u, err := url.Parse(parts[1])
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("registry mirror endpoint %q must be a http(s) URL", parts[1])
+		}
+		mirrors = append(mirrors, registryMirror{registry: parts[0], endpoint: parts[1]})
+	}
+	return mirrors, nil
+}
+
+// renderHostsToml renders the containerd hosts.toml for the mirror. auth is
+// the base64 encoded basic auth credential and may be empty.
+func renderHostsToml(mirror registryMirror, auth string) (string, error) {
+	u, err := url.Parse(mirror.endpoint)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := hostsTomlTemplate.Execute(&buf, struct {
+		Endpoint     string
+		OverridePath bool
+		Auth         string
+	}{
+		Endpoint: mirror.endpoint,
+		// Mirrors serving images under a path (e.g. Artifact Registry) must
+		// include the /v2 API prefix in the endpoint themselves.
+		OverridePath: strings.Trim(u.Path, "/") != "",
+		Auth:         auth,
+	}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// registryMirrorsManifest returns the manifest that configures the mirrors
+// on the cluster nodes.
+func (d *deployer) registryMirrorsManifest() ([]byte, error) {
+	mirrors, err := parseRegistryMirrors(d.registryMirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth string
+	if d.registryMirrorPasswordFile != "" {
+		password, err := credentials.Read(types.Context(d.commonOptions), d.registryMirrorPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --registry-mirror-password-file: %w", err)
+		}
+		credential := d.registryMirrorUsername + ":" + password
+		auth = base64.StdEncoding.EncodeToString([]byte(credential))
+	}
+
+	files := make(map[string]string, len(mirrors))
+	for _, mirror := range mirrors {
+		hostsToml, err := renderHostsToml(mirror, auth)
+		if err != nil {
+			return nil, err
+		}
+		files[strings.ReplaceAll(mirror.registry, ":", "_")] = base64.StdEncoding.EncodeToString([]byte(hostsToml))
+	}
+
+	var buf bytes.Buffer
+	if err := registryMirrorsManifestTemplate.Execute(&buf, struct {
+		Name     string
+		Image    string
+		HostsDir string
+		Files    map[string]string
+	}{
+		Name:     registryMirrorsName,
+		Image:    d.registryMirrorInstallerImage,
+		HostsDir: d.registryHostsDir,
+		Files:    files,
+	}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ensureRegistryMirrors configures the registry mirrors on the nodes of all
+// the clusters and waits for the configuration to be rolled out.
+func (d *deployer) ensureRegistryMirrors() error {
+	if len(d.registryMirrors) == 0 {
+		return nil
+	}
+
+	manifest, err := d.registryMirrorsManifest()
+	if err != nil {
+		return err
+	}
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			klog.V(1).Infof("Configuring registry mirrors %v for cluster %s in %s", d.registryMirrors, cluster.name, project)
+
+			kubeconfig := d.clusterKubeconfig(project, cluster.name)
+			apply := kubectlCommand(kubeconfig, "apply", "-f", "-")
+			apply.SetStdin(bytes.NewReader(manifest))
+			if err := runWithOutput(apply); err != nil {
+				return fmt.Errorf("error applying the registry mirrors configuration to cluster %s: %w", cluster.name, err)
+			}
+			if err := runWithOutput(kubectlCommand(kubeconfig, "rollout", "status", "daemonset/"+registryMirrorsName,
+				"--namespace=kube-system", "--timeout=10m")); err != nil {
+				return fmt.Errorf("error waiting for the registry mirrors configuration on cluster %s: %w", cluster.name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRegistryMirrors(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		values      []string
+		expected    []registryMirror
+		expectError bool
+	}{
+		{
+			desc:     "no mirrors",
+			values:   []string{},
+			expected: []registryMirror{},
+		},
+		{
+			desc:   "multiple mirrors",
+			values: []string{"docker.io=https://mirror.example.com", "registry.k8s.io=http://10.0.0.2:5000"},
+			expected: []registryMirror{
+				{registry: "docker.io", endpoint: "https://mirror.example.com"},
+				{registry: "registry.k8s.io", endpoint: "http://10.0.0.2:5000"},
+			},
+		},
+		{
+			desc:        "missing endpoint",
+			values:      []string{"docker.io"},
+			expectError: true,
+		},
+		{
+			desc:        "endpoint is not a URL",
+			values:      []string{"docker.io=mirror.example.com"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := parseRegistryMirrors(tc.values)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, got, cmp.AllowUnexported(registryMirror{})); diff != "" {
+				t.Errorf("unexpected mirrors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistryMirrorFlags(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		d           deployer
+		expectError bool
+	}{
+		{
+			desc: "no mirrors with autopilot",
+			d:    deployer{autopilot: true},
+		},
+		{
+			desc: "mirrors",
+			d:    deployer{registryMirrors: []string{"docker.io=https://mirror.example.com"}},
+		},
+		{
+			desc:        "invalid mirrors",
+			d:           deployer{registryMirrors: []string{"docker.io"}},
+			expectError: true,
+		},
+		{
+			desc:        "artifact registry mirrors with autopilot",
+			d:           deployer{autopilot: true, artifactRegistryMirrors: []string{"docker.io"}},
+			expectError: true,
+		},
+		{
+			desc:        "mirrors with autopilot",
+			d:           deployer{autopilot: true, registryMirrors: []string{"docker.io=https://mirror.example.com"}},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.d.verifyRegistryMirrorFlags()
+			if (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestRenderHostsToml(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		mirror   registryMirror
+		auth     string
+		expected string
+	}{
+		{
+			desc:   "mirror without auth",
+			mirror: registryMirror{registry: "docker.io", endpoint: "https://mirror.example.com"},
+			expected: `[host."https://mirror.example.com"]
+  capabilities = ["pull", "resolve"]
+`,
+		},
+		{
+			desc:   "mirror with path and auth",
+			mirror: registryMirror{registry: "docker.io", endpoint: "https://us-docker.pkg.dev/v2/project/repo"},
+			auth:   "dXNlcjpwYXNz",
+			expected: `[host."https://us-docker.pkg.dev/v2/project/repo"]
+  capabilities = ["pull", "resolve"]
+  override_path = true
+  [host."https://us-docker.pkg.dev/v2/project/repo".header]
+    authorization = "Basic dXNlcjpwYXNz"
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := renderHostsToml(tc.mirror, tc.auth)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("unexpected hosts.toml (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArtifactRegistryMirrorName(t *testing.T) {
+	testCases := []struct {
+		runID    string
+		upstream string
+		expected string
+	}{
+		{
+			runID:    "foobar",
+			upstream: "docker.io",
+			expected: "kt2-foobar-docker-io",
+		},
+		{
+			runID:    "0A6E2B1C-5D2F-4E6B-9C4E-8F3D1A2B3C4D",
+			upstream: "registry.k8s.io",
+			expected: "kt2-0a6e2b1c-5d2f-4e6b-9c4e-8f3d1a2b3-registry-k8s-io",
+		},
+	}
+
+	for _, tc := range testCases {
+		got := artifactRegistryMirrorName(tc.runID, tc.upstream)
+		if got != tc.expected {
+			t.Errorf("expected %q but got %q", tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	// scalePodRangeSizePerNode is the number of pod IPs reserved per node
+	// with the default maximum of 110 pods per node, i.e. a /24.
+	// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/flexible-pod-cidr
+	scalePodRangeSizePerNode = 256
+	// scaleReservedSubnetIPs is the number of IPs of a subnetwork reserved by GCP.
+	scaleReservedSubnetIPs = 4
+	// scaleNodeBatchSize is the number of nodes per zone the default node
+	// pool is grown by at a time.
+	scaleNodeBatchSize = 100
+	// scaleCreateTimeout is how long gcloud waits for the cluster creation,
+	// instead of its default of 30m.
+	scaleCreateTimeout = 3 * time.Hour
+	// scaleCleanupTimeout is the minimum --cleanup-timeout of the scale runs,
+	// as deleting hundreds of nodes takes longer than the default.
+	scaleCleanupTimeout = 2 * time.Hour
+	// scaleDiskSizeGB is the default boot disk size of the GKE nodes.
+	scaleDiskSizeGB = 100
+	// regionalClusterZones is the number of zones of the regional clusters,
+	// each of them having --num-nodes nodes.
+	regionalClusterZones = 3
+	// largeClusterNodes is the size above which --scale is suggested.
+	largeClusterNodes = 500
+)
+
+// zonesPerCluster returns the number of zones of each cluster.
+func (d *deployer) zonesPerCluster() int {
+	if d.zone != "" {
+		return 1
+	}
+	return regionalClusterZones
+}
+
+// nodesPerCluster returns the total number of nodes of the default node pool
+// of each cluster.
+func (d *deployer) nodesPerCluster() int {
+	return d.nodes * d.zonesPerCluster()
+}
+
+// rangePrefixLength returns the prefix length of the smallest IPv4 range
+// with at least size addresses.
+func rangePrefixLength(size int) int {
+	if size <= 1 {
+		return 32
+	}
+	return 32 - bits.Len(uint(size-1))
+}
+
+// podRangePrefixLength returns the prefix length of the pod range fitting
+// the nodes, no smaller than the /14 chosen by GKE by default.
+func podRangePrefixLength(nodes int) int {
+	prefix := rangePrefixLength(nodes * scalePodRangeSizePerNode)
+	if prefix > 14 {
+		prefix = 14
+	}
+	return prefix
+}
+
+// nodeRangePrefixLength returns the prefix length of the subnetwork range
+// fitting the nodes, no smaller than the /20 chosen by GKE by default.
+func nodeRangePrefixLength(nodes int) int {
+	prefix := rangePrefixLength(nodes + scaleReservedSubnetIPs)
+	if prefix > 20 {
+		prefix = 20
+	}
+	return prefix
+}
+
+// verifyScaleFlags validates the flags for the scale runs, and checks that
+// the IP ranges fit the requested number of nodes.
+func (d *deployer) verifyScaleFlags() error {
+	nodes := d.nodesPerCluster()
+	if !d.scale {
+		if !d.autopilot && nodes > largeClusterNodes {
+			klog.Warningf("%d nodes requested per cluster, consider setting --scale to size the IP ranges and raise the timeouts for them", nodes)
+		}
+		return nil
+	}
+	if d.autopilot {
+		return fmt.Errorf("--scale is not supported for GKE Autopilot clusters")
+	}
+
+	check := func(flag, cidr string, size int) error {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("%s must be in CIDR notation: %w", flag, err)
+		}
+		if ones, _ := ipNet.Mask.Size(); ones > rangePrefixLength(size) {
+			return fmt.Errorf("%s %q is too small for %d nodes, it must be at least a /%d", flag, cidr, nodes, rangePrefixLength(size))
+		}
+		return nil
+	}
+	if d.clusterIPv4CIDR != "" {
+		if err := check("--cluster-ipv4-cidr", d.clusterIPv4CIDR, nodes*scalePodRangeSizePerNode); err != nil {
+			return err
+		}
+	}
+	// The ranges of the multi-project profile are in the format of
+	// "nodes services pods", see verifyNetworkFlags.
+	for _, sr := range d.subnetworkRanges {
+		parts := strings.Split(sr, " ")
+		if len(parts) != 3 {
+			continue
+		}
+		if err := check("the node range of --subnetwork-ranges", parts[0], nodes+scaleReservedSubnetIPs); err != nil {
+			return err
+		}
+		if err := check("the pod range of --subnetwork-ranges", parts[2], nodes*scalePodRangeSizePerNode); err != nil {
+			return err
+		}
+	}
+	if d.clusterSecondaryRangeName != "" {
+		klog.Warningf("--cluster-secondary-range-name specified, not checking that it fits %d nodes", nodes)
+	}
+
+	d.raiseScaleCleanupTimeout()
+	return nil
+}
+
+// raiseScaleCleanupTimeout raises --cleanup-timeout for the scale runs,
+// including the down-only ones deleting the clusters of an earlier run.
+func (d *deployer) raiseScaleCleanupTimeout() {
+	if d.scale && d.cleanupTimeout < scaleCleanupTimeout {
+		klog.V(0).Infof("--scale specified, raising --cleanup-timeout from %v to %v", d.cleanupTimeout, scaleCleanupTimeout)
+		d.cleanupTimeout = scaleCleanupTimeout
+	}
+}
+
+// scaleArgs returns the args sizing the IP ranges to the number of nodes and
+// raising the timeout, needed for the cluster creation command.
+func (d *deployer) scaleArgs() []string {
+	if !d.scale {
+		return []string{}
+	}
+	nodes := d.nodesPerCluster()
+	args := []string{fmt.Sprintf("--timeout=%d", int(scaleCreateTimeout.Seconds()))}
+	// The ranges are already set by the flags for the multi-project profile.
+	if len(d.projects) > 1 {
+		return args
+	}
+	if d.clusterIPv4CIDR == "" && d.clusterSecondaryRangeName == "" {
+		// Only the size is set, GKE chooses a range not in use in the network.
+		args = append(args, fmt.Sprintf("--cluster-ipv4-cidr=/%d", podRangePrefixLength(nodes)))
+	}
+	// The subnetwork of the private clusters is created by privateClusterArgs.
+	if d.privateClusterAccessLevel == "" {
+		args = append(args, fmt.Sprintf("--create-subnetwork=range=/%d", nodeRangePrefixLength(nodes)))
+	}
+	return args
+}
+
+// initialNodes returns the --num-nodes of the cluster creation command. The
+// default node pool of the scale runs is created with a batch of nodes, then
+// grown by growDefaultNodePool.
+func (d *deployer) initialNodes() int {
+	if d.scale && d.nodes > scaleNodeBatchSize {
+		return scaleNodeBatchSize
+	}
+	return d.nodes
+}
+
+// growDefaultNodePool resizes the default node pool of the cluster to
+// --num-nodes in batches, as creating hundreds of nodes at once is more
+// likely to fail and slower to retry.
+func (d *deployer) growDefaultNodePool(ctx context.Context, project, loc, clusterName string) error {
+	for size := d.initialNodes(); size < d.nodes; {
+		size += scaleNodeBatchSize
+		if size > d.nodes {
+			size = d.nodes
+		}
+		klog.V(1).Infof("Resizing the default node pool of cluster %s to %d nodes per zone", clusterName, size)
+		if err := runWithOutput(exec.CommandContext(ctx, "gcloud", containerArgs("clusters", "resize", clusterName,
+			"--project="+project,
+			loc,
+			"--node-pool=default-pool",
+			"--num-nodes="+strconv.Itoa(size),
+			"--quiet")...)); err != nil {
+			return fmt.Errorf("error resizing the default node pool of cluster %s to %d nodes: %w", clusterName, size, err)
+		}
+	}
+	return nil
+}
+
+// regionQuotas is the subset of the description of a region read to check
+// the quotas.
+type regionQuotas struct {
+	Quotas []struct {
+		Metric string  `json:"metric"`
+		Limit  float64 `json:"limit"`
+		Usage  float64 `json:"usage"`
+	} `json:"quotas"`
+}
+
+// machineTypeVCPUs returns the number of vCPUs of the predefined machine
+// type, e.g. e2-standard-4.
+func machineTypeVCPUs(machineType string) (int, bool) {
+	parts := strings.Split(machineType, "-")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	vCPUs, err := strconv.Atoi(parts[2])
+	if err != nil || vCPUs <= 0 {
+		return 0, false
+	}
+	return vCPUs, true
+}
+
+// scaleQuotaRequirements returns the regional quotas needed by the nodes of
+// all the node pools of all the clusters of the project, by metric.
+func (d *deployer) scaleQuotaRequirements(clusters int) map[string]float64 {
+	required := map[string]float64{}
+	addPool := func(machineType string, nodesPerZone int) {
+		nodes := float64(nodesPerZone * d.zonesPerCluster() * clusters)
+		if nodes == 0 {
+			return
+		}
+		required["INSTANCES"] += nodes
+		required["DISKS_TOTAL_GB"] += nodes * scaleDiskSizeGB
+		// The private nodes do not have external IPs.
+		if d.privateClusterAccessLevel == "" {
+			required["IN_USE_ADDRESSES"] += nodes
+		}
+		if vCPUs, ok := machineTypeVCPUs(machineType); ok {
+			required["CPUS"] += nodes * float64(vCPUs)
+		} else {
+			klog.Warningf("Cannot tell the vCPUs of machine type %q, not counting it in the CPU quota", machineType)
+		}
+	}
+	addPool(d.machineType, d.nodes)
+	addPool(d.windowsMachineType, d.windowsNodes)
+	for _, pool := range d.clonedNodePools {
+		addPool(pool.Config.MachineType, pool.InitialNodeCount)
+	}
+	return required
+}
+
+// verifyScaleQuotas checks that each project has enough regional quota left
+// for the nodes of its clusters, so that the scale runs fail fast.
+func (d *deployer) verifyScaleQuotas() error {
+	if !d.scale {
+		return nil
+	}
+	region := regionFromLocation(d.region, d.zone)
+	for _, project := range d.projects {
+		var quotas regionQuotas
+		if err := gcloudJSON(&quotas, "compute", "regions", "describe", region, "--project="+project); err != nil {
+			return fmt.Errorf("error getting the quotas of project %s in %s: %s", project, region, execError(err))
+		}
+		required := d.scaleQuotaRequirements(len(d.projectClustersLayout[project]))
+		var insufficient []string
+		for _, q := range quotas.Quotas {
+			need, ok := required[q.Metric]
+			if !ok {
+				continue
+			}
+			if available := q.Limit - q.Usage; available < need {
+				insufficient = append(insufficient, fmt.Sprintf("%s (%.0f needed, %.0f available)", q.Metric, need, available))
+			}
+		}
+		if len(insufficient) > 0 {
+			return fmt.Errorf("project %s does not have enough quota in %s for the requested nodes: %s", project, region, strings.Join(insufficient, ", "))
+		}
+	}
+	return nil
+}
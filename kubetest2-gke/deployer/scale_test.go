@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScaleIPRanges(t *testing.T) {
+	testCases := []struct {
+		nodes              int
+		expectedPodPrefix  int
+		expectedNodePrefix int
+	}{
+		{nodes: 3, expectedPodPrefix: 14, expectedNodePrefix: 20},
+		{nodes: 1000, expectedPodPrefix: 14, expectedNodePrefix: 20},
+		{nodes: 1500, expectedPodPrefix: 13, expectedNodePrefix: 20},
+		{nodes: 5000, expectedPodPrefix: 11, expectedNodePrefix: 19},
+	}
+
+	for _, tc := range testCases {
+		if prefix := podRangePrefixLength(tc.nodes); prefix != tc.expectedPodPrefix {
+			t.Errorf("expected a /%d pod range for %d nodes, got /%d", tc.expectedPodPrefix, tc.nodes, prefix)
+		}
+		if prefix := nodeRangePrefixLength(tc.nodes); prefix != tc.expectedNodePrefix {
+			t.Errorf("expected a /%d node range for %d nodes, got /%d", tc.expectedNodePrefix, tc.nodes, prefix)
+		}
+	}
+}
+
+func TestScaleArgs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		d         *deployer
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "not a scale run",
+			d:        &deployer{nodes: 3, zone: "us-central1-c"},
+			expected: []string{},
+		},
+		{
+			name:     "zonal cluster",
+			d:        &deployer{scale: true, nodes: 2000, zone: "us-central1-c", projects: []string{"p"}},
+			expected: []string{"--timeout=10800", "--cluster-ipv4-cidr=/13", "--create-subnetwork=range=/20"},
+		},
+		{
+			name:     "regional cluster",
+			d:        &deployer{scale: true, nodes: 2000, region: "us-central1", projects: []string{"p"}},
+			expected: []string{"--timeout=10800", "--cluster-ipv4-cidr=/11", "--create-subnetwork=range=/19"},
+		},
+		{
+			name:     "pod range set by the flags",
+			d:        &deployer{scale: true, nodes: 1000, zone: "us-central1-c", projects: []string{"p"}, clusterIPv4CIDR: "10.0.0.0/14", privateClusterAccessLevel: "limited"},
+			expected: []string{"--timeout=10800"},
+		},
+		{
+			name:      "pod range too small",
+			d:         &deployer{scale: true, nodes: 1000, zone: "us-central1-c", projects: []string{"p"}, clusterIPv4CIDR: "10.0.0.0/16"},
+			expectErr: true,
+		},
+		{
+			name:      "subnetwork ranges too small",
+			d:         &deployer{scale: true, nodes: 1000, zone: "us-central1-c", projects: []string{"p", "q"}, subnetworkRanges: []string{"10.0.4.0/23 10.0.32.0/20 10.4.0.0/14"}},
+			expectErr: true,
+		},
+		{
+			name:      "autopilot",
+			d:         &deployer{scale: true, autopilot: true, region: "us-central1"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			err := tc.d.verifyScaleFlags()
+			if (err != nil) != tc.expectErr {
+				st.Fatalf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if tc.expectErr {
+				return
+			}
+			if diff := cmp.Diff(tc.expected, tc.d.scaleArgs()); diff != "" {
+				st.Errorf("args differ (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScaleQuotaRequirements(t *testing.T) {
+	d := &deployer{scale: true, nodes: 100, region: "us-central1", machineType: "e2-standard-4"}
+	expected := map[string]float64{
+		"INSTANCES":        600,
+		"DISKS_TOTAL_GB":   60000,
+		"IN_USE_ADDRESSES": 600,
+		"CPUS":             2400,
+	}
+	if diff := cmp.Diff(expected, d.scaleQuotaRequirements(2)); diff != "" {
+		t.Errorf("quota requirements differ (-want, +got):\n%s", diff)
+	}
+
+	pool := clonedNodePool{Name: "pool-1", InitialNodeCount: 10}
+	pool.Config.MachineType = "n2-standard-8"
+	d = &deployer{scale: true, nodes: 100, zone: "us-central1-c", machineType: "e2-standard-4",
+		windowsNodes: 5, windowsMachineType: "n1-standard-2", clonedNodePools: []clonedNodePool{pool},
+		privateClusterAccessLevel: "no"}
+	expected = map[string]float64{
+		"INSTANCES":      115,
+		"DISKS_TOTAL_GB": 11500,
+		"CPUS":           490,
+	}
+	if diff := cmp.Diff(expected, d.scaleQuotaRequirements(1)); diff != "" {
+		t.Errorf("quota requirements with the Windows and cloned node pools differ (-want, +got):\n%s", diff)
+	}
+}
+
+func TestVerifyDownFlagsScaleCleanupTimeout(t *testing.T) {
+	d := &deployer{scale: true, zone: "us-central1-c", downMode: downModeCluster, cleanupTimeout: 30 * time.Minute}
+	d.projects = []string{"p"}
+	d.clusters = []string{"c"}
+	if err := d.verifyDownFlags(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.cleanupTimeout != scaleCleanupTimeout {
+		t.Errorf("expected --cleanup-timeout to be raised to %v, got %v", scaleCleanupTimeout, d.cleanupTimeout)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// forEachCluster runs fn for all the clusters concurrently, with at most
+// --setup-concurrency clusters at a time, and returns an error listing the
+// failed clusters, if any.
+func (d *deployer) forEachCluster(fn func(project string, cluster cluster) error) error {
+	concurrency := d.setupConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var errs []string
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			project, cluster := project, cluster
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := fn(project, cluster); err != nil {
+					lock.Lock()
+					errs = append(errs, fmt.Sprintf("cluster %s in %s: %v", cluster.name, project, err))
+					lock.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d cluster(s) failed: %s", len(errs), strings.Join(errs, "; "))
+}
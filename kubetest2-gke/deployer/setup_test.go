@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEachCluster(t *testing.T) {
+	d := &deployer{
+		projects: []string{"project-a", "project-b"},
+		projectClustersLayout: map[string][]cluster{
+			"project-a": {{0, "cluster-1"}, {1, "cluster-2"}},
+			"project-b": {{2, "cluster-3"}},
+		},
+		setupConcurrency: 2,
+	}
+
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	err := d.forEachCluster(func(project string, cluster cluster) error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		if cluster.name == "cluster-3" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	if maxRunning > d.setupConcurrency {
+		t.Errorf("expected at most %d clusters set up concurrently, got %d", d.setupConcurrency, maxRunning)
+	}
+	if err == nil {
+		t.Fatal("expected an error but got none")
+	}
+	for _, expected := range []string{"1 cluster(s) failed", "cluster cluster-3 in project-b: boom"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q to contain %q", err, expected)
+		}
+	}
+}
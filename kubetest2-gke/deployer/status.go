@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// runStatus is the output of the status subcommand.
+type runStatus struct {
+	RunID string `json:"runID"`
+	// Up is true if all the clusters are reachable and all their nodes are ready
+	Up       bool            `json:"up"`
+	Clusters []clusterStatus `json:"clusters"`
+}
+
+type clusterStatus struct {
+	Project  string `json:"project"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	// Status is the GKE status of the cluster, e.g. RUNNING
+	Status        string `json:"status"`
+	MasterVersion string `json:"masterVersion"`
+	NodeVersion   string `json:"nodeVersion"`
+	// Reachable is true if the API server of the cluster can list the nodes
+	Reachable bool `json:"reachable"`
+	Nodes     int  `json:"nodes"`
+	// ReadyNodes is the number of nodes with the Ready condition
+	ReadyNodes int `json:"readyNodes"`
+	// KubeletVersions are the distinct kubelet versions of the nodes
+	KubeletVersions []string `json:"kubeletVersions,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// status writes the status of the clusters of the run as JSON to out, and
+// returns an error if any of them is not up, so it can be used between the
+// kubetest2 phases.
+func (d *deployer) status(out io.Writer) error {
+	if len(d.projects) == 0 {
+		return fmt.Errorf("--project must be set to get the status of the run")
+	}
+	if err := d.verifyLocationFlags(); err != nil {
+		return err
+	}
+	if err := d.prepareGcpIfNeeded(); err != nil {
+		return err
+	}
+
+	kubecfgDir, err := ioutil.TempDir("", "kubetest2-gke-status")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(kubecfgDir)
+
+	s := runStatus{RunID: d.commonOptions.RunID(), Up: true, Clusters: []clusterStatus{}}
+	for _, project := range d.projects {
+		var clusters []gkeCluster
+		if err := gcloudJSON(&clusters, containerArgs("clusters", "list",
+			"--project="+project,
+			"--filter="+d.runClusterFilter())...); err != nil {
+			return fmt.Errorf("error listing the clusters in project %s: %s", project, execError(err))
+		}
+		for _, c := range clusters {
+			cs := clusterStatus{
+				Project:       project,
+				Name:          c.Name,
+				Location:      c.Location,
+				Status:        c.Status,
+				MasterVersion: c.CurrentMasterVersion,
+				NodeVersion:   c.CurrentNodeVersion,
+			}
+			if c.Status == "RUNNING" {
+				kubeconfig := filepath.Join(kubecfgDir, fmt.Sprintf("kubecfg-%s-%s", project, c.Name))
+				if err := d.nodesStatus(&cs, kubeconfig); err != nil {
+					cs.Error = err.Error()
+				}
+			}
+			if !cs.Reachable || cs.Nodes == 0 || cs.ReadyNodes != cs.Nodes {
+				s.Up = false
+			}
+			s.Clusters = append(s.Clusters, cs)
+		}
+	}
+	// The run is not up if any of its clusters is missing.
+	if len(s.Clusters) == 0 || (len(d.clusters) > 0 && len(s.Clusters) != len(d.clusters)) {
+		s.Up = false
+	}
+
+	e := json.NewEncoder(out)
+	e.SetIndent("", "  ")
+	if err := e.Encode(s); err != nil {
+		return err
+	}
+	if !s.Up {
+		return fmt.Errorf("run %s is not up", s.RunID)
+	}
+	return nil
+}
+
+// nodesStatus fills in the reachability and the node readiness of the
+// cluster, using a kubeconfig written to the given path. The clusters are
+// listed in all the locations of the project, so the credentials are fetched
+// from the location of the cluster rather than --zone or --region.
+func (d *deployer) nodesStatus(cs *clusterStatus, kubeconfig string) error {
+	// The progress of gcloud is sent to stderr to keep the JSON output clean.
+	args := append([]string{"clusters", "get-credentials", cs.Name,
+		"--project=" + cs.Project,
+		clusterLocationFlag(cs.Location)}, d.credentialsArgs()...)
+	getCredentials := exec.Command("gcloud", containerArgs(args...)...)
+	getCredentials.SetEnv(append(os.Environ(), "KUBECONFIG="+kubeconfig)...)
+	getCredentials.SetStdout(os.Stderr)
+	getCredentials.SetStderr(os.Stderr)
+	if err := getCredentials.Run(); err != nil {
+		return fmt.Errorf("error executing get-credentials: %v", err)
+	}
+
+	output, err := exec.Output(kubectlCommand(kubeconfig, "get", "nodes", "--output=json", "--request-timeout=30s"))
+	if err != nil {
+		return fmt.Errorf("error listing the nodes: %s", execError(err))
+	}
+	var nodes struct {
+		Items []struct {
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+				NodeInfo struct {
+					KubeletVersion string `json:"kubeletVersion"`
+				} `json:"nodeInfo"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &nodes); err != nil {
+		return fmt.Errorf("error parsing the nodes: %w", err)
+	}
+	cs.Reachable = true
+	versions := map[string]bool{}
+	for _, node := range nodes.Items {
+		cs.Nodes++
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				cs.ReadyNodes++
+			}
+		}
+		if v := node.Status.NodeInfo.KubeletVersion; v != "" && !versions[v] {
+			versions[v] = true
+			cs.KubeletVersions = append(cs.KubeletVersions, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNodesStatus(t *testing.T) {
+	const nodes = `{"items": [
+  {"status": {"conditions": [{"type": "Ready", "status": "True"}], "nodeInfo": {"kubeletVersion": "v1.27.3-gke.100"}}},
+  {"status": {"conditions": [{"type": "Ready", "status": "False"}], "nodeInfo": {"kubeletVersion": "v1.27.3-gke.100"}}}
+]}`
+	testCases := []struct {
+		name             string
+		location         string
+		expectedLocation string
+	}{
+		{
+			name:             "zonal cluster",
+			location:         "us-central1-c",
+			expectedLocation: "--zone=us-central1-c",
+		},
+		{
+			name:             "regional cluster",
+			location:         "us-east1",
+			expectedLocation: "--region=us-east1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, restore := useFakeCmder(func(args []string) (string, error) {
+				if args[0] == "kubectl" {
+					return nodes, nil
+				}
+				return "", nil
+			})
+			defer restore()
+
+			// --zone and --region are those of the run, not of the listed cluster.
+			d := &deployer{zone: "us-west1-a"}
+			cs := &clusterStatus{Project: "p", Name: "kt2-1", Location: tc.location}
+			if err := d.nodesStatus(cs, "/tmp/kubeconfig"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ran := f.ran(); len(ran) != 2 || !strings.Contains(ran[0], "get-credentials kt2-1 --project=p "+tc.expectedLocation) {
+				t.Errorf("expected the credentials to be fetched from %s, got %v", tc.expectedLocation, ran)
+			}
+			expected := &clusterStatus{Project: "p", Name: "kt2-1", Location: tc.location,
+				Reachable: true, Nodes: 2, ReadyNodes: 1, KubeletVersions: []string{"v1.27.3-gke.100"}}
+			if diff := cmp.Diff(expected, cs); diff != "" {
+				t.Errorf("status differs (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
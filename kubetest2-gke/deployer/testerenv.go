@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// assert that deployer implements types.DeployerWithTesterEnv
+var _ types.DeployerWithTesterEnv = &deployer{}
+
+// TesterEnv exposes the clusters to the tester as KUBETEST2_CLUSTER_<N>_*
+// environment variables, where N is the index of the cluster in
+// --cluster-name, so multi-cluster testers can target specific clusters.
+func (d *deployer) TesterEnv() ([]string, error) {
+	if _, err := d.Kubeconfig(); err != nil {
+		return nil, err
+	}
+
+	location := d.region
+	if d.zone != "" {
+		location = d.zone
+	}
+	env := []string{fmt.Sprintf("KUBETEST2_NUM_CLUSTERS=%d", len(d.clusters))}
+	env = append(env, d.autopilotTesterEnv()...)
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			c, err := describeCluster(project, locationFlag(d.region, d.zone), cluster.name)
+			if err != nil {
+				return nil, fmt.Errorf("error describing cluster %s: %s", cluster.name, execError(err))
+			}
+			clusterEnv, err := d.clusterTesterEnv(project, location, cluster, c)
+			if err != nil {
+				return nil, err
+			}
+			env = append(env, clusterEnv...)
+		}
+	}
+	return env, nil
+}
+
+// clusterTesterEnv returns the KUBETEST2_CLUSTER_<N>_* environment variables
+// of a cluster.
+func (d *deployer) clusterTesterEnv(project, location string, cluster cluster, c *gkeCluster) ([]string, error) {
+	endpoint := d.clusterEndpoint(c)
+	if endpoint == "" || c.MasterAuth.ClusterCaCertificate == "" {
+		return nil, fmt.Errorf("cluster %s has no endpoint or CA certificate", cluster.name)
+	}
+	prefix := fmt.Sprintf("KUBETEST2_CLUSTER_%d_", cluster.index)
+	return []string{
+		prefix + "NAME=" + cluster.name,
+		prefix + "PROJECT=" + project,
+		prefix + "LOCATION=" + location,
+		prefix + "ENDPOINT=https://" + endpoint,
+		// base64 encoded, as in the certificate-authority-data of a kubeconfig
+		prefix + "CA_CERT=" + c.MasterAuth.ClusterCaCertificate,
+		// the context of the cluster in the kubeconfig returned by Kubeconfig()
+		prefix + "CONTEXT=" + clusterContext(project, cluster.name),
+		// the kubeconfig of the cluster alone, with its context as the current one
+		prefix + "KUBECONFIG=" + d.clusterKubeconfig(project, cluster.name),
+	}, nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"sigs.k8s.io/kubetest2/pkg/kubeconfig"
+)
+
+// gcloudKubeconfig is a kubeconfig as written by gcloud container clusters
+// get-credentials
+const gcloudKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://1.2.3.4
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+users:
+- name: %[1]s
+  user:
+    token: fake
+current-context: %[1]s
+`
+
+func TestClusterTesterEnvContexts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testerenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &deployer{
+		projects: []string{"project-a", "project-b"},
+		projectClustersLayout: map[string][]cluster{
+			"project-a": {{index: 0, name: "cluster-a"}, {index: 1, name: "cluster-b"}},
+			"project-b": {{index: 2, name: "cluster-a"}},
+		},
+		zone:       "us-central1-c",
+		kubecfgDir: dir,
+	}
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			content := fmt.Sprintf(gcloudKubeconfig, fmt.Sprintf("gke_%s_%s_%s", project, d.zone, cluster.name))
+			if err := ioutil.WriteFile(d.clusterKubeconfig(project, cluster.name), []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	path := filepath.Join(dir, "kubeconfig")
+	if err := kubeconfig.Merge(path, d.kubeconfigSources()...); err != nil {
+		t.Fatalf("failed to merge the kubeconfigs: %v", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var merged struct {
+		Contexts []struct {
+			Name string `yaml:"name"`
+		} `yaml:"contexts"`
+	}
+	if err := yaml.Unmarshal(content, &merged); err != nil {
+		t.Fatal(err)
+	}
+	contexts := map[string]bool{}
+	for _, context := range merged.Contexts {
+		contexts[context.Name] = true
+	}
+
+	c := &gkeCluster{Endpoint: "1.2.3.4"}
+	c.MasterAuth.ClusterCaCertificate = "Y2E="
+	found := 0
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			env, err := d.clusterTesterEnv(project, d.zone, cluster, c)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, e := range env {
+				if strings.HasPrefix(e, fmt.Sprintf("KUBETEST2_CLUSTER_%d_CONTEXT=", cluster.index)) {
+					found++
+					if context := strings.SplitN(e, "=", 2)[1]; !contexts[context] {
+						t.Errorf("the context %q of cluster %s in %s is not in the kubeconfig, got %v", context, cluster.name, project, contexts)
+					}
+				}
+			}
+		}
+	}
+	if found != 3 {
+		t.Errorf("expected a context for each of the 3 clusters, got %d", found)
+	}
+}
@@ -25,12 +25,17 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/fs"
+	"sigs.k8s.io/kubetest2/pkg/kubeconfig"
 	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
 // Deployer implementation methods below
@@ -38,6 +43,14 @@ func (d *deployer) Up() error {
 	if err := d.init(); err != nil {
 		return err
 	}
+	// The settings of the cloned cluster are part of the plan confirmed with
+	// --interactive, and their node pools count in the quotas.
+	if err := d.loadCloneSource(); err != nil {
+		return err
+	}
+	if err := d.confirmUp(); err != nil {
+		return err
+	}
 
 	defer func() {
 		if d.RepoRoot == "" {
@@ -49,8 +62,10 @@ func (d *deployer) Up() error {
 		}
 	}()
 
-	// Only run prepare once for the first GCP project.
-	if err := d.prepareGcpIfNeeded(d.projects[0]); err != nil {
+	if err := d.prepareGcpIfNeeded(); err != nil {
+		return err
+	}
+	if err := d.verifyScaleQuotas(); err != nil {
 		return err
 	}
 	if err := d.createNetwork(); err != nil {
@@ -62,15 +77,44 @@ func (d *deployer) Up() error {
 	if err := d.setupNetwork(); err != nil {
 		return err
 	}
+	if err := d.storeNodeSystemConfig(); err != nil {
+		return err
+	}
+	if err := d.allocatePrivateClusterMasterIPRanges(); err != nil {
+		return err
+	}
+	if err := d.createArtifactRegistryMirrors(); err != nil {
+		return err
+	}
+	if err := d.createNotificationsTopics(); err != nil {
+		return err
+	}
+	d.recordAutopilotMetadata()
+	d.recordImageTypes()
+	d.recordCapabilities()
+	d.recordFeatureGates()
+	// Regional clusters are recorded with their region.
+	if d.zone != "" {
+		d.metadata.Add("zones", []string{d.zone})
+	} else {
+		d.metadata.Add("zones", []string{d.region})
+	}
 
 	klog.V(2).Infof("Environment: %v", os.Environ())
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(types.Context(d.commonOptions))
 	defer cancel()
 	eg, ctx := errgroup.WithContext(ctx)
 	loc := locationFlag(d.region, d.zone)
+	// the machine type the default node pool of each cluster was created with
+	machineTypes := map[string]string{}
+	// the number of cluster creations retried with a fallback machine type
+	retries := 0
+	var machineTypesLock sync.Mutex
+	readiness := &readinessRecorder{}
 	for i := range d.projects {
 		project := d.projects[i]
-		subNetworkArgs := subNetworkArgs(d.autopilot, d.projects, regionFromLocation(d.region, d.zone), d.network, i)
+		subNetworkArgs := subNetworkArgs(d.projects, regionFromLocation(d.region, d.zone), d.network, i)
+		ipAliasArgs := d.ipAliasArgs(i)
 		for j := range d.projectClustersLayout[project] {
 			cluster := d.projectClustersLayout[project][j]
 			privateClusterArgs := privateClusterArgs(d.projects, d.network, d.privateClusterAccessLevel, d.privateClusterMasterIPRanges, cluster)
@@ -84,10 +128,16 @@ func (d *deployer) Up() error {
 				)
 				// A few args are not supported in GKE Autopilot cluster creation, so they should be left unset.
 				// https://cloud.google.com/sdk/gcloud/reference/container/clusters/create-auto
+				machineTypeIndex := -1
 				if !d.autopilot {
+					machineTypeIndex = len(args)
 					args = append(args, "--machine-type="+d.machineType)
-					args = append(args, "--num-nodes="+strconv.Itoa(d.nodes))
-					args = append(args, "--image-type="+d.imageType)
+					args = append(args, "--num-nodes="+strconv.Itoa(d.initialNodes()))
+					args = append(args, "--image-type="+d.clusterImageType(cluster))
+					if d.nodeSystemConfig != "" {
+						args = append(args, "--system-config-from-file="+d.nodeSystemConfig)
+					}
+					args = append(args, reservationArgs(d.reservationAffinity, d.reservation)...)
 				}
 
 				if d.workloadIdentityEnabled {
@@ -110,26 +160,135 @@ func (d *deployer) Up() error {
 					args = append(args, "--cluster-version="+d.Version)
 				}
 				args = append(args, subNetworkArgs...)
+				args = append(args, ipAliasArgs...)
+				args = append(args, clusterIPArgs(d.clusterIPv4CIDR, d.servicesIPv4CIDR, d.clusterSecondaryRangeName, d.servicesSecondaryRangeName)...)
 				args = append(args, privateClusterArgs...)
+				args = append(args, d.notificationConfigArgs(project)...)
+				args = append(args, addonsArgs(d.autopilot, d.addons())...)
+				args = append(args, d.clusterDNSArgs()...)
+				args = append(args, d.featureGatesArgs()...)
+				args = append(args, d.scaleArgs()...)
 				args = append(args, cluster.name)
-				if err := runWithOutput(exec.CommandContext(ctx, "gcloud", args...)); err != nil {
+				// Fall back to the next machine type if the location runs out of capacity.
+				var stderr string
+				var err error
+				var watcher *readinessWatcher
+				// The nodes of Autopilot clusters are created for the workloads.
+				expectedNodes := 0
+				if !d.autopilot {
+					expectedNodes = d.nodesPerCluster()
+				}
+				candidates := d.machineTypes()
+				for k, machineType := range candidates {
+					if machineTypeIndex >= 0 {
+						args[machineTypeIndex] = "--machine-type=" + machineType
+					}
+					// The cluster is created asynchronously, so its readiness can be
+					// measured while the creation operation is running.
+					start := time.Now()
+					var operation string
+					operation, stderr, err = startClusterCreation(ctx, args)
+					if err == nil {
+						watcher = d.watchReadiness(ctx, project, loc, cluster.name, start, expectedNodes)
+						stderr, err = runWithCapturedStderr(exec.CommandContext(ctx, "gcloud", containerArgs("operations", "wait", operation,
+							"--project="+project,
+							loc)...))
+						if err != nil {
+							watcher.stop()
+						}
+					}
+					if err == nil {
+						machineTypesLock.Lock()
+						machineTypes[cluster.name] = machineType
+						machineTypesLock.Unlock()
+						break
+					}
+					if ctx.Err() != nil || k == len(candidates)-1 || !isStockout(stderr) {
+						break
+					}
+					klog.Warningf("Machine type %s is out of capacity for cluster %s, falling back to machine type %s", machineType, cluster.name, candidates[k+1])
+					machineTypesLock.Lock()
+					retries++
+					machineTypesLock.Unlock()
+					// The failed cluster must be deleted before it can be created again with the same name.
+					if delErr := runWithOutput(exec.CommandContext(ctx, "gcloud", containerArgs("clusters", "delete", "-q", cluster.name,
+						"--project="+project,
+						loc)...)); delErr != nil {
+						klog.Warningf("Failed to delete the failed cluster %s, assuming it was not created: %v", cluster.name, delErr)
+					}
+				}
+				if err != nil {
+					// The creation was cancelled because of another failure, there is nothing to diagnose.
+					if ctx.Err() != nil {
+						return fmt.Errorf("error creating cluster: %v", err)
+					}
 					// Cancel the context to kill other cluster creation processes if any error happens.
 					cancel()
-					return fmt.Errorf("error creating cluster: %v", err)
+					return d.clusterCreationError(project, loc, cluster.name, err, stderr)
+				}
+				if err := d.growDefaultNodePool(ctx, project, loc, cluster.name); err != nil {
+					cancel()
+					return err
+				}
+				readiness.add(watcher.wait())
+				if d.windowsNodes > 0 {
+					if err := runWithOutput(exec.CommandContext(ctx, "gcloud", d.windowsNodePoolArgs(project, loc, cluster.name)...)); err != nil {
+						cancel()
+						return fmt.Errorf("error creating the Windows node pool: %v", err)
+					}
+				}
+				for _, pool := range d.clonedNodePools {
+					if err := runWithOutput(exec.CommandContext(ctx, "gcloud", d.clonedNodePoolArgs(project, loc, cluster.name, pool)...)); err != nil {
+						cancel()
+						return fmt.Errorf("error creating the cloned node pool %s: %v", pool.Name, err)
+					}
 				}
 				return nil
 			})
 		}
 	}
 
-	if err := eg.Wait(); err != nil {
+	err := eg.Wait()
+	// The retries and the readiness are recorded even if the creation failed.
+	d.metadata.Add("retries", retries)
+	readiness.record(d.metadata)
+	if err != nil {
+		// Keep the diagnostics of the failure for the JUnit output.
+		if jErr, ok := err.(metadata.JUnitError); ok {
+			return metadata.NewJUnitError(fmt.Errorf("error creating clusters: %v", err), jErr.SystemOut())
+		}
 		return fmt.Errorf("error creating clusters: %v", err)
 	}
+	if !d.autopilot {
+		d.metadata.Add("machine-types", machineTypes)
+		for name, machineType := range machineTypes {
+			d.metadata.AddJUnitProperty("machine-type/"+name, machineType)
+		}
+	}
 
 	if err := d.testSetup(); err != nil {
 		return fmt.Errorf("error running setup for the tests: %v", err)
 	}
+	if err := d.verifyCrossClusterConnectivity(); err != nil {
+		return err
+	}
+	if err := d.backupClusters(); err != nil {
+		return fmt.Errorf("error backing up the clusters: %v", err)
+	}
+
+	return nil
+}
 
+// storeNodeSystemConfig copies the node system configuration file into the
+// artifacts directory, so the settings used by the run are preserved.
+func (d *deployer) storeNodeSystemConfig() error {
+	if d.nodeSystemConfig == "" {
+		return nil
+	}
+	dst := filepath.Join(d.commonOptions.RunDir(), filepath.Base(d.nodeSystemConfig))
+	if err := fs.CopyFile(d.nodeSystemConfig, dst); err != nil {
+		return fmt.Errorf("failed to store the node system config in artifacts: %w", err)
+	}
 	return nil
 }
 
@@ -154,14 +313,105 @@ func (d *deployer) createCommand() []string {
 	return fs
 }
 
+// machineTypes returns the machine types to create the default node pool
+// with, in order of preference.
+func (d *deployer) machineTypes() []string {
+	return append([]string{d.machineType}, d.machineTypeFallbacks...)
+}
+
+// verifyReservationFlags validates the flags for consuming GCE reservations.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/consuming-reservations
+func (d *deployer) verifyReservationFlags() error {
+	switch d.reservationAffinity {
+	case "":
+		if d.reservation != "" {
+			return fmt.Errorf("--reservation can only be used with --reservation-affinity=specific")
+		}
+		return nil
+	case "any", "none":
+		if d.reservation != "" {
+			return fmt.Errorf("--reservation can only be used with --reservation-affinity=specific")
+		}
+	case "specific":
+		if d.reservation == "" {
+			return fmt.Errorf("--reservation must be set with --reservation-affinity=specific")
+		}
+	default:
+		return fmt.Errorf("--reservation-affinity must be one of %v", []string{"", "any", "none", "specific"})
+	}
+	// The nodes are provisioned by GKE in Autopilot mode.
+	if d.autopilot {
+		return fmt.Errorf("--reservation-affinity is not supported for GKE Autopilot clusters")
+	}
+	return nil
+}
+
+// reservationArgs returns the args for consuming GCE reservations needed for
+// the cluster creation command.
+func reservationArgs(affinity, reservation string) []string {
+	args := []string{}
+	if affinity != "" {
+		args = append(args, "--reservation-affinity="+affinity)
+	}
+	if reservation != "" {
+		args = append(args, "--reservation="+reservation)
+	}
+	return args
+}
+
+// addons returns the GKE addons to enable in the cluster creation command.
+func (d *deployer) addons() []string {
+	addons := make([]string, 0)
+	if d.backupBeforeTest {
+		addons = append(addons, backupRestoreAddon)
+	}
+	if d.nodeLocalDNSCache {
+		addons = append(addons, nodeLocalDNSAddon)
+	}
+	// --addons disables the default addons it does not list. The cloned
+	// addons include those of the default addons not disabled on the cluster
+	// to clone.
+	if len(addons) > 0 && d.cloneFromCluster == "" {
+		addons = append(addons, defaultAddons...)
+	}
+	for _, addon := range d.clonedAddons {
+		if !containsString(addons, addon) {
+			addons = append(addons, addon)
+		}
+	}
+	return addons
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultAddons are the addons enabled by gcloud when --addons is not set.
+var defaultAddons = []string{"HorizontalPodAutoscaling", "HttpLoadBalancing"}
+
+// addonsArgs returns the args for enabling the addons in the cluster creation
+// command. Autopilot clusters do not support --addons, so the addons are
+// enabled after the cluster is created instead.
+func addonsArgs(autopilot bool, addons []string) []string {
+	if autopilot || len(addons) == 0 {
+		return []string{}
+	}
+	return []string{"--addons=" + strings.Join(addons, ",")}
+}
+
 func (d *deployer) IsUp() (up bool, err error) {
-	if err := d.prepareGcpIfNeeded(d.projects[0]); err != nil {
+	if err := d.prepareGcpIfNeeded(); err != nil {
 		return false, err
 	}
 
 	for _, project := range d.projects {
 		for _, cluster := range d.projectClustersLayout[project] {
-			if err := getClusterCredentials(project, locationFlag(d.region, d.zone), cluster.name); err != nil {
+			if err := getClusterCredentials(project, locationFlag(d.region, d.zone), cluster.name, d.credentialsArgs()...); err != nil {
 				return false, err
 			}
 
@@ -187,25 +437,42 @@ func (d *deployer) testSetup() error {
 		return nil
 	}
 
-	// Only run prepare once for the first GCP project.
-	if err := d.prepareGcpIfNeeded(d.projects[0]); err != nil {
+	if err := d.prepareGcpIfNeeded(); err != nil {
 		return err
 	}
 	if _, err := d.Kubeconfig(); err != nil {
 		return err
 	}
+	if err := d.recordNodeCounts(); err != nil {
+		return err
+	}
 	if err := d.getInstanceGroups(); err != nil {
 		return err
 	}
 	if err := d.ensureFirewallRules(); err != nil {
 		return err
 	}
+	if err := d.verifyDNSHealthy(); err != nil {
+		return err
+	}
+	if err := d.ensureRegistryMirrors(); err != nil {
+		return err
+	}
+	if err := d.ensurePullSecrets(); err != nil {
+		return err
+	}
+	if err := d.warmUpAutopilotClusters(); err != nil {
+		return err
+	}
 	d.testPrepared = true
 	return nil
 }
 
-// Kubeconfig returns a path to a kubeconfig file for the cluster in
+// Kubeconfig returns a path to a kubeconfig file for the clusters in
 // a temp directory, creating one if one does not exist.
+// The kubeconfig is merged from the ones of the clusters, with a context per
+// cluster named by kubeconfig.ContextName(Name, project, cluster), and the
+// current context set to the last cluster.
 // It also sets the KUBECONFIG environment variable appropriately.
 func (d *deployer) Kubeconfig() (string, error) {
 	if d.kubecfgPath != "" {
@@ -216,23 +483,60 @@ func (d *deployer) Kubeconfig() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	d.kubecfgDir = tmpdir
+	if err := d.setKubeconfigAuthEnv(); err != nil {
+		return "", err
+	}
+
+	if err := d.forEachCluster(func(project string, cluster cluster) error {
+		filename := d.clusterKubeconfig(project, cluster.name)
+		if err := getClusterCredentialsInto(filename, project, locationFlag(d.region, d.zone), cluster.name, d.credentialsArgs()...); err != nil {
+			return err
+		}
+		if d.kubeconfigAuth == kubeconfigAuthToken {
+			return useStaticToken(types.Context(d.commonOptions), filename, cluster.name)
+		}
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("error getting the cluster credentials: %w", err)
+	}
+
+	// The current context is left to the last cluster, as the commands using
+	// it expect.
+	path, err := kubeconfig.Write(filepath.Join(d.kubecfgDir, "kubeconfig"), d.kubeconfigSources()...)
+	if err != nil {
+		return "", fmt.Errorf("error merging the cluster kubeconfigs: %w", err)
+	}
 
-	kubecfgFiles := make([]string, 0)
+	d.kubecfgPath = path
+	return d.kubecfgPath, nil
+}
+
+// kubeconfigSources returns the kubeconfigs of the clusters, which are merged
+// into the one returned by Kubeconfig().
+func (d *deployer) kubeconfigSources() []kubeconfig.Source {
+	var sources []kubeconfig.Source
 	for _, project := range d.projects {
 		for _, cluster := range d.projectClustersLayout[project] {
-			filename := filepath.Join(tmpdir, fmt.Sprintf("kubecfg-%s-%s", project, cluster.name))
-			if err := os.Setenv("KUBECONFIG", filename); err != nil {
-				return "", err
-			}
-			if err := getClusterCredentials(project, locationFlag(d.region, d.zone), cluster.name); err != nil {
-				return "", err
-			}
-			kubecfgFiles = append(kubecfgFiles, filename)
+			sources = append(sources, kubeconfig.Source{
+				Path: d.clusterKubeconfig(project, cluster.name),
+				Name: clusterContext(project, cluster.name),
+			})
 		}
 	}
+	return sources
+}
 
-	d.kubecfgPath = strings.Join(kubecfgFiles, string(os.PathListSeparator))
-	return d.kubecfgPath, nil
+// clusterContext returns the name of the context of a cluster in the
+// kubeconfig returned by Kubeconfig().
+func clusterContext(project, clusterName string) string {
+	return kubeconfig.ContextName(Name, project, clusterName)
+}
+
+// clusterKubeconfig returns the path to the kubeconfig file of a single
+// cluster, which is written by Kubeconfig().
+func (d *deployer) clusterKubeconfig(project, clusterName string) string {
+	return filepath.Join(d.kubecfgDir, fmt.Sprintf("kubecfg-%s-%s", project, clusterName))
 }
 
 // verifyCommonFlags validates flags for up phase.
@@ -241,6 +545,23 @@ func (d *deployer) verifyUpFlags() error {
 		return fmt.Errorf("either --project or --projects-requested with a value larger than 0 must be set for GKE deployment")
 	}
 
+	if len(d.layout) > 0 {
+		if len(d.clusters) > 0 {
+			return fmt.Errorf("--layout cannot be used with --cluster-name")
+		}
+		numProjects := len(d.projects)
+		if numProjects == 0 {
+			numProjects = d.boskosProjectsRequested
+		}
+		clusters, err := clusterNamesFromLayout(d.layout, d.projects, numProjects, d.commonOptions.RunID())
+		if err != nil {
+			return err
+		}
+		d.clusters = clusters
+	}
+	if err := d.verifyImageTypesFlags(); err != nil {
+		return err
+	}
 	if len(d.clusters) == 0 {
 		if len(d.projects) > 1 || d.boskosProjectsRequested > 1 {
 			return fmt.Errorf("explicit --cluster-name must be set for multi-project profile")
@@ -258,12 +579,71 @@ func (d *deployer) verifyUpFlags() error {
 	if err := d.verifyLocationFlags(); err != nil {
 		return err
 	}
-	if d.nodes <= 0 {
+	// Nodes are provisioned by GKE based on the workloads in Autopilot mode.
+	if d.autopilot {
+		if d.nodes != defaultNodePool.Nodes {
+			klog.V(0).Infof("--autopilot specified, ignoring --num-nodes")
+		}
+	} else if d.nodes <= 0 {
 		return fmt.Errorf("--num-nodes must be larger than 0")
 	}
+	if err := d.verifyAutopilotFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyWindowsFlags(); err != nil {
+		return err
+	}
+	if d.nodeSystemConfig != "" {
+		// Node system configuration is managed by GKE in Autopilot mode.
+		if d.autopilot {
+			return fmt.Errorf("--node-system-config is not supported for GKE Autopilot clusters")
+		}
+		if _, err := os.Stat(d.nodeSystemConfig); err != nil {
+			return fmt.Errorf("failed to find the --node-system-config file: %w", err)
+		}
+	}
 	if err := validateVersion(d.Version); err != nil {
 		return err
 	}
+	if err := d.verifyRegistryMirrorFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyArtifactRegistryMirrorFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyDNSFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyReservationFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyFeatureGatesFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyScaleFlags(); err != nil {
+		return err
+	}
+	if _, err := parseFirewallRuleSets(d.firewallRuleSets); err != nil {
+		return err
+	}
+	if err := d.verifyKubeconfigAuthFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyCredentialsFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyVPCServiceControlsFlags(); err != nil {
+		return err
+	}
+	if d.cloneFromCluster != "" {
+		if _, _, _, err := parseCloneSource(d.cloneFromCluster); err != nil {
+			return err
+		}
+	}
+	// The nodes are provisioned by GKE in Autopilot mode.
+	if d.autopilot && len(d.machineTypeFallbacks) > 0 {
+		return fmt.Errorf("--machine-type-fallbacks is not supported for GKE Autopilot clusters")
+	}
 	return nil
 }
 
@@ -19,6 +19,8 @@ package deployer
 import (
 	"reflect"
 	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
 )
 
 func TestClusterVersion(t *testing.T) {
@@ -116,3 +118,258 @@ func TestGenerateClusterNames(t *testing.T) {
 		})
 	}
 }
+
+func TestAddonsArgs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		autopilot bool
+		addons    []string
+		expected  []string
+	}{
+		{
+			name:     "no addons",
+			expected: []string{},
+		},
+		{
+			name:     "multiple addons",
+			addons:   []string{"BackupRestore", "GcsFuseCsiDriver"},
+			expected: []string{"--addons=BackupRestore,GcsFuseCsiDriver"},
+		},
+		{
+			name:      "autopilot clusters do not support --addons",
+			autopilot: true,
+			addons:    []string{"BackupRestore"},
+			expected:  []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual := addonsArgs(tc.autopilot, tc.addons)
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("expected addons args %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReservationArgs(t *testing.T) {
+	testCases := []struct {
+		name        string
+		affinity    string
+		reservation string
+		expected    []string
+	}{
+		{
+			name:     "no reservation affinity",
+			expected: []string{},
+		},
+		{
+			name:     "any reservation",
+			affinity: "any",
+			expected: []string{"--reservation-affinity=any"},
+		},
+		{
+			name:        "specific reservation",
+			affinity:    "specific",
+			reservation: "test-reservation",
+			expected:    []string{"--reservation-affinity=specific", "--reservation=test-reservation"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual := reservationArgs(tc.affinity, tc.reservation)
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("expected reservation args %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVerifyImageTypesFlags(t *testing.T) {
+	testCases := []struct {
+		name                string
+		imageTypes          []string
+		imageType           string
+		clusters            []string
+		numClusters         int
+		expectError         bool
+		expectedNumClusters int
+	}{
+		{
+			name:                "no image types",
+			imageType:           defaultImage,
+			numClusters:         1,
+			expectedNumClusters: 1,
+		},
+		{
+			name:                "one cluster per image type",
+			imageTypes:          []string{"cos_containerd", "ubuntu_containerd"},
+			imageType:           defaultImage,
+			numClusters:         1,
+			expectedNumClusters: 2,
+		},
+		{
+			name:        "mismatching number of clusters",
+			imageTypes:  []string{"cos_containerd", "ubuntu_containerd"},
+			imageType:   defaultImage,
+			numClusters: 3,
+			expectError: true,
+		},
+		{
+			name:                "explicit cluster names",
+			imageTypes:          []string{"cos_containerd", "ubuntu_containerd"},
+			imageType:           defaultImage,
+			clusters:            []string{"cos", "ubuntu"},
+			numClusters:         1,
+			expectedNumClusters: 1,
+		},
+		{
+			name:        "mismatching number of cluster names",
+			imageTypes:  []string{"cos_containerd", "ubuntu_containerd"},
+			imageType:   defaultImage,
+			clusters:    []string{"cos"},
+			numClusters: 1,
+			expectError: true,
+		},
+		{
+			name:        "with --image-type",
+			imageTypes:  []string{"cos_containerd", "ubuntu_containerd"},
+			imageType:   "ubuntu_containerd",
+			numClusters: 1,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &deployer{
+				imageTypes: tc.imageTypes,
+				imageType:  tc.imageType,
+				clusters:   tc.clusters,
+				UpOptions:  &options.UpOptions{NumClusters: tc.numClusters},
+			}
+			err := d.verifyImageTypesFlags()
+			if tc.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if d.UpOptions.NumClusters != tc.expectedNumClusters {
+				t.Errorf("expected %d clusters, got %d", tc.expectedNumClusters, d.UpOptions.NumClusters)
+			}
+		})
+	}
+}
+
+func TestClusterNamesFromLayout(t *testing.T) {
+	testCases := []struct {
+		name          string
+		layout        []string
+		projects      []string
+		numProjects   int
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:        "single project",
+			layout:      []string{"projA:2"},
+			projects:    []string{"projA"},
+			numProjects: 1,
+			expected:    []string{"kt2-run-1", "kt2-run-2"},
+		},
+		{
+			name:        "asymmetric projects by name",
+			layout:      []string{"projB:1", "projA:2"},
+			projects:    []string{"projA", "projB"},
+			numProjects: 2,
+			expected:    []string{"kt2-run-1:1", "kt2-run-2:0", "kt2-run-3:0"},
+		},
+		{
+			name:        "projects by index",
+			layout:      []string{"0:1", "1:2"},
+			numProjects: 2,
+			expected:    []string{"kt2-run-1:0", "kt2-run-2:1", "kt2-run-3:1"},
+		},
+		{
+			name:          "unknown project",
+			layout:        []string{"projC:1"},
+			projects:      []string{"projA", "projB"},
+			numProjects:   2,
+			expectedError: true,
+		},
+		{
+			name:          "duplicate project",
+			layout:        []string{"projA:1", "0:1"},
+			projects:      []string{"projA", "projB"},
+			numProjects:   2,
+			expectedError: true,
+		},
+		{
+			name:          "invalid count",
+			layout:        []string{"projA:0"},
+			projects:      []string{"projA"},
+			numProjects:   1,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := clusterNamesFromLayout(tc.layout, tc.projects, tc.numProjects, "run")
+			if tc.expectedError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsStockout(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stderr   string
+		expected bool
+	}{
+		{
+			name:     "zone resource pool exhausted",
+			stderr:   "ERROR: (gcloud.container.clusters.create) Operation [...] finished with error: ZONE_RESOURCE_POOL_EXHAUSTED: The zone does not have enough resources",
+			expected: true,
+		},
+		{
+			name:     "not enough resources",
+			stderr:   "The zone 'projects/p/zones/us-central1-a' does not have enough resources available to fulfill the request.",
+			expected: true,
+		},
+		{
+			name:   "quota exceeded",
+			stderr: "ERROR: (gcloud.container.clusters.create) ResponseError: code=403, message=Insufficient regional quota to satisfy request",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := isStockout(tc.stderr); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/app"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Subcommands are the additional commands of the GKE deployer binary.
+var Subcommands = []app.Subcommand{
+	{
+		Name:  "verify-down",
+		Short: "Check that all the resources of the run (--run-id) are gone, and exit non-zero listing the remnants",
+		Run: func(d types.Deployer) error {
+			return d.(*deployer).verifyDown()
+		},
+	},
+	{
+		Name:  "status",
+		Short: "Print the reachability, node readiness and versions of the clusters of the run (--run-id) as JSON, and exit non-zero if any is not up",
+		Run: func(d types.Deployer) error {
+			return d.(*deployer).status(os.Stdout)
+		},
+	},
+}
+
+// verifyDown checks whether the clusters, subnets, firewall rules and network
+// created by the run still exist, and returns an error listing them if so.
+func (d *deployer) verifyDown() error {
+	if len(d.projects) == 0 {
+		return fmt.Errorf("--project must be set to verify the run is down")
+	}
+	if err := d.verifyLocationFlags(); err != nil {
+		return err
+	}
+	if err := d.prepareGcpIfNeeded(); err != nil {
+		return err
+	}
+
+	var remnants []string
+	list := func(kind string, args ...string) error {
+		var resources []struct {
+			Name string `json:"name"`
+		}
+		if err := gcloudJSON(&resources, args...); err != nil {
+			return fmt.Errorf("error listing %s: %s", kind, execError(err))
+		}
+		for _, r := range resources {
+			remnants = append(remnants, fmt.Sprintf("%s %s", kind, r.Name))
+		}
+		return nil
+	}
+
+	for _, project := range d.projects {
+		if err := list("cluster", containerArgs("clusters", "list", "--project="+project, "--filter="+d.runClusterFilter())...); err != nil {
+			return err
+		}
+	}
+
+	hostProject := d.projects[0]
+	for _, serviceProject := range d.projects[1:] {
+		if err := list("subnet", "compute", "networks", "subnets", "list",
+			"--project="+hostProject,
+			"--filter=name="+d.network+"-"+serviceProject); err != nil {
+			return err
+		}
+	}
+	if err := list("firewall rule", "compute", "firewall-rules", "list",
+		"--project="+hostProject,
+		"--filter="+d.runFirewallFilter()); err != nil {
+		return err
+	}
+	// The default network is never deleted.
+	if d.network != "default" {
+		if err := list("network", "compute", "networks", "list",
+			"--project="+hostProject,
+			"--filter=name="+d.network); err != nil {
+			return err
+		}
+	}
+
+	if len(remnants) > 0 {
+		return fmt.Errorf("%d resource(s) of run %s are not gone: %s", len(remnants), d.commonOptions.RunID(), strings.Join(remnants, ", "))
+	}
+	klog.V(0).Infof("All the resources of run %s are gone", d.commonOptions.RunID())
+	return nil
+}
+
+// runClusterFilter returns the gcloud filter matching the clusters of the run,
+// which are the ones of --cluster-name, or the ones generated for the run.
+func (d *deployer) runClusterFilter() string {
+	if len(d.clusters) == 0 {
+		return fmt.Sprintf("name:%s*", runClusterNamePrefix(d.commonOptions.RunID()))
+	}
+	var names []string
+	for _, name := range d.clusters {
+		// Strip the project index of the multi-project profile.
+		names = append(names, fmt.Sprintf("name=%s", strings.Split(name, ":")[0]))
+	}
+	return strings.Join(names, " OR ")
+}
+
+// runFirewallFilter returns the gcloud filter matching the firewall rules of
+// the run. The networks other than the default one are created by the run, so
+// all their rules are. The default network is shared with the other runs, so
+// only the rules GKE creates for the clusters of the run, named after them,
+// are matched.
+func (d *deployer) runFirewallFilter() string {
+	filter := "network:" + d.network
+	if d.network != "default" {
+		return filter
+	}
+	var names []string
+	if len(d.clusters) == 0 {
+		names = append(names, fmt.Sprintf("name:gke-%s*", runClusterNamePrefix(d.commonOptions.RunID())))
+	}
+	for _, name := range d.clusters {
+		names = append(names, fmt.Sprintf("name:gke-%s-*", strings.Split(name, ":")[0]))
+	}
+	return fmt.Sprintf("%s AND (%s)", filter, strings.Join(names, " OR "))
+}
+
+// runClusterNamePrefix returns the prefix of the cluster names generated for
+// the run, see generateClusterNames.
+func runClusterNamePrefix(runID string) string {
+	const maxIDLength = 33
+	if len(runID) > maxIDLength {
+		runID = runID[:maxIDLength]
+	}
+	return "kt2-" + runID + "-"
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// runOptions fakes the options of a run with its id.
+type runOptions struct {
+	types.Options
+	runID string
+}
+
+func (o runOptions) RunID() string {
+	return o.runID
+}
+
+func TestRunFilters(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		network                string
+		clusters               []string
+		expectedClusterFilter  string
+		expectedFirewallFilter string
+	}{
+		{
+			name:                   "generated clusters in the default network",
+			network:                "default",
+			expectedClusterFilter:  "name:kt2-1234-*",
+			expectedFirewallFilter: "network:default AND (name:gke-kt2-1234-*)",
+		},
+		{
+			name:                   "named clusters in the default network",
+			network:                "default",
+			clusters:               []string{"c1:0", "c2:1"},
+			expectedClusterFilter:  "name=c1 OR name=c2",
+			expectedFirewallFilter: "network:default AND (name:gke-c1-* OR name:gke-c2-*)",
+		},
+		{
+			name:                   "network of the run",
+			network:                "kt2-1234",
+			clusters:               []string{"c1"},
+			expectedClusterFilter:  "name=c1",
+			expectedFirewallFilter: "network:kt2-1234",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			d := &deployer{commonOptions: runOptions{runID: "1234"}, network: tc.network}
+			d.clusters = tc.clusters
+			if actual := d.runClusterFilter(); actual != tc.expectedClusterFilter {
+				st.Errorf("expected cluster filter %q, got %q", tc.expectedClusterFilter, actual)
+			}
+			if actual := d.runFirewallFilter(); actual != tc.expectedFirewallFilter {
+				st.Errorf("expected firewall filter %q, got %q", tc.expectedFirewallFilter, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// pscEndpointRe matches the names of the Private Service Connect endpoints
+// for Google APIs.
+// Reference: https://cloud.google.com/vpc/docs/configure-private-service-connect-apis#naming
+var pscEndpointRe = regexp.MustCompile(`^[a-z][a-z0-9]{0,19}$`)
+
+// pscAPIs are the Google APIs called by the deployer through gcloud, other
+// than the container API which depends on --environment.
+var pscAPIs = []string{
+	"artifactregistry",
+	"cloudresourcemanager",
+	"compute",
+	"gkebackup",
+	"iam",
+	"logging",
+	"serviceusage",
+}
+
+// pscAPIEndpoint returns the URL of the API through the Private Service
+// Connect endpoint.
+func pscAPIEndpoint(api, endpoint string) string {
+	return fmt.Sprintf("https://%s-%s.p.googleapis.com/", api, endpoint)
+}
+
+// setPrivateGoogleAPIsEnv makes gcloud call the Google APIs through the
+// Private Service Connect endpoint, since the public endpoints are not
+// reachable from inside a VPC Service Controls perimeter.
+func (d *deployer) setPrivateGoogleAPIsEnv() error {
+	if d.pscGoogleAPIsEndpoint == "" {
+		return nil
+	}
+	if !pscEndpointRe.MatchString(d.pscGoogleAPIsEndpoint) {
+		return fmt.Errorf("--psc-googleapis-endpoint must match %v, found %q", pscEndpointRe, d.pscGoogleAPIsEndpoint)
+	}
+
+	apis := pscAPIs
+	// The test and staging environments are not served by the endpoint.
+	if d.environment == "prod" {
+		apis = append([]string{"container"}, apis...)
+	}
+	for _, api := range apis {
+		key := "CLOUDSDK_API_ENDPOINT_OVERRIDES_" + strings.ToUpper(api)
+		if err := os.Setenv(key, pscAPIEndpoint(api, d.pscGoogleAPIsEndpoint)); err != nil {
+			return fmt.Errorf("could not set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// verifyVPCServiceControlsFlags validates the flags for running inside a VPC
+// Service Controls perimeter.
+func (d *deployer) verifyVPCServiceControlsFlags() error {
+	// Only private clusters have an internal endpoint.
+	if d.vpcServiceControls && d.privateClusterAccessLevel == "" {
+		return fmt.Errorf("--vpc-service-controls requires private clusters, set --private-cluster-access-level")
+	}
+	return nil
+}
+
+// credentialsArgs returns the extra args of get-credentials, which writes
+// the internal endpoint of the clusters to the kubeconfig inside a VPC
+// Service Controls perimeter, so kubectl reaches them through the VPC.
+func (d *deployer) credentialsArgs() []string {
+	if d.vpcServiceControls {
+		return []string{"--internal-ip"}
+	}
+	return nil
+}
+
+// clusterEndpoint returns the endpoint of the control plane of the cluster
+// reachable from where the run operates.
+func (d *deployer) clusterEndpoint(c *gkeCluster) string {
+	if d.vpcServiceControls {
+		return c.PrivateClusterConfig.PrivateEndpoint
+	}
+	return c.Endpoint
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetPrivateGoogleAPIsEnv(t *testing.T) {
+	const containerKey = "CLOUDSDK_API_ENDPOINT_OVERRIDES_CONTAINER"
+	const computeKey = "CLOUDSDK_API_ENDPOINT_OVERRIDES_COMPUTE"
+	for _, key := range []string{containerKey, computeKey} {
+		if value, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, value)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		environment       string
+		endpoint          string
+		expectedContainer string
+		expectedCompute   string
+		expectError       bool
+	}{
+		{
+			name:              "prod",
+			environment:       "prod",
+			endpoint:          "kt2psc",
+			expectedContainer: "https://container-kt2psc.p.googleapis.com/",
+			expectedCompute:   "https://compute-kt2psc.p.googleapis.com/",
+		},
+		{
+			name:              "staging keeps the container endpoint",
+			environment:       "staging",
+			endpoint:          "kt2psc",
+			expectedContainer: "https://staging-container.sandbox.googleapis.com/",
+			expectedCompute:   "https://compute-kt2psc.p.googleapis.com/",
+		},
+		{
+			name:        "invalid endpoint name",
+			environment: "prod",
+			endpoint:    "kt2-psc",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv(containerKey, "https://staging-container.sandbox.googleapis.com/")
+			os.Unsetenv(computeKey)
+			d := &deployer{environment: tc.environment, pscGoogleAPIsEndpoint: tc.endpoint}
+			err := d.setPrivateGoogleAPIsEnv()
+			if tc.expectError {
+				if err == nil {
+					t.Error("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := os.Getenv(containerKey); got != tc.expectedContainer {
+				t.Errorf("expected %s=%q, got %q", containerKey, tc.expectedContainer, got)
+			}
+			if got := os.Getenv(computeKey); got != tc.expectedCompute {
+				t.Errorf("expected %s=%q, got %q", computeKey, tc.expectedCompute, got)
+			}
+		})
+	}
+}
+
+func TestVerifyVPCServiceControlsFlags(t *testing.T) {
+	testCases := []struct {
+		name        string
+		d           *deployer
+		expectError bool
+	}{
+		{
+			name: "outside of a perimeter",
+			d:    &deployer{},
+		},
+		{
+			name: "private clusters",
+			d:    &deployer{vpcServiceControls: true, privateClusterAccessLevel: string(no)},
+		},
+		{
+			name:        "public clusters",
+			d:           &deployer{vpcServiceControls: true},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			err := tc.d.verifyVPCServiceControlsFlags()
+			if tc.expectError && err == nil {
+				st.Error("expected an error but got nil")
+			} else if !tc.expectError && err != nil {
+				st.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	windowsNodePoolName     = "windows-pool"
+	defaultWindowsImageType = "WINDOWS_LTSC_CONTAINERD"
+	// RDP and WinRM, used by the Windows e2e tests to access the nodes.
+	windowsAllow = "tcp:3389,tcp:5985-5986"
+	// The taint added to Windows nodes, so that Linux workloads do not get
+	// scheduled on them.
+	windowsNodeTaint = "node.kubernetes.io/os=windows:NoSchedule"
+)
+
+// windowsNodePoolArgs returns the args for creating the Windows node pool in
+// a cluster.
+// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/creating-a-cluster-windows
+func (d *deployer) windowsNodePoolArgs(project, loc, clusterName string) []string {
+	args := make([]string, 0)
+	if d.gcloudCommandGroup != "" {
+		args = append(args, d.gcloudCommandGroup)
+	}
+	args = append(args, "container", "node-pools", "create", windowsNodePoolName,
+		"--quiet",
+		"--cluster="+clusterName,
+		"--project="+project,
+		loc,
+		"--image-type="+d.windowsImageType,
+		"--machine-type="+d.windowsMachineType,
+		"--num-nodes="+strconv.Itoa(d.windowsNodes),
+		"--node-taints="+windowsNodeTaint,
+	)
+	args = append(args, d.alphaNodePoolArgs()...)
+	return args
+}
+
+// verifyWindowsFlags validates the flags for creating Windows node pools.
+func (d *deployer) verifyWindowsFlags() error {
+	if d.windowsNodes < 0 {
+		return fmt.Errorf("--windows-num-nodes must not be negative")
+	}
+	if d.windowsNodes == 0 {
+		return nil
+	}
+	if d.autopilot {
+		return fmt.Errorf("--windows-num-nodes is not supported for GKE Autopilot clusters")
+	}
+	if !strings.HasPrefix(strings.ToUpper(d.windowsImageType), "WINDOWS_") {
+		return fmt.Errorf("--windows-image-type must be a Windows image type, got %q", d.windowsImageType)
+	}
+	return nil
+}
+
+// recordNodeCounts records the number of nodes per OS of each cluster in the
+// metadata, so testers can compute the expected scheduling behavior.
+func (d *deployer) recordNodeCounts() error {
+	// cluster name -> OS -> number of nodes
+	counts := map[string]map[string]int{}
+	for _, project := range d.projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			counts[cluster.name] = map[string]int{}
+			for _, nodeOS := range []string{"linux", "windows"} {
+				lines, err := exec.OutputLines(kubectlCommand(d.clusterKubeconfig(project, cluster.name),
+					"get", "nodes", "--selector=kubernetes.io/os="+nodeOS, "--output=name"))
+				if err != nil {
+					return fmt.Errorf("error listing %s nodes of cluster %s: %w", nodeOS, cluster.name, err)
+				}
+				counts[cluster.name][nodeOS] = len(lines)
+			}
+		}
+	}
+	d.metadata.Add("node-counts", counts)
+	return nil
+}
@@ -23,5 +23,5 @@ import (
 )
 
 func main() {
-	app.Main(deployer.Name, deployer.New)
+	app.Main(deployer.Name, deployer.New, deployer.Subcommands...)
 }
@@ -0,0 +1,39 @@
+#!/bin/bash
+
+# Copyright 2021 The Kubernetes Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+set -o xtrace
+
+REPO_ROOT=$(git rev-parse --show-toplevel)
+cd "${REPO_ROOT}" &> /dev/null || exit 1
+
+make install
+make install-deployer-gkeonprem install-tester-exec
+
+# the admin cluster and the user cluster config are provided by the admin
+# workstation running the job
+kubetest2 gkeonprem \
+            -v 2 \
+            --platform "${GKEONPREM_PLATFORM:-vmware}" \
+            --admin-kubeconfig "${ADMIN_KUBECONFIG}" \
+            --cluster-name "${USER_CLUSTER_NAME}" \
+            --config "${USER_CLUSTER_CONFIG}" \
+            --up \
+            --down \
+            --test=exec \
+            -- kubectl get nodes
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deployer implements the kubetest2 deployer for GKE on-prem user
+// clusters, i.e. GKE on VMware (gkectl) and GKE on Bare Metal (bmctl),
+// created through an existing admin cluster.
+package deployer
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/octago/sflags/gen/gpflag"
+	"github.com/spf13/pflag"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/kubeconfig"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Name is the name of the deployer
+const Name = "gkeonprem"
+
+const (
+	platformVMware    = "vmware"
+	platformBareMetal = "baremetal"
+)
+
+// New implements deployer.New for gkeonprem
+func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
+	// create a deployer object and set fields that are not flag controlled
+	d := &deployer{
+		commonOptions: opts,
+		logsDir:       filepath.Join(opts.RunDir(), "logs"),
+		workDir:       filepath.Join(opts.RunDir(), "gkeonprem"),
+		Platform:      platformVMware,
+	}
+	// register flags and return
+	return d, bindFlags(d)
+}
+
+// assert that New implements types.NewDeployer
+var _ types.NewDeployer = New
+
+type deployer struct {
+	// generic parts
+	commonOptions types.Options
+	// on-prem specific details
+	Platform        string `desc:"The on-prem platform of the user cluster, one of 'vmware' (gkectl) and 'baremetal' (bmctl)"`
+	AdminKubeconfig string `flag:"admin-kubeconfig" desc:"Path to the kubeconfig of the admin cluster managing the user cluster"`
+	ClusterName     string `flag:"cluster-name" desc:"Name of the user cluster, it must match the name in --config"`
+	ConfigPath      string `flag:"config" desc:"Path to the user cluster configuration file used by gkectl create cluster or bmctl create cluster"`
+
+	// workDir is where the tools write the user cluster kubeconfig and workspace
+	workDir string
+	logsDir string
+}
+
+// verifyFlags validates the flags shared by all the phases.
+func (d *deployer) verifyFlags() error {
+	if d.Platform != platformVMware && d.Platform != platformBareMetal {
+		return fmt.Errorf("--platform must be one of %v", []string{platformVMware, platformBareMetal})
+	}
+	if d.AdminKubeconfig == "" {
+		return fmt.Errorf("--admin-kubeconfig must be set")
+	}
+	if d.ClusterName == "" {
+		return fmt.Errorf("--cluster-name must be set")
+	}
+	return nil
+}
+
+// Kubeconfig returns the path to a kubeconfig in the run dir holding the
+// context of the user cluster, named by kubeconfig.ContextName(Name, cluster),
+// which is merged from the kubeconfig written by gkectl / bmctl when the
+// cluster is created. It also sets the KUBECONFIG environment variable to it.
+func (d *deployer) Kubeconfig() (string, error) {
+	return kubeconfig.Write(filepath.Join(d.commonOptions.RunDir(), "kubeconfig"), kubeconfig.Source{
+		Path: d.userClusterKubeconfig(),
+		Name: kubeconfig.ContextName(Name, d.ClusterName),
+	})
+}
+
+// userClusterKubeconfig returns the path to the kubeconfig of the user
+// cluster written by gkectl / bmctl.
+func (d *deployer) userClusterKubeconfig() string {
+	if d.Platform == platformBareMetal {
+		return filepath.Join(d.bareMetalClusterDir(), d.ClusterName+"-kubeconfig")
+	}
+	return filepath.Join(d.workDir, d.ClusterName+"-kubeconfig")
+}
+
+// bareMetalClusterDir returns the directory of the user cluster in the bmctl
+// workspace, which bmctl reads the configuration from.
+func (d *deployer) bareMetalClusterDir() string {
+	return filepath.Join(d.workDir, "bmctl-workspace", d.ClusterName)
+}
+
+func (d *deployer) Build() error {
+	return fmt.Errorf("building is not supported, the GKE on-prem release is installed from the admin workstation")
+}
+
+// helper used to create & bind a flagset to the deployer
+func bindFlags(d *deployer) *pflag.FlagSet {
+	flags, err := gpflag.Parse(d)
+	if err != nil {
+		klog.Fatalf("unable to generate flags from deployer")
+		return nil
+	}
+
+	klog.InitFlags(nil)
+	flags.AddGoFlagSet(flag.CommandLine)
+
+	return flags
+}
+
+// assert that deployer implements types.DeployerWithKubeconfig
+var _ types.DeployerWithKubeconfig = &deployer{}
@@ -0,0 +1,206 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// fakeCmder records the commands and their working directory instead of
+// running them.
+type fakeCmder struct {
+	commands []string
+	dirs     []string
+}
+
+func (f *fakeCmder) Command(name string, args ...string) exec.Cmd {
+	return f.CommandContext(context.Background(), name, args...)
+}
+
+func (f *fakeCmder) CommandContext(_ context.Context, name string, args ...string) exec.Cmd {
+	return &fakeCmd{cmder: f, args: append([]string{name}, args...)}
+}
+
+type fakeCmd struct {
+	cmder *fakeCmder
+	args  []string
+	dir   string
+}
+
+func (c *fakeCmd) Run() error {
+	c.cmder.commands = append(c.cmder.commands, strings.Join(c.args, " "))
+	c.cmder.dirs = append(c.cmder.dirs, c.dir)
+	return nil
+}
+
+func (c *fakeCmd) SetEnv(...string) exec.Cmd    { return c }
+func (c *fakeCmd) SetStdin(io.Reader) exec.Cmd  { return c }
+func (c *fakeCmd) SetStdout(io.Writer) exec.Cmd { return c }
+func (c *fakeCmd) SetStderr(io.Writer) exec.Cmd { return c }
+func (c *fakeCmd) SetDir(dir string) exec.Cmd   { c.dir = dir; return c }
+
+func TestVerifyFlags(t *testing.T) {
+	testCases := []struct {
+		name      string
+		d         deployer
+		expectErr bool
+	}{
+		{
+			name: "vmware",
+			d:    deployer{Platform: platformVMware, AdminKubeconfig: "admin", ClusterName: "user"},
+		},
+		{
+			name: "baremetal",
+			d:    deployer{Platform: platformBareMetal, AdminKubeconfig: "admin", ClusterName: "user"},
+		},
+		{
+			name:      "unknown platform",
+			d:         deployer{Platform: "aws", AdminKubeconfig: "admin", ClusterName: "user"},
+			expectErr: true,
+		},
+		{
+			name:      "no admin kubeconfig",
+			d:         deployer{Platform: platformVMware, ClusterName: "user"},
+			expectErr: true,
+		},
+		{
+			name:      "no cluster name",
+			d:         deployer{Platform: platformVMware, AdminKubeconfig: "admin"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			err := tc.d.verifyFlags()
+			if (err != nil) != tc.expectErr {
+				st.Errorf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestUserClusterKubeconfig(t *testing.T) {
+	testCases := []struct {
+		platform string
+		expected string
+	}{
+		{
+			platform: platformVMware,
+			expected: "/run/gkeonprem/user-kubeconfig",
+		},
+		{
+			platform: platformBareMetal,
+			expected: "/run/gkeonprem/bmctl-workspace/user/user-kubeconfig",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.platform, func(st *testing.T) {
+			st.Parallel()
+			d := &deployer{Platform: tc.platform, ClusterName: "user", workDir: "/run/gkeonprem"}
+			if actual := d.userClusterKubeconfig(); actual != tc.expected {
+				st.Errorf("expected kubeconfig %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gkeonprem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "user.yaml")
+	if err := ioutil.WriteFile(config, []byte("kind: Cluster\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		platform string
+		expected []string
+	}{
+		{
+			platform: platformVMware,
+			expected: []string{
+				"gkectl create cluster --kubeconfig=admin --config=" + config,
+				"gkectl delete cluster --kubeconfig=admin --cluster=user",
+			},
+		},
+		{
+			platform: platformBareMetal,
+			expected: []string{
+				"bmctl create cluster --cluster=user --kubeconfig=admin",
+				"bmctl reset cluster --cluster=user --admin-kubeconfig=admin",
+			},
+		},
+	}
+
+	cmder := exec.DefaultCmder
+	defer func() { exec.DefaultCmder = cmder }()
+	for _, tc := range testCases {
+		workDir := filepath.Join(dir, tc.platform)
+		d := &deployer{
+			Platform:        tc.platform,
+			AdminKubeconfig: "admin",
+			ClusterName:     "user",
+			ConfigPath:      config,
+			workDir:         workDir,
+		}
+		f := &fakeCmder{}
+		exec.DefaultCmder = f
+		if err := d.Up(); err != nil {
+			t.Fatalf("%s: unexpected error creating the cluster: %v", tc.platform, err)
+		}
+		if err := d.Down(); err != nil {
+			t.Fatalf("%s: unexpected error deleting the cluster: %v", tc.platform, err)
+		}
+		if diff := cmp.Diff(tc.expected, f.commands); diff != "" {
+			t.Errorf("%s: commands differ (-want, +got):\n%s", tc.platform, diff)
+		}
+		if diff := cmp.Diff([]string{workDir, workDir}, f.dirs); diff != "" {
+			t.Errorf("%s: working directories differ (-want, +got):\n%s", tc.platform, diff)
+		}
+	}
+
+	// bmctl reads the configuration from its workspace.
+	copied, err := ioutil.ReadFile(filepath.Join(dir, platformBareMetal, "bmctl-workspace", "user", "user.yaml"))
+	if err != nil || string(copied) != "kind: Cluster\n" {
+		t.Errorf("expected the config to be copied into the bmctl workspace, got %q, %v", copied, err)
+	}
+}
+
+func TestUpRequiresConfig(t *testing.T) {
+	d := &deployer{Platform: platformVMware, AdminKubeconfig: "admin", ClusterName: "user"}
+	if err := d.Up(); err == nil {
+		t.Error("expected an error without --config")
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+func (d *deployer) Down() error {
+	if err := d.verifyFlags(); err != nil {
+		return err
+	}
+
+	var cmd exec.Cmd
+	switch d.Platform {
+	case platformVMware:
+		cmd = exec.CommandContext(types.Context(d.commonOptions), "gkectl", "delete", "cluster",
+			"--kubeconfig="+d.AdminKubeconfig,
+			"--cluster="+d.ClusterName,
+		)
+	case platformBareMetal:
+		cmd = exec.CommandContext(types.Context(d.commonOptions), "bmctl", "reset", "cluster",
+			"--cluster="+d.ClusterName,
+			"--admin-kubeconfig="+d.AdminKubeconfig,
+		)
+	}
+	cmd.SetDir(d.workDir)
+	exec.InheritOutput(cmd)
+
+	klog.V(0).Infof("Down(): deleting %s user cluster %s...\n", d.Platform, d.ClusterName)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error deleting the user cluster: %w", err)
+	}
+	return nil
+}
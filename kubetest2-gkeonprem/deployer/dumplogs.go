@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"os"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// DumpClusterLogs takes a diagnostic snapshot of the user cluster into the
+// logs directory.
+func (d *deployer) DumpClusterLogs() error {
+	if err := d.verifyFlags(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.logsDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var cmd exec.Cmd
+	switch d.Platform {
+	case platformVMware:
+		cmd = exec.CommandContext(types.Context(d.commonOptions), "gkectl", "diagnose", "snapshot",
+			"--kubeconfig="+d.AdminKubeconfig,
+			"--cluster-name="+d.ClusterName,
+		)
+	case platformBareMetal:
+		cmd = exec.CommandContext(types.Context(d.commonOptions), "bmctl", "check", "cluster",
+			"--snapshot",
+			"--cluster="+d.ClusterName,
+			"--admin-kubeconfig="+d.AdminKubeconfig,
+		)
+	}
+	// both tools write the snapshot into the working directory
+	cmd.SetDir(d.logsDir)
+	exec.InheritOutput(cmd)
+
+	klog.V(0).Infof("DumpClusterLogs(): taking a snapshot of %s user cluster %s...\n", d.Platform, d.ClusterName)
+	return cmd.Run()
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/fs"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+func (d *deployer) IsUp() (up bool, err error) {
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return false, err
+	}
+	// naively assume that if the api server reports nodes, the cluster is up
+	lines, err := exec.CombinedOutputLines(
+		exec.Command("kubectl", "--kubeconfig="+kubeconfig, "get", "nodes", "-o=name"),
+	)
+	if err != nil {
+		return false, metadata.NewJUnitError(err, strings.Join(lines, "\n"))
+	}
+	return len(lines) > 0, nil
+}
+
+func (d *deployer) Up() error {
+	if err := d.verifyFlags(); err != nil {
+		return err
+	}
+	if d.ConfigPath == "" {
+		return fmt.Errorf("--config must be set for up")
+	}
+	if err := os.MkdirAll(d.workDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var cmd exec.Cmd
+	switch d.Platform {
+	case platformVMware:
+		cmd = exec.CommandContext(types.Context(d.commonOptions), "gkectl", "create", "cluster",
+			"--kubeconfig="+d.AdminKubeconfig,
+			"--config="+d.ConfigPath,
+		)
+	case platformBareMetal:
+		// bmctl reads the configuration from its workspace.
+		if err := os.MkdirAll(d.bareMetalClusterDir(), os.ModePerm); err != nil {
+			return err
+		}
+		if err := fs.CopyFile(d.ConfigPath, filepath.Join(d.bareMetalClusterDir(), d.ClusterName+".yaml")); err != nil {
+			return fmt.Errorf("failed to copy the cluster config into the bmctl workspace: %w", err)
+		}
+		cmd = exec.CommandContext(types.Context(d.commonOptions), "bmctl", "create", "cluster",
+			"--cluster="+d.ClusterName,
+			"--kubeconfig="+d.AdminKubeconfig,
+		)
+	}
+	cmd.SetDir(d.workDir)
+	exec.InheritOutput(cmd)
+
+	klog.V(0).Infof("Up(): creating %s user cluster %s...\n", d.Platform, d.ClusterName)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error creating the user cluster: %w", err)
+	}
+	return nil
+}
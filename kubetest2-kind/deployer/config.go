@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"sigs.k8s.io/kubetest2/pkg/featuregates"
+)
+
+// clusterConfigPath returns the --config for kind create cluster. If
+// --feature-gates or --runtime-config are set, they are patched into the
+// cluster config of --config, or into an empty one, which is written to the
+// run dir.
+// Reference: https://kind.sigs.k8s.io/docs/user/configuration/#feature-gates
+func (d *deployer) clusterConfigPath() (string, error) {
+	if d.FeatureGates == "" && d.RuntimeConfig == "" {
+		return d.ConfigPath, nil
+	}
+	gates, err := featuregates.Parse(d.FeatureGates)
+	if err != nil {
+		return "", fmt.Errorf("invalid --feature-gates: %v", err)
+	}
+	runtimeConfig, err := featuregates.ParseRuntimeConfig(d.RuntimeConfig)
+	if err != nil {
+		return "", fmt.Errorf("invalid --runtime-config: %v", err)
+	}
+
+	var config []byte
+	if d.ConfigPath != "" {
+		if config, err = ioutil.ReadFile(d.ConfigPath); err != nil {
+			return "", fmt.Errorf("failed to read --config: %v", err)
+		}
+	}
+	patched, err := patchClusterConfig(config, gates, runtimeConfig)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(d.commonOptions.RunDir(), "kind-config.yaml")
+	if err := ioutil.WriteFile(path, patched, 0644); err != nil {
+		return "", fmt.Errorf("failed to write the kind cluster config: %v", err)
+	}
+	return path, nil
+}
+
+// patchClusterConfig sets the feature gates and the runtime config in the kind
+// cluster config, overriding the ones of the same name already set in it.
+func patchClusterConfig(config []byte, gates map[string]bool, runtimeConfig map[string]string) ([]byte, error) {
+	cluster := yaml.MapSlice{}
+	if err := yaml.Unmarshal(config, &cluster); err != nil {
+		return nil, fmt.Errorf("failed to parse the kind cluster config: %v", err)
+	}
+	if len(cluster) == 0 {
+		cluster = yaml.MapSlice{
+			{Key: "kind", Value: "Cluster"},
+			{Key: "apiVersion", Value: "kind.x-k8s.io/v1alpha4"},
+		}
+	}
+
+	patch := func(key string, values map[string]interface{}) error {
+		if len(values) == 0 {
+			return nil
+		}
+		for i, item := range cluster {
+			if item.Key != key {
+				continue
+			}
+			existing, ok := item.Value.(yaml.MapSlice)
+			if !ok && item.Value != nil {
+				return fmt.Errorf("%s of the kind cluster config must be a map", key)
+			}
+			for _, e := range existing {
+				k := fmt.Sprint(e.Key)
+				if _, ok := values[k]; !ok {
+					values[k] = e.Value
+				}
+			}
+			cluster[i].Value = values
+			return nil
+		}
+		cluster = append(cluster, yaml.MapItem{Key: key, Value: values})
+		return nil
+	}
+
+	gateValues := make(map[string]interface{}, len(gates))
+	for k, v := range gates {
+		gateValues[k] = v
+	}
+	if err := patch("featureGates", gateValues); err != nil {
+		return nil, err
+	}
+	runtimeValues := make(map[string]interface{}, len(runtimeConfig))
+	for k, v := range runtimeConfig {
+		runtimeValues[k] = v
+	}
+	if err := patch("runtimeConfig", runtimeValues); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(cluster)
+}
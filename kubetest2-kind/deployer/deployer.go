@@ -26,6 +26,7 @@ import (
 	"github.com/spf13/pflag"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/kubetest2/pkg/kubeconfig"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
@@ -38,6 +39,8 @@ func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
 	d := &deployer{
 		commonOptions: opts,
 		logsDir:       filepath.Join(opts.RunDir(), "logs"),
+		// before Kubeconfig() sets it to the kubeconfig of the run
+		userKubeconfig: os.Getenv("KUBECONFIG"),
 	}
 	// register flags and return
 	return d, bindFlags(d)
@@ -56,13 +59,46 @@ type deployer struct {
 	ConfigPath     string `flag:"config" desc:"--config for kind create cluster"`
 	KubeconfigPath string `flag:"kubeconfig" desc:"--kubeconfig flag for kind create cluster"`
 	KubeRoot       string `desc:"--kube-root for kind build node-image"`
+	FeatureGates   string `desc:"feature gates patched into the cluster config of --config, e.g. Gate1=true,Gate2=false"`
+	RuntimeConfig  string `desc:"runtime config patched into the cluster config of --config, e.g. api/all=true"`
 
 	logsDir string
+	// userKubeconfig is the KUBECONFIG kubetest2 was started with
+	userKubeconfig string
 }
 
+// Kubeconfig returns the path to a kubeconfig in the run dir holding the
+// context of the cluster, named by kubeconfig.ContextName(Name, cluster),
+// which is merged from the kubeconfig written by kind. It also sets the
+// KUBECONFIG environment variable to it.
 func (d *deployer) Kubeconfig() (string, error) {
+	source, err := d.kindKubeconfig()
+	if err != nil {
+		return "", err
+	}
+	return kubeconfig.Write(filepath.Join(d.commonOptions.RunDir(), "kubeconfig"), kubeconfig.Source{
+		Path:    source,
+		Context: "kind-" + d.clusterName(),
+		Name:    kubeconfig.ContextName(Name, d.clusterName()),
+	})
+}
+
+// kindKubeconfig returns the kubeconfig kind writes the cluster context to,
+// selected as kind does: --kubeconfig, or the first existing file of the
+// user's KUBECONFIG (the first one if none exists), or ~/.kube/config.
+// It is passed explicitly to kind, so that kind does not write to the
+// kubeconfig of the run once KUBECONFIG is set to it.
+func (d *deployer) kindKubeconfig() (string, error) {
 	if d.KubeconfigPath != "" {
-		return d.KubeconfigPath, nil
+		return filepath.Abs(d.KubeconfigPath)
+	}
+	if paths := filepath.SplitList(d.userKubeconfig); len(paths) > 0 {
+		for _, path := range paths {
+			if _, err := os.Stat(path); err == nil {
+				return filepath.Abs(path)
+			}
+		}
+		return filepath.Abs(paths[0])
 	}
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -71,6 +107,14 @@ func (d *deployer) Kubeconfig() (string, error) {
 	return filepath.Join(home, ".kube", "config"), nil
 }
 
+// clusterName returns the kind cluster --name, defaulting to the kind one.
+func (d *deployer) clusterName() string {
+	if d.ClusterName != "" {
+		return d.ClusterName
+	}
+	return "kind"
+}
+
 // helper used to create & bind a flagset to the deployer
 func bindFlags(d *deployer) *pflag.FlagSet {
 	flags, err := gpflag.Parse(d)
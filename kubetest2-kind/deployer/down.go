@@ -28,6 +28,11 @@ func (d *deployer) Down() error {
 		"delete", "cluster",
 		"--name", d.ClusterName,
 	}
+	kindKubeconfig, err := d.kindKubeconfig()
+	if err != nil {
+		return err
+	}
+	args = append(args, "--kubeconfig", kindKubeconfig)
 
 	klog.V(0).Infof("Down(): deleting kind cluster...\n")
 	// we want to see the output so use process.ExecJUnit
@@ -27,9 +27,15 @@ import (
 )
 
 func (d *deployer) IsUp() (up bool, err error) {
+	// the kubeconfig written by kind is read as is, Kubeconfig() writes files
+	// and sets KUBECONFIG
+	kindKubeconfig, err := d.kindKubeconfig()
+	if err != nil {
+		return false, err
+	}
 	// naively assume that if the api server reports nodes, the cluster is up
 	lines, err := exec.CombinedOutputLines(
-		exec.Command("kubectl", "get", "nodes", "-o=name"),
+		exec.Command("kubectl", "--kubeconfig="+kindKubeconfig, "--context=kind-"+d.clusterName(), "get", "nodes", "-o=name"),
 	)
 	if err != nil {
 		return false, metadata.NewJUnitError(err, strings.Join(lines, "\n"))
@@ -52,12 +58,18 @@ func (d *deployer) Up() error {
 		// we use the same logic / constant for Build()
 		args = append(args, "--image", kindDefaultBuiltImageName)
 	}
-	if d.ConfigPath != "" {
-		args = append(args, "--config", d.ConfigPath)
+	configPath, err := d.clusterConfigPath()
+	if err != nil {
+		return err
 	}
-	if d.KubeconfigPath != "" {
-		args = append(args, "--kubeconfig", d.KubeconfigPath)
+	if configPath != "" {
+		args = append(args, "--config", configPath)
+	}
+	kindKubeconfig, err := d.kindKubeconfig()
+	if err != nil {
+		return err
 	}
+	args = append(args, "--kubeconfig", kindKubeconfig)
 
 	klog.V(0).Infof("Up(): creating kind cluster...\n")
 	// we want to see the output so use process.ExecJUnit
@@ -20,20 +20,36 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/kubetest2/pkg/artifacts"
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/kubeconfig"
 	"sigs.k8s.io/kubetest2/pkg/metadata"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
+// Subcommand is an additional command of a deployer binary, e.g.
+// `kubetest2 gke verify-down`, which is run with the parsed flags instead of
+// the build / up / test / down flow.
+type Subcommand struct {
+	// Name is the first argument selecting the subcommand
+	Name string
+	// Short is the description shown in the usage
+	Short string
+	// Run runs the subcommand with the deployer instanced from the flags
+	Run func(d types.Deployer) error
+}
+
 // Main implements the kubetest2 deployer binary entrypoint
 // Each deployer binary should invoke this, in addition to loading deployers
-func Main(deployerName string, newDeployer types.NewDeployer) {
+func Main(deployerName string, newDeployer types.NewDeployer, subcommands ...Subcommand) {
 	// see cmd.go for the rest of the CLI boilerplate
-	if err := Run(deployerName, newDeployer); err != nil {
+	if err := Run(deployerName, newDeployer, subcommands...); err != nil {
 		// only print the error if it's not an IncorrectUsage (which we've)
 		// already output along with usage
 		if _, isUsage := err.(types.IncorrectUsage); !isUsage {
@@ -54,7 +70,6 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 		 - cluster down
 		Throughout this, collecting metadata and writing it out on exit
 	*/
-	// TODO(bentheelder): signal handling & timeout
 
 	klog.Infof("RunDir for this run: %q", opts.RunDir())
 
@@ -63,9 +78,15 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 		return err
 	}
 
+	// the junit files may be kept apart from the other artifacts
+	junitDir := artifacts.JUnitDir(opts.RunID())
+	if err := os.MkdirAll(junitDir, os.ModePerm); err != nil {
+		return err
+	}
+
 	// setup the metadata writer
 	junitRunner, err := os.Create(
-		filepath.Join(opts.RunDir(), "junit_runner.xml"),
+		filepath.Join(junitDir, "junit_runner.xml"),
 	)
 	if err != nil {
 		return errors.Wrap(err, "could not create runner output")
@@ -86,12 +107,36 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 			result = err
 		}
 	}()
+	// record the run once it's torn down, before the metadata is finished
+	started := time.Now()
+	if recorder, ok := opts.(runRecorder); ok {
+		defer func() {
+			if err := writeRunRecord(opts, recorder, writer, d, started, result); err != nil && result == nil {
+				result = err
+			}
+		}()
+	}
+
+	// assemble the triage bundle once the run is torn down, if it failed
+	triage := newTriageBundle(opts)
+	defer func() {
+		if result == nil {
+			return
+		}
+		if err := triage.write(result); err != nil {
+			klog.Warningf("Failed to write the triage bundle: %v", err)
+		}
+	}()
 
 	klog.Infof("ID for this run: %q", opts.RunID())
 
+	parallelBuild := opts.ShouldBuild() && opts.ShouldUp() && shouldBuildInParallel(opts, d)
+	// mark the output of each phase so that it can be folded in the logs
+	markers := newPhaseMarkers(opts, os.Stdout)
+
 	// build if specified
-	if opts.ShouldBuild() {
-		if err := writer.WrapStep("Build", d.Build); err != nil {
+	if opts.ShouldBuild() && !parallelBuild {
+		if err := writer.WrapStep("Build", markers.wrap("Build", false, d.Build)); err != nil {
 			// we do not continue to up / test etc. if build fails
 			return err
 		}
@@ -100,26 +145,60 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 	// ensure tearing down the cluster happens last, even if up or test fails.
 	defer func() {
 		if opts.ShouldDown() {
+			// the run may have been cancelled, make sure down gets a usable context
+			if o, ok := opts.(teardownStarter); ok {
+				o.startTeardown()
+			}
 			// TODO(bentheelder): instead of keeping the first error, consider
 			// a multi-error type
-			if err := writer.WrapStep("Down", d.Down); err != nil && result == nil {
+			if err := writer.WrapStep("Down", markers.wrap("Down", false, d.Down)); err != nil && result == nil {
 				result = err
 			}
 		}
 	}()
+	// the cluster state is only available before the cluster is torn down
+	defer func() {
+		if result != nil {
+			triage.collectClusterState(d)
+		}
+	}()
 
 	// up a cluster
 	if opts.ShouldUp() {
+		// the build runs while the cluster comes up
+		var buildErr error
+		var buildDone sync.WaitGroup
+		if parallelBuild {
+			buildDone.Add(1)
+			go func() {
+				defer buildDone.Done()
+				buildErr = writer.WrapStep("Build", markers.wrap("Build", true, d.Build))
+			}()
+		}
 		// TODO(bentheelder): this should write out to JUnit
-		if err := writer.WrapStep("Up", d.Up); err != nil {
+		upErr := writer.WrapStep("Up", markers.wrap("Up", parallelBuild, d.Up))
+		buildDone.Wait()
+		// we do not continue to test if build fails
+		if buildErr != nil && upErr == nil {
+			upErr = buildErr
+		}
+		// the properties describe the clusters even if they failed to come up
+		if err := addJUnitProperties(writer, d); err != nil && upErr == nil {
+			upErr = err
+		}
+		if upErr != nil {
 			// we do not continue to test if build fails
+			return upErr
+		}
+		if err := writeDeployerMetadata(opts, d); err != nil {
 			return err
 		}
 	}
 
 	// and finally test, if a test was specified
 	if opts.ShouldTest() {
-		test := exec.Command(tester.TesterPath, tester.TesterArgs...)
+		// the tester output is not teed, it's the main output of the run
+		test := (&exec.LocalCmder{}).CommandContext(types.Context(opts), tester.TesterPath, tester.TesterArgs...)
 		exec.InheritOutput(test)
 
 		envsForTester := os.Environ()
@@ -130,22 +209,45 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 		envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "ARTIFACTS", opts.RunDir()))
 		envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "KUBETEST2_RUN_DIR", opts.RunDir()))
 		envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "KUBETEST2_RUN_ID", opts.RunID()))
+		// the tester writes its junit files to its artifacts dir unless the
+		// layout keeps them apart
+		if junitDir != opts.RunDir() {
+			envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "KUBETEST2_JUNIT_DIR", junitDir))
+		}
 		// If the deployer provides a kubeconfig pass it to the tester
 		// else assumes that it is handled offline by default methods like
 		// ~/.kube/config
 		if dWithKubeconfig, ok := d.(types.DeployerWithKubeconfig); ok {
 			if kconfig, err := dWithKubeconfig.Kubeconfig(); err == nil {
+				// the contract is only enforced for the in-tree deployers,
+				// the others may predate it
+				if err := kubeconfig.Verify(kconfig); err != nil {
+					klog.Warningf("The kubeconfig returned by the deployer does not follow the Kubeconfig() contract: %v", err)
+				}
 				envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "KUBECONFIG", kconfig))
 			}
-
 		}
+		if dWithTesterEnv, ok := d.(types.DeployerWithTesterEnv); ok {
+			env, err := dWithTesterEnv.TesterEnv()
+			if err != nil {
+				return errors.Wrap(err, "could not get the deployer environment for the tester")
+			}
+			envsForTester = append(envsForTester, env...)
+		}
+		// the kubectl versions of the client-skew tests
+		kubectlEnv, err := acquireSkewKubectls(opts)
+		if err != nil {
+			return errors.Wrap(err, "could not acquire the kubectl versions for the tester")
+		}
+		envsForTester = append(envsForTester, kubectlEnv...)
 		test.SetEnv(envsForTester...)
 
 		var testErr error
+		runTest := markers.wrap("Test", false, test.Run)
 		if !opts.SkipTestJUnitReport() {
-			testErr = writer.WrapStep("Test", test.Run)
+			testErr = writer.WrapStep("Test", runTest)
 		} else {
-			testErr = test.Run()
+			testErr = runTest()
 		}
 
 		if dWithPostTester, ok := d.(types.DeployerWithPostTester); ok {
@@ -159,3 +261,59 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 	}
 	return nil
 }
+
+// shouldBuildInParallel returns true if the build can run concurrently with
+// the cluster coming up, as requested with --parallel-build
+func shouldBuildInParallel(opts types.Options, d types.Deployer) bool {
+	if !types.ParallelBuild(opts) {
+		return false
+	}
+	if dWithParallelBuild, ok := d.(types.DeployerWithParallelBuild); !ok || !dWithParallelBuild.SupportsParallelBuild() {
+		klog.Warningf("--parallel-build ignored, the deployer does not support building while the cluster comes up")
+		return false
+	}
+	return true
+}
+
+// teardownStarter is implemented by the options whose Context() is swapped
+// to a separate context for tearing down the cluster
+type teardownStarter interface {
+	startTeardown()
+}
+
+// addJUnitProperties adds the junit properties of the deployer metadata, if
+// the deployer provides any, to the junit_runner.xml
+func addJUnitProperties(writer *metadata.Writer, d types.Deployer) error {
+	dWithMetadata, ok := d.(types.DeployerWithMetadata)
+	if !ok {
+		return nil
+	}
+	m, err := dWithMetadata.Metadata()
+	if err != nil {
+		return errors.Wrap(err, "could not get deployer metadata")
+	}
+	names, values := m.JUnitProperties()
+	for _, name := range names {
+		writer.AddProperty(name, values[name])
+	}
+	return nil
+}
+
+// writeDeployerMetadata writes out the deployer metadata, if the deployer
+// provides any, as metadata.json in the run dir
+func writeDeployerMetadata(opts types.Options, d types.Deployer) error {
+	dWithMetadata, ok := d.(types.DeployerWithMetadata)
+	if !ok {
+		return nil
+	}
+	m, err := dWithMetadata.Metadata()
+	if err != nil {
+		return errors.Wrap(err, "could not get deployer metadata")
+	}
+	f, err := os.Create(filepath.Join(opts.RunDir(), "metadata.json"))
+	if err != nil {
+		return errors.Wrap(err, "could not create metadata output")
+	}
+	defer f.Close()
+	return m.Write(f)
+}
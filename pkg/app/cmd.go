@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -30,17 +31,18 @@ import (
 
 	"sigs.k8s.io/kubetest2/pkg/app/shim"
 	"sigs.k8s.io/kubetest2/pkg/artifacts"
+	"sigs.k8s.io/kubetest2/pkg/credentials"
 	"sigs.k8s.io/kubetest2/pkg/exec"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
 // Run instantiates and executes the kubetest2 cobra command, returning the result
-func Run(deployerName string, newDeployer types.NewDeployer) error {
-	return NewCommand(deployerName, newDeployer).Execute()
+func Run(deployerName string, newDeployer types.NewDeployer, subcommands ...Subcommand) error {
+	return NewCommand(deployerName, newDeployer, subcommands...).Execute()
 }
 
 // NewCommand returns a new cobra.Command for kubetest2
-func NewCommand(deployerName string, newDeployer types.NewDeployer) *cobra.Command {
+func NewCommand(deployerName string, newDeployer types.NewDeployer, subcommands ...Subcommand) *cobra.Command {
 	cmd := &cobra.Command{
 		Use: fmt.Sprintf("%s %s", shim.BinaryName, deployerName),
 		// we defer showing usage, so that we can include deployer and test
@@ -48,7 +50,7 @@ func NewCommand(deployerName string, newDeployer types.NewDeployer) *cobra.Comma
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runE(cmd, args, deployerName, newDeployer)
+			return runE(cmd, args, deployerName, newDeployer, subcommands)
 		},
 	}
 	// we implement custom flag parsing below
@@ -59,10 +61,31 @@ func NewCommand(deployerName string, newDeployer types.NewDeployer) *cobra.Comma
 // runE implements the custom CLI logic
 func runE(
 	cmd *cobra.Command, args []string,
-	deployerName string, newDeployer types.NewDeployer,
+	deployerName string, newDeployer types.NewDeployer, subcommands []Subcommand,
 ) error {
+	// delete the secrets written to temp files for the tools only taking files
+	defer credentials.Cleanup()
+
+	// split out the subcommand, if any
+	var subcommand *Subcommand
+	if len(args) > 0 {
+		for i := range subcommands {
+			if args[0] == subcommands[i].Name {
+				subcommand = &subcommands[i]
+				args = args[1:]
+				break
+			}
+		}
+	}
+
+	// load the flags of the preset, if any, before the user flags
+	args, err := expandPreset(args)
+	if err != nil {
+		return err
+	}
+
 	// setup the options struct & flags, etc.
-	opts := &options{}
+	opts := &options{deployerName: deployerName, configHash: configHash(deployerName, args)}
 	kubetest2Flags := pflag.NewFlagSet(deployerName, pflag.ContinueOnError)
 	opts.bindFlags(kubetest2Flags)
 	artifacts.MustBindFlags(kubetest2Flags)
@@ -77,6 +100,7 @@ func runE(
 	usage := &usage{
 		deployerName:   deployerName,
 		kubetest2Flags: kubetest2Flags,
+		subcommands:    subcommands,
 	}
 
 	// parse the kubetest2 common flags flags
@@ -137,7 +161,7 @@ func runE(
 	}
 
 	// print usage and return if no args are provided, or help is explicitly requested
-	if len(args) == 0 || opts.HelpRequested() {
+	if (len(args) == 0 && subcommand == nil) || opts.HelpRequested() {
 		cmd.Print(usage.String())
 		return nil
 	}
@@ -162,6 +186,34 @@ func runE(
 		return parseError
 	}
 
+	if err := verifyRunRegistry(opts.runRegistrySink); err != nil {
+		return err
+	}
+	if err := verifyPhaseMarkers(opts.phaseMarkersFormat); err != nil {
+		return err
+	}
+	if err := verifyKubectlSkew(opts.kubectlSkewVersion); err != nil {
+		return err
+	}
+	if err := artifacts.VerifyLayoutFlag(); err != nil {
+		return err
+	}
+
+	if opts.teeCommandOutput {
+		exec.DefaultCmder = &exec.LocalCmder{
+			OutputDir:      filepath.Join(opts.RunDir(), "commands"),
+			CondensedLines: exec.DefaultCondensedLines,
+		}
+	}
+
+	stop := opts.startContext()
+	defer stop()
+	// bind the commands of the deployer to the context of the run, so that
+	// they are interrupted on --timeout and on SIGINT / SIGTERM
+	exec.DefaultCmder = exec.WithContext(exec.DefaultCmder, opts.Context)
+	if subcommand != nil {
+		return subcommand.Run(deployer)
+	}
 	// run RealMain, which contains all of the logic beyond the CLI boilerplate
 	return RealMain(opts, deployer, tester)
 }
@@ -190,7 +242,20 @@ type options struct {
 	down                bool
 	test                string
 	skipTestJUnitReport bool
+	parallelBuild       bool
 	runid               string
+	timeout             time.Duration
+	teeCommandOutput    bool
+	preset              string
+	runRegistrySink     string
+	phaseMarkersFormat  string
+	triageLines         int
+	kubectlSkewVersion  string
+	ctx                 *runContext
+
+	// set by runE to describe the run in the run record
+	deployerName string
+	configHash   string
 }
 
 // bindFlags registers all first class kubetest2 flags
@@ -202,6 +267,8 @@ func (o *options) bindFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&o.test, "test", "", "test type to run, if unset no tests will run")
 	flags.BoolVar(&o.skipTestJUnitReport, "skip-test-junit-report", false, "skip reporting the test step as a JUnit test case, "+
 		"should be set to true when solely relying on the tester binary to generate it's own junit.")
+	flags.BoolVar(&o.parallelBuild, "parallel-build", false, "run the build concurrently with provisioning the test cluster, "+
+		"for the builds only consumed by the tests (e.g. of the test binaries). Ignored unless the deployer supports it.")
 
 	var defaultRunID string
 	// reuse uid for CI use cases
@@ -211,10 +278,27 @@ func (o *options) bindFlags(flags *pflag.FlagSet) {
 		defaultRunID = uuid.New().String()
 	}
 	flags.StringVar(&o.runid, "run-id", defaultRunID, "unique identifier for a kubetest2 run")
+	flags.DurationVar(&o.timeout, "timeout", 0, "maximum duration of the run, after which the build, up and test are cancelled "+
+		"and the cluster is torn down, if unset the run never times out")
+	flags.BoolVar(&o.teeCommandOutput, "tee-command-output", false, "tee the output of each command run by kubetest2 and the deployer into its own file under commands/ in the artifacts, "+
+		fmt.Sprintf("and only print the first %d lines of it", exec.DefaultCondensedLines))
+	flags.StringVar(&o.preset, "preset", "", "name of a published preset (a set of kubetest2, deployer and tester flags for a common job shape, e.g. gke-conformance), "+
+		fmt.Sprintf("or a gs:// URL or path to a preset YAML file. Presets referenced by name are loaded from %s or $KUBETEST2_PRESETS_LOCATION. ", DefaultPresetsLocation)+
+		"The flags on the command line override the ones of the preset.")
+	flags.StringVar(&o.runRegistrySink, "run-registry", "", "if set, add the summary of the run (config hash, result, step durations, retries and zones) to this run registry, "+
+		"either a gs://bucket/prefix/ prefix, under which each run writes <run-id>.json, or a bq://project.dataset.table BigQuery table. The summary is always written to run-summary.json in the run dir.")
+	flags.StringVar(&o.phaseMarkersFormat, "phase-markers", defaultPhaseMarkers(), "format of the markers printed around the output of the build, up, test and down phases "+
+		"so that log viewers can fold them, one of 'plain', 'github' (GitHub Actions groups, the default when running in GitHub Actions) and 'none'")
+	flags.IntVar(&o.triageLines, "triage-log-lines", 0, fmt.Sprintf("if larger than 0, write %s to the run dir when the run fails, with this many last lines of each log, ", triageBundleName)+
+		"the output of the failed commands (with --tee-command-output), the deployer metadata and the description of the not ready nodes and pods. "+
+		"The secrets recognized in the flags and the output of the commands are redacted, the bundle is uploaded with the artifacts")
+	flags.StringVar(&o.kubectlSkewVersion, "kubectl-skew", "", "for client-skew tests, the current kubectl version (e.g. v1.22.3) or version marker (e.g. stable or latest-1.22) to download "+
+		"along with the latest release of the previous minor version, the tester gets their paths in $KUBETEST2_KUBECTL_PATH and $KUBETEST2_SKEW_KUBECTL_PATH")
 }
 
 // assert that options implements deployer options
-var _ types.Options = &options{}
+var _ types.OptionsWithContext = &options{}
+var _ types.OptionsWithParallelBuild = &options{}
 
 func (o *options) HelpRequested() bool {
 	return o.help
@@ -240,12 +324,28 @@ func (o *options) SkipTestJUnitReport() bool {
 	return o.skipTestJUnitReport
 }
 
+func (o *options) ParallelBuild() bool {
+	return o.parallelBuild
+}
+
+func (o *options) kubectlSkew() string {
+	return o.kubectlSkewVersion
+}
+
+func (o *options) triageLogLines() int {
+	return o.triageLines
+}
+
+func (o *options) phaseMarkers() string {
+	return o.phaseMarkersFormat
+}
+
 func (o *options) RunID() string {
 	return o.runid
 }
 
 func (o *options) RunDir() string {
-	return filepath.Join(artifacts.BaseDir(), o.RunID())
+	return artifacts.RunDir(o.RunID())
 }
 
 // metadata used for CLI usage string
@@ -253,6 +353,7 @@ type usage struct {
 	kubetest2Flags *pflag.FlagSet
 	deployerFlags  *pflag.FlagSet
 	deployerName   string
+	subcommands    []Subcommand
 	testerName     string
 	testerUsage    string
 	// purely computed fields, see Default()
@@ -291,6 +392,14 @@ DeployerFlags(%s):
 		u.deployerUsage,
 	)
 
+	// add the subcommands of the deployer, if any
+	if len(u.subcommands) > 0 {
+		s += fmt.Sprintf("\nSubcommands(%s), run as `kubetest2 %s <Subcommand> [Flags] [DeployerFlags]`:\n", u.deployerName, u.deployerName)
+		for _, sub := range u.subcommands {
+			s += fmt.Sprintf("  %-20s %s\n", sub.Name, sub.Short)
+		}
+	}
+
 	// add tester info if we selected a tester and have it
 	if u.testerName != "" {
 		s += fmt.Sprintf(
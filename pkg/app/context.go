@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"k8s.io/klog"
+)
+
+// runContext holds the contexts handed out by options.Context()
+type runContext struct {
+	lock sync.Mutex
+	// current is the context returned by Context(), it's swapped to
+	// teardown once the run starts tearing down the cluster
+	current  context.Context
+	teardown context.Context
+}
+
+// startContext sets up the contexts of the run, which are cancelled on
+// --timeout and on SIGINT / SIGTERM. The first signal cancels the run, the
+// second one also cancels the teardown.
+// The returned function releases the resources and must be called once the
+// run finishes.
+func (o *options) startContext() func() {
+	var runCtx context.Context
+	var cancelRun context.CancelFunc
+	if o.timeout > 0 {
+		runCtx, cancelRun = context.WithTimeout(context.Background(), o.timeout)
+	} else {
+		runCtx, cancelRun = context.WithCancel(context.Background())
+	}
+	teardownCtx, cancelTeardown := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case sig := <-signals:
+				if i == 0 {
+					klog.Warningf("Received %v, cancelling the run", sig)
+					cancelRun()
+				} else {
+					klog.Warningf("Received %v again, cancelling the teardown", sig)
+					cancelTeardown()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	o.ctx = &runContext{
+		current:  runCtx,
+		teardown: teardownCtx,
+	}
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		cancelRun()
+		cancelTeardown()
+	}
+}
+
+// startTeardown swaps the context returned by Context() to the teardown
+// context, so the cluster can still be torn down after the run is cancelled.
+func (o *options) startTeardown() {
+	if o.ctx == nil {
+		return
+	}
+	o.ctx.lock.Lock()
+	defer o.ctx.lock.Unlock()
+	o.ctx.current = o.ctx.teardown
+}
+
+func (o *options) Context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	o.ctx.lock.Lock()
+	defer o.ctx.lock.Unlock()
+	return o.ctx.current
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestContextTimeout(t *testing.T) {
+	o := &options{timeout: 10 * time.Millisecond}
+	if err := o.Context().Err(); err != nil {
+		t.Fatalf("expected a background context before the run starts, got %v", err)
+	}
+	stop := o.startContext()
+
+	select {
+	case <-o.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run context to be done after the timeout")
+	}
+	if err := o.Context().Err(); err != context.DeadlineExceeded {
+		t.Errorf("expected the run context to time out, got %v", err)
+	}
+
+	o.startTeardown()
+	if err := o.Context().Err(); err != nil {
+		t.Errorf("expected the teardown context not to be done after the timeout, got %v", err)
+	}
+	stop()
+	if err := o.Context().Err(); err != context.Canceled {
+		t.Errorf("expected the teardown context to be cancelled once the run finishes, got %v", err)
+	}
+}
+
+func TestContextSignals(t *testing.T) {
+	o := &options{}
+	stop := o.startContext()
+	defer stop()
+	run := o.Context()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-run.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run context to be cancelled on the first signal")
+	}
+
+	o.startTeardown()
+	teardown := o.Context()
+	if err := teardown.Err(); err != nil {
+		t.Fatalf("expected the teardown context not to be cancelled on the first signal, got %v", err)
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-teardown.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the teardown context to be cancelled on the second signal")
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// kubectlReleaseURL is where the kubectl releases and the version markers
+// are published
+var kubectlReleaseURL = "https://dl.k8s.io/release"
+
+// kubectlCacheDir returns the directory the kubectl releases are cached in,
+// outside of the artifacts since they are the same across runs
+var kubectlCacheDir = func() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "could not find the cache dir for kubectl")
+	}
+	return filepath.Join(dir, "kubetest2", "kubectl"), nil
+}
+
+// kubectlVersionRe matches the released kubectl versions, e.g. v1.22.3
+var kubectlVersionRe = regexp.MustCompile(`^v1\.(\d+)\.\d+$`)
+
+// kubectlSkewOptions is implemented by the options which select the kubectl
+// versions of the client-skew tests
+type kubectlSkewOptions interface {
+	// kubectlSkew returns the current kubectl version or version marker,
+	// empty if no kubectl is acquired for the tester
+	kubectlSkew() string
+}
+
+// verifyKubectlSkew validates the --kubectl-skew flag
+func verifyKubectlSkew(value string) error {
+	if value == "" || kubectlVersionRe.MatchString(value) || !strings.HasPrefix(value, "v") {
+		return nil
+	}
+	return errors.Errorf("--kubectl-skew must be a version like v1.22.3 or a version marker like stable, got %q", value)
+}
+
+// acquireSkewKubectls downloads the current kubectl version and the latest
+// release of the previous minor version (N-1) into the cache, and returns
+// the environment exposing them to the tester, KUBETEST2_KUBECTL_PATH and
+// KUBETEST2_KUBECTL_VERSION for the current one, and
+// KUBETEST2_SKEW_KUBECTL_PATH and KUBETEST2_SKEW_KUBECTL_VERSION for N-1
+func acquireSkewKubectls(opts types.Options) ([]string, error) {
+	o, ok := opts.(kubectlSkewOptions)
+	if !ok || o.kubectlSkew() == "" {
+		return nil, nil
+	}
+	ctx := types.Context(opts)
+	current, err := resolveKubectlVersion(ctx, o.kubectlSkew())
+	if err != nil {
+		return nil, err
+	}
+	previous, err := previousMinorKubectlVersion(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheDir, err := kubectlCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for _, k := range []struct{ prefix, version string }{
+		{"KUBETEST2_KUBECTL", current},
+		{"KUBETEST2_SKEW_KUBECTL", previous},
+	} {
+		path := filepath.Join(cacheDir, k.version, runtime.GOOS+"-"+runtime.GOARCH, "kubectl")
+		if err := downloadKubectl(ctx, k.version, path); err != nil {
+			return nil, err
+		}
+		klog.Infof("Acquired kubectl %s at %s for the tester", k.version, path)
+		env = append(env, k.prefix+"_PATH="+path, k.prefix+"_VERSION="+k.version)
+	}
+	return env, nil
+}
+
+// resolveKubectlVersion returns the version of the version marker, e.g.
+// stable or latest-1.22, or the version itself
+func resolveKubectlVersion(ctx context.Context, value string) (string, error) {
+	if kubectlVersionRe.MatchString(value) {
+		return value, nil
+	}
+	data, err := httpGet(ctx, fmt.Sprintf("%s/%s.txt", kubectlReleaseURL, value))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not resolve the kubectl version marker %s", value)
+	}
+	version := strings.TrimSpace(string(data))
+	if !kubectlVersionRe.MatchString(version) {
+		return "", errors.Errorf("unexpected kubectl version %q of version marker %s", version, value)
+	}
+	return version, nil
+}
+
+// previousMinorKubectlVersion returns the latest stable release of the minor
+// version preceding the version
+func previousMinorKubectlVersion(ctx context.Context, version string) (string, error) {
+	match := kubectlVersionRe.FindStringSubmatch(version)
+	if match == nil {
+		return "", errors.Errorf("unexpected kubectl version %q", version)
+	}
+	minor, _ := strconv.Atoi(match[1])
+	if minor == 0 {
+		return "", errors.Errorf("kubectl %s has no previous minor version", version)
+	}
+	return resolveKubectlVersion(ctx, fmt.Sprintf("stable-1.%d", minor-1))
+}
+
+// downloadKubectl downloads the kubectl release to path and verifies its
+// checksum, unless it's already there. The release is written to a temp file
+// renamed to path, so the concurrent runs sharing the cache never see a
+// partial download.
+func downloadKubectl(ctx context.Context, version, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	url := fmt.Sprintf("%s/%s/bin/%s/%s/kubectl", kubectlReleaseURL, version, runtime.GOOS, runtime.GOARCH)
+	sum, err := httpGet(ctx, url+".sha256")
+	if err != nil {
+		return errors.Wrapf(err, "could not get the checksum of kubectl %s", version)
+	}
+	binary, err := httpGet(ctx, url)
+	if err != nil {
+		return errors.Wrapf(err, "could not download kubectl %s", version)
+	}
+	if actual := fmt.Sprintf("%x", sha256.Sum256(binary)); actual != strings.TrimSpace(string(sum)) {
+		return errors.Errorf("sha256 of kubectl %s does not match", version)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "kubectl-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(binary); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 1<<30))
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVerifyKubectlSkew(t *testing.T) {
+	for value, expectError := range map[string]bool{"": false, "stable": false, "latest-1.22": false, "v1.22.3": false, "v1.22": true} {
+		if err := verifyKubectlSkew(value); (err != nil) != expectError {
+			t.Errorf("unexpected error for %q: %v", value, err)
+		}
+	}
+}
+
+func TestSkewKubectls(t *testing.T) {
+	binary := []byte("kubectl")
+	kubectlPath := fmt.Sprintf("/v1.21.7/bin/%s/%s/kubectl", runtime.GOOS, runtime.GOARCH)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable.txt":
+			fmt.Fprintln(w, "v1.22.3")
+		case "/stable-1.21.txt":
+			fmt.Fprintln(w, "v1.21.7")
+		case kubectlPath:
+			w.Write(binary)
+		case kubectlPath + ".sha256":
+			fmt.Fprintf(w, "%x", sha256.Sum256(binary))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func(url string) { kubectlReleaseURL = url }(kubectlReleaseURL)
+	kubectlReleaseURL = server.URL
+
+	ctx := context.Background()
+	current, err := resolveKubectlVersion(ctx, "stable")
+	if err != nil || current != "v1.22.3" {
+		t.Fatalf("expected the stable version v1.22.3, got %q: %v", current, err)
+	}
+	previous, err := previousMinorKubectlVersion(ctx, current)
+	if err != nil || previous != "v1.21.7" {
+		t.Fatalf("expected the previous minor version v1.21.7, got %q: %v", previous, err)
+	}
+
+	dir, err := ioutil.TempDir("", "kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, previous, "kubectl")
+	if err := downloadKubectl(ctx, previous, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content, err := ioutil.ReadFile(path); err != nil || string(content) != string(binary) {
+		t.Errorf("expected the downloaded kubectl at %s, got %q: %v", path, content, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected the downloaded kubectl to be executable, got %v: %v", info, err)
+	}
+	if err := downloadKubectl(ctx, "v1.22.3", filepath.Join(dir, "v1.22.3", "kubectl")); err == nil {
+		t.Errorf("expected an error downloading a missing release")
+	}
+}
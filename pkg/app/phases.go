@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// the formats of the markers printed around the output of the phases
+const (
+	// plainPhaseMarkers are single lines which log viewers (e.g. Prow's
+	// Spyglass or Jenkins' collapsible sections) can be configured to fold on
+	plainPhaseMarkers = "plain"
+	// githubPhaseMarkers are GitHub Actions workflow commands, see
+	// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions#grouping-log-lines
+	githubPhaseMarkers = "github"
+	noPhaseMarkers     = "none"
+)
+
+// phaseMarkersFormatter is implemented by the options which select the format
+// of the phase markers
+type phaseMarkersFormatter interface {
+	phaseMarkers() string
+}
+
+// phaseMarkers prints the begin and end markers of the phases
+type phaseMarkers struct {
+	format string
+	out    io.Writer
+	lock   sync.Mutex
+}
+
+func newPhaseMarkers(opts types.Options, out io.Writer) *phaseMarkers {
+	format := plainPhaseMarkers
+	if f, ok := opts.(phaseMarkersFormatter); ok {
+		format = f.phaseMarkers()
+	}
+	return &phaseMarkers{format: format, out: out}
+}
+
+// verifyPhaseMarkers validates the --phase-markers flag
+func verifyPhaseMarkers(format string) error {
+	switch format {
+	case plainPhaseMarkers, githubPhaseMarkers, noPhaseMarkers:
+		return nil
+	}
+	return errors.Errorf("--phase-markers must be one of %v", []string{plainPhaseMarkers, githubPhaseMarkers, noPhaseMarkers})
+}
+
+// defaultPhaseMarkers returns the format of the markers used if
+// --phase-markers is not set, GitHub Actions groups when running in a GitHub
+// Actions workflow and the plain markers otherwise
+func defaultPhaseMarkers() string {
+	if os.Getenv("GITHUB_ACTIONS") == "true" {
+		return githubPhaseMarkers
+	}
+	return plainPhaseMarkers
+}
+
+// wrap returns f surrounded by the markers of the phase. Grouping is disabled
+// for the phases running concurrently with another one, as GitHub Actions
+// groups cannot overlap.
+func (p *phaseMarkers) wrap(name string, concurrent bool, f func() error) func() error {
+	if p.format == noPhaseMarkers {
+		return f
+	}
+	format := p.format
+	if concurrent {
+		format = plainPhaseMarkers
+	}
+	return func() error {
+		p.begin(format, name)
+		start := time.Now()
+		err := f()
+		p.end(format, name, time.Since(start), err)
+		return err
+	}
+}
+
+func (p *phaseMarkers) begin(format, name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	switch format {
+	case githubPhaseMarkers:
+		fmt.Fprintf(p.out, "::group::%s\n", name)
+	default:
+		fmt.Fprintf(p.out, "##### kubetest2 phase %s: BEGIN #####\n", name)
+	}
+}
+
+func (p *phaseMarkers) end(format, name string, duration time.Duration, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	result := "PASSED"
+	if err != nil {
+		result = "FAILED"
+	}
+	duration = duration.Round(time.Second)
+	switch format {
+	case githubPhaseMarkers:
+		fmt.Fprintf(p.out, "::endgroup::\n")
+		if err != nil {
+			// the annotation is shown in the summary of the workflow run
+			fmt.Fprintf(p.out, "::error title=kubetest2 %s::%s\n", name, escapeWorkflowCommand(err.Error()))
+		}
+	default:
+		fmt.Fprintf(p.out, "##### kubetest2 phase %s: END (%s in %v) #####\n", name, result, duration)
+	}
+}
+
+// escapeWorkflowCommand escapes the message of a GitHub Actions workflow
+// command, which must be on a single line
+func escapeWorkflowCommand(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPhaseMarkers(t *testing.T) {
+	testCases := []struct {
+		name       string
+		format     string
+		concurrent bool
+		err        error
+		expected   string
+	}{
+		{
+			name:     "plain",
+			format:   plainPhaseMarkers,
+			expected: "##### kubetest2 phase Up: BEGIN #####\n##### kubetest2 phase Up: END (PASSED in 0s) #####\n",
+		},
+		{
+			name:     "plain failure",
+			format:   plainPhaseMarkers,
+			err:      errors.New("boom"),
+			expected: "##### kubetest2 phase Up: BEGIN #####\n##### kubetest2 phase Up: END (FAILED in 0s) #####\n",
+		},
+		{
+			name:     "github",
+			format:   githubPhaseMarkers,
+			expected: "::group::Up\n::endgroup::\n",
+		},
+		{
+			name:     "github failure",
+			format:   githubPhaseMarkers,
+			err:      errors.New("cluster\ncreation failed"),
+			expected: "::group::Up\n::endgroup::\n::error title=kubetest2 Up::cluster%0Acreation failed\n",
+		},
+		{
+			name:       "github concurrent phase",
+			format:     githubPhaseMarkers,
+			concurrent: true,
+			expected:   "##### kubetest2 phase Up: BEGIN #####\n##### kubetest2 phase Up: END (PASSED in 0s) #####\n",
+		},
+		{
+			name:     "none",
+			format:   noPhaseMarkers,
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			p := &phaseMarkers{format: tc.format, out: out}
+			if err := p.wrap("Up", tc.concurrent, func() error { return tc.err })(); err != tc.err {
+				t.Errorf("expected the error of the phase %v, got %v", tc.err, err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("expected markers %q, got %q", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestVerifyPhaseMarkers(t *testing.T) {
+	for format, expectError := range map[string]bool{"plain": false, "github": false, "none": false, "": true, "jenkins": true} {
+		if err := verifyPhaseMarkers(format); (err != nil) != expectError {
+			t.Errorf("unexpected error for %q: %v", format, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// DefaultPresetsLocation is where the presets referenced by name are
+// published, it can be overridden with $KUBETEST2_PRESETS_LOCATION
+const DefaultPresetsLocation = "gs://kubetest2-presets"
+
+// preset is a published set of flags for a common job shape, loaded with
+// --preset before the flags on the command line
+type preset struct {
+	// Args are the kubetest2 and deployer flags
+	Args []string `yaml:"args"`
+	// TesterArgs are passed to the tester before the ones after `--`
+	TesterArgs []string `yaml:"testerArgs"`
+}
+
+// presetFlagValue returns the value of the --preset flag in the kubetest2 and
+// deployer args, or empty if it's not set
+func presetFlagValue(args []string) string {
+	value := ""
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "--preset=") {
+			value = strings.TrimPrefix(arg, "--preset=")
+		} else if arg == "--preset" && i+1 < len(args) {
+			value = args[i+1]
+		}
+	}
+	return value
+}
+
+// presetURL returns the location of the preset, which is either a gs:// URL,
+// a local file or the name of a preset in the presets location
+func presetURL(value string) string {
+	if strings.HasPrefix(value, "gs://") || strings.Contains(value, string(os.PathSeparator)) || strings.HasSuffix(value, ".yaml") {
+		return value
+	}
+	location := DefaultPresetsLocation
+	if l := os.Getenv("KUBETEST2_PRESETS_LOCATION"); l != "" {
+		location = l
+	}
+	return strings.TrimSuffix(location, "/") + "/" + value + ".yaml"
+}
+
+func loadPreset(url string) (*preset, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(url, "gs://") {
+		data, err = exec.Output(exec.Command("gsutil", "cat", url))
+	} else {
+		data, err = ioutil.ReadFile(url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preset %s: %v", url, err)
+	}
+	p := &preset{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse preset %s: %v", url, err)
+	}
+	return p, nil
+}
+
+// expandPreset prepends the flags of the preset selected with --preset, if
+// any, to args, so that the flags on the command line override them.
+// Note that the values of list flags are appended to the ones of the preset.
+func expandPreset(args []string) ([]string, error) {
+	value := presetFlagValue(args)
+	if value == "" {
+		return args, nil
+	}
+	url := presetURL(value)
+	p, err := loadPreset(url)
+	if err != nil {
+		return nil, err
+	}
+	return mergePresetArgs(p, args), nil
+}
+
+// mergePresetArgs returns the args with the preset args before the user args,
+// and the preset tester args before the user tester args
+func mergePresetArgs(p *preset, args []string) []string {
+	deployerArgs, testerArgs := splitArgs(args)
+	merged := append([]string{}, p.Args...)
+	merged = append(merged, deployerArgs...)
+	if len(p.TesterArgs) > 0 || len(testerArgs) > 0 {
+		merged = append(merged, "--")
+		merged = append(merged, p.TesterArgs...)
+		merged = append(merged, testerArgs...)
+	}
+	klog.V(1).Infof("Expanded the preset flags: %v", merged)
+	return merged
+}
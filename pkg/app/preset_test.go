@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestPresetFlagValue(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "no preset",
+			args: []string{"--up", "--down"},
+		},
+		{
+			name:     "preset with equals",
+			args:     []string{"--up", "--preset=gke-conformance"},
+			expected: "gke-conformance",
+		},
+		{
+			name:     "preset as the next arg",
+			args:     []string{"--preset", "gs://bucket/preset.yaml", "--up"},
+			expected: "gs://bucket/preset.yaml",
+		},
+		{
+			name: "preset in the tester args",
+			args: []string{"--up", "--", "--preset=gke-conformance"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := presetFlagValue(tc.args); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMergePresetArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		preset   preset
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "preset args only",
+			preset:   preset{Args: []string{"--up", "--num-nodes=3"}},
+			args:     []string{"--preset=gke-conformance", "--num-nodes=5"},
+			expected: []string{"--up", "--num-nodes=3", "--preset=gke-conformance", "--num-nodes=5"},
+		},
+		{
+			name:     "preset tester args",
+			preset:   preset{Args: []string{"--test=ginkgo"}, TesterArgs: []string{"--focus-regex=Conformance"}},
+			args:     []string{"--preset=gke-conformance", "--", "--parallel=30"},
+			expected: []string{"--test=ginkgo", "--preset=gke-conformance", "--", "--focus-regex=Conformance", "--parallel=30"},
+		},
+		{
+			name:     "user tester args only",
+			preset:   preset{Args: []string{"--up"}},
+			args:     []string{"--preset=gke-conformance", "--", "--parallel=30"},
+			expected: []string{"--up", "--preset=gke-conformance", "--", "--parallel=30"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := mergePresetArgs(&tc.preset, tc.args); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPresetURL(t *testing.T) {
+	if location, ok := os.LookupEnv("KUBETEST2_PRESETS_LOCATION"); ok {
+		os.Unsetenv("KUBETEST2_PRESETS_LOCATION")
+		defer os.Setenv("KUBETEST2_PRESETS_LOCATION", location)
+	}
+	for value, expected := range map[string]string{
+		"gke-conformance":         DefaultPresetsLocation + "/gke-conformance.yaml",
+		"gs://bucket/preset.yaml": "gs://bucket/preset.yaml",
+		"./presets/scale.yaml":    "./presets/scale.yaml",
+	} {
+		if actual := presetURL(value); actual != expected {
+			t.Errorf("expected %q for %q, got %q", expected, value, actual)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// runRecord is the summary of a run, written to run-summary.json in the run
+// dir and added to the run registry. The field names are valid BigQuery
+// column names.
+type runRecord struct {
+	RunID    string `json:"run_id"`
+	Deployer string `json:"deployer"`
+	Tester   string `json:"tester"`
+	// ConfigHash identifies the runs with the same kubetest2, deployer and
+	// tester flags, other than --run-id
+	ConfigHash      string                `json:"config_hash"`
+	Result          string                `json:"result"`
+	Error           string                `json:"error,omitempty"`
+	Started         time.Time             `json:"started"`
+	DurationSeconds float64               `json:"duration_seconds"`
+	Steps           []metadata.StepResult `json:"steps"`
+	// Retries and Zones are read from the "retries" and "zones" keys of the
+	// deployer metadata, if the deployer records them
+	Retries int      `json:"retries"`
+	Zones   []string `json:"zones"`
+	// Readiness is read from the metadata.ReadinessKey key of the deployer
+	// metadata, if the deployer records it
+	Readiness []metadata.ClusterReadiness `json:"readiness"`
+}
+
+// runRecorder is implemented by the options which describe the run for the
+// run record
+type runRecorder interface {
+	newRunRecord() runRecord
+	runRegistry() string
+}
+
+// configHash returns a hash of the args of the run, ignoring --run-id which
+// is unique to each run
+func configHash(deployerName string, args []string) string {
+	h := sha256.New()
+	fmt.Fprintln(h, deployerName)
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "--run-id=") {
+			continue
+		}
+		if args[i] == "--run-id" {
+			i++
+			continue
+		}
+		fmt.Fprintln(h, args[i])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
+
+// writeRunRecord writes the summary of the run to run-summary.json in the run
+// dir, and adds it to the run registry if one is configured. Failing to
+// report to the run registry does not fail the run.
+func writeRunRecord(opts types.Options, recorder runRecorder, writer *metadata.Writer, d types.Deployer, started time.Time, result error) error {
+	record := recorder.newRunRecord()
+	record.Started = started.UTC()
+	record.DurationSeconds = time.Since(started).Seconds()
+	record.Steps = writer.Steps()
+	record.Result = "success"
+	if result != nil {
+		record.Result = "failure"
+		record.Error = result.Error()
+	}
+	record.Zones = []string{}
+	record.Readiness = []metadata.ClusterReadiness{}
+	if dWithMetadata, ok := d.(types.DeployerWithMetadata); ok {
+		if m, err := dWithMetadata.Metadata(); err == nil {
+			if retries, ok := m.Get("retries"); ok {
+				if n, ok := retries.(int); ok {
+					record.Retries = n
+				}
+			}
+			if zones, ok := m.Get("zones"); ok {
+				if z, ok := zones.([]string); ok {
+					record.Zones = z
+				}
+			}
+			if readiness, ok := m.Get(metadata.ReadinessKey); ok {
+				if r, ok := readiness.([]metadata.ClusterReadiness); ok {
+					record.Readiness = r
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal the run record")
+	}
+	path := filepath.Join(opts.RunDir(), "run-summary.json")
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "could not write the run record")
+	}
+
+	if sink := recorder.runRegistry(); sink != "" {
+		if err := reportRunRecord(sink, record.RunID, path); err != nil {
+			klog.Errorf("Failed to report the run to the run registry %s: %v", sink, err)
+		} else {
+			klog.V(0).Infof("Reported the run to the run registry %s", sink)
+		}
+	}
+	return nil
+}
+
+// verifyRunRegistry validates the --run-registry flag
+func verifyRunRegistry(sink string) error {
+	switch {
+	case sink == "":
+		return nil
+	case strings.HasPrefix(sink, "gs://") && strings.HasSuffix(sink, "/") && strings.Trim(strings.TrimPrefix(sink, "gs://"), "/") != "":
+		// each run writes <prefix><run-id>.json
+		return nil
+	case strings.HasPrefix(sink, "bq://") && strings.Count(strings.TrimPrefix(sink, "bq://"), ".") == 2:
+		return nil
+	}
+	return fmt.Errorf("--run-registry must be a gs://bucket/prefix/ URL or a bq://project.dataset.table table, found %q", sink)
+}
+
+// reportRunRecord adds the run record in the file to the run registry
+func reportRunRecord(sink, runID, path string) error {
+	if strings.HasPrefix(sink, "bq://") {
+		table := strings.TrimPrefix(sink, "bq://")
+		// bq expects project:dataset.table
+		table = strings.Replace(table, ".", ":", 1)
+		return exec.Command("bq", "insert", table, path).Run()
+	}
+
+	// GCS objects cannot be appended to, and composing onto a shared object
+	// races with concurrent runs, so each run writes its own object under
+	// the registry prefix.
+	return exec.Command("gsutil", "-q", "cp", path, registryObject(sink, runID)).Run()
+}
+
+// registryObject returns the object in the gs:// run registry the record of
+// the run is written to
+func registryObject(sink, runID string) string {
+	return strings.TrimSuffix(sink, "/") + "/" + runID + ".json"
+}
+
+// newRunRecord returns the run record filled with the options of the run
+func (o *options) newRunRecord() runRecord {
+	return runRecord{
+		RunID:      o.RunID(),
+		Deployer:   o.deployerName,
+		Tester:     o.test,
+		ConfigHash: o.configHash,
+	}
+}
+
+func (o *options) runRegistry() string {
+	return o.runRegistrySink
+}
+
+var _ runRecorder = &options{}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"testing"
+)
+
+func TestConfigHash(t *testing.T) {
+	base := configHash("gke", []string{"--up", "--run-id=a", "--region=us-central1"})
+	if h := configHash("gke", []string{"--up", "--run-id", "b", "--region=us-central1"}); h != base {
+		t.Errorf("expected the hash to ignore --run-id, got %q and %q", base, h)
+	}
+	if h := configHash("gke", []string{"--up", "--region=us-east1"}); h == base {
+		t.Errorf("expected different flags to change the hash %q", base)
+	}
+	if h := configHash("kind", []string{"--up", "--region=us-central1"}); h == base {
+		t.Errorf("expected a different deployer to change the hash %q", base)
+	}
+}
+
+func TestVerifyRunRegistry(t *testing.T) {
+	testCases := []struct {
+		sink        string
+		expectError bool
+	}{
+		{sink: ""},
+		{sink: "gs://bucket/runs/"},
+		{sink: "gs://bucket/"},
+		{sink: "bq://project.dataset.table"},
+		{sink: "gs://bucket/runs/registry.jsonl", expectError: true},
+		{sink: "gs://", expectError: true},
+		{sink: "bq://dataset.table", expectError: true},
+		{sink: "/tmp/registry.jsonl", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.sink, func(t *testing.T) {
+			err := verifyRunRegistry(tc.sink)
+			if tc.expectError && err == nil {
+				t.Error("expected an error but got nil")
+			} else if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRegistryObject(t *testing.T) {
+	if object := registryObject("gs://bucket/runs/", "run-1"); object != "gs://bucket/runs/run-1.json" {
+		t.Errorf("unexpected registry object %q", object)
+	}
+}
@@ -0,0 +1,390 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+
+	"sigs.k8s.io/kubetest2/pkg/artifacts"
+	"sigs.k8s.io/kubetest2/pkg/process"
+)
+
+// MatrixCommand is the name of the shim command running a matrix of runs
+const MatrixCommand = "matrix"
+
+const matrixSummaryFile = "matrix-summary.json"
+
+// matrixRun is a run of the matrix, or a value of one of its axes
+type matrixRun struct {
+	// Name identifies the run, its run id is <matrix run id>-<name>
+	Name string `yaml:"name"`
+	// Deployer overrides the deployer of the matrix
+	Deployer string `yaml:"deployer"`
+	// Args are the kubetest2 and deployer flags
+	Args []string `yaml:"args"`
+	// TesterArgs are passed to the tester
+	TesterArgs []string `yaml:"testerArgs"`
+}
+
+// matrixConfig is the --config of kubetest2 matrix. The runs are the
+// cross product of the axes, if any, followed by the explicit runs, each with
+// the args of the matrix before its own.
+type matrixConfig struct {
+	Deployer   string        `yaml:"deployer"`
+	Args       []string      `yaml:"args"`
+	TesterArgs []string      `yaml:"testerArgs"`
+	Axes       [][]matrixRun `yaml:"axes"`
+	Runs       []matrixRun   `yaml:"runs"`
+}
+
+// matrixResult is the outcome of a run, written to the matrix summary
+type matrixResult struct {
+	Name            string  `json:"name"`
+	RunID           string  `json:"run_id"`
+	Deployer        string  `json:"deployer"`
+	Result          string  `json:"result"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	RunDir          string  `json:"run_dir"`
+	// Record is the run-summary.json written by the run, if it got that far
+	Record json.RawMessage `json:"record,omitempty"`
+}
+
+// matrixSummary is written to matrix-summary.json in the artifacts
+type matrixSummary struct {
+	RunID  string         `json:"run_id"`
+	Passed int            `json:"passed"`
+	Failed int            `json:"failed"`
+	Runs   []matrixResult `json:"runs"`
+}
+
+// the flags set by the matrix for each run
+var matrixRunFlags = []string{"--run-id", "--artifacts", "--artifacts-layout"}
+
+var invalidRunNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// runName makes the name usable in the run id, which ends up in directory
+// and cloud resource names
+func runName(name string) string {
+	return strings.Trim(invalidRunNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// expandMatrix returns the runs of the matrix
+func expandMatrix(c *matrixConfig) ([]matrixRun, error) {
+	var runs []matrixRun
+	if len(c.Axes) > 0 {
+		runs = []matrixRun{{}}
+		for i, axis := range c.Axes {
+			if len(axis) == 0 {
+				return nil, fmt.Errorf("axis %d of the matrix has no values", i)
+			}
+			var product []matrixRun
+			for _, run := range runs {
+				for _, value := range axis {
+					next := matrixRun{
+						Name:       strings.TrimPrefix(run.Name+"-"+value.Name, "-"),
+						Deployer:   run.Deployer,
+						Args:       append(append([]string{}, run.Args...), value.Args...),
+						TesterArgs: append(append([]string{}, run.TesterArgs...), value.TesterArgs...),
+					}
+					if value.Deployer != "" {
+						next.Deployer = value.Deployer
+					}
+					product = append(product, next)
+				}
+			}
+			runs = product
+		}
+	}
+	runs = append(runs, c.Runs...)
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("the matrix has no runs")
+	}
+
+	names := make(map[string]bool, len(runs))
+	for i := range runs {
+		run := &runs[i]
+		name := runName(run.Name)
+		if name == "" {
+			return nil, fmt.Errorf("run %d of the matrix has no valid name, found %q", i, run.Name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate run %q in the matrix", name)
+		}
+		names[name] = true
+		run.Name = name
+
+		if run.Deployer == "" {
+			run.Deployer = c.Deployer
+		}
+		if run.Deployer == "" {
+			return nil, fmt.Errorf("run %q of the matrix has no deployer", name)
+		}
+		run.Args = append(append([]string{}, c.Args...), run.Args...)
+		run.TesterArgs = append(append([]string{}, c.TesterArgs...), run.TesterArgs...)
+		for _, arg := range run.Args {
+			for _, flag := range matrixRunFlags {
+				if arg == flag || strings.HasPrefix(arg, flag+"=") {
+					return nil, fmt.Errorf("%s is set by kubetest2 matrix, it cannot be set in run %q", flag, name)
+				}
+			}
+		}
+	}
+	return runs, nil
+}
+
+func loadMatrixConfig(path string) (*matrixConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the matrix config: %v", err)
+	}
+	c := &matrixConfig{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse the matrix config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// matrixArgs returns the args of the deployer binary for the run, which
+// writes its artifacts to their own <artifacts>/<run id> directory
+func matrixArgs(run matrixRun, runID, artifactsDir string) []string {
+	args := append([]string{}, run.Args...)
+	args = append(args,
+		"--run-id="+runID,
+		"--artifacts="+artifactsDir,
+		"--artifacts-layout=plain")
+	if len(run.TesterArgs) > 0 {
+		args = append(args, "--")
+		args = append(args, run.TesterArgs...)
+	}
+	return args
+}
+
+// execMatrixRun runs a deployer binary, it is a variable so that it can be
+// faked in the tests
+var execMatrixRun = process.ExecOutput
+
+// matrix runs the runs of the matrix
+type matrix struct {
+	runID        string
+	artifactsDir string
+	parallelism  int
+	out          io.Writer
+
+	lock      sync.Mutex
+	cancelled bool
+}
+
+// run executes the runs, at most parallelism at once, and returns their
+// results in the order of the runs. Once the matrix is cancelled the runs
+// not started yet are skipped.
+func (m *matrix) run(runs []matrixRun) []matrixResult {
+	results := make([]matrixResult, len(runs))
+	slots := make(chan struct{}, m.parallelism)
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, run matrixRun) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = m.runOne(run)
+		}(i, run)
+	}
+	wg.Wait()
+	return results
+}
+
+func (m *matrix) runOne(run matrixRun) matrixResult {
+	runID := m.runID + "-" + run.Name
+	result := matrixResult{
+		Name:     run.Name,
+		RunID:    runID,
+		Deployer: run.Deployer,
+		Result:   "failure",
+		RunDir:   filepath.Join(m.artifactsDir, runID),
+	}
+	m.lock.Lock()
+	cancelled := m.cancelled
+	m.lock.Unlock()
+	if cancelled {
+		result.Error = "skipped as the matrix was cancelled"
+		return result
+	}
+
+	started := time.Now()
+	err := m.exec(run, runID, result.RunDir)
+	result.DurationSeconds = time.Since(started).Seconds()
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Result = "success"
+	}
+	if record, err := ioutil.ReadFile(filepath.Join(result.RunDir, "run-summary.json")); err == nil && json.Valid(record) {
+		result.Record = record
+	}
+	m.printf("Run %s finished: %s in %v (artifacts: %s)\n", run.Name, strings.ToUpper(result.Result), time.Since(started).Round(time.Second), result.RunDir)
+	return result
+}
+
+func (m *matrix) exec(run matrixRun, runID, runDir string) error {
+	deployer, err := FindDeployer(run.Deployer)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(runDir, os.ModePerm); err != nil {
+		return err
+	}
+	// The output of the concurrent runs is kept apart in their artifacts.
+	log, err := os.Create(filepath.Join(runDir, "kubetest2.log"))
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	args := matrixArgs(run, runID, m.artifactsDir)
+	m.printf("Starting run %s: %s %s\n", run.Name, deployer, strings.Join(args, " "))
+	return execMatrixRun(deployer, args, os.Environ(), log)
+}
+
+func (m *matrix) printf(format string, a ...interface{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	fmt.Fprintf(m.out, format, a...)
+}
+
+// cancelOnSignal stops starting new runs on SIGINT / SIGTERM, while the
+// running ones get the signal forwarded to tear down.
+func (m *matrix) cancelOnSignal() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			m.lock.Lock()
+			m.cancelled = true
+			m.lock.Unlock()
+			m.printf("Received %v, not starting the remaining runs of the matrix\n", sig)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// summarize writes the matrix summary to the artifacts and prints it
+func (m *matrix) summarize(results []matrixResult) (*matrixSummary, error) {
+	summary := &matrixSummary{RunID: m.runID, Runs: results}
+	w := tabwriter.NewWriter(m.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tDEPLOYER\tRESULT\tDURATION\tRUN ID")
+	for _, r := range results {
+		if r.Result == "success" {
+			summary.Passed++
+		} else {
+			summary.Failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n", r.Name, r.Deployer, strings.ToUpper(r.Result),
+			time.Duration(r.DurationSeconds*float64(time.Second)).Round(time.Second), r.RunID)
+	}
+	m.lock.Lock()
+	w.Flush()
+	m.lock.Unlock()
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal the matrix summary")
+	}
+	if err := os.MkdirAll(m.artifactsDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(m.artifactsDir, matrixSummaryFile), append(data, '\n'), 0644); err != nil {
+		return nil, errors.Wrap(err, "could not write the matrix summary")
+	}
+	return summary, nil
+}
+
+// runMatrix implements kubetest2 matrix
+func runMatrix(cmd *cobra.Command, args []string) error {
+	flags := pflag.NewFlagSet(BinaryName+" "+MatrixCommand, pflag.ContinueOnError)
+	config := flags.String("config", "", "Path to the matrix config, a YAML file with the deployer, args and testerArgs shared by the runs, "+
+		"and the runs as the cross product of axes (lists of runs) and / or a list of runs, each with a name and its own deployer, args and testerArgs.")
+	parallelism := flags.Int("parallelism", 1, "Maximum number of runs executed at the same time.")
+	runID := flags.String("run-id", uuid.New().String()[:8], "Identifier of the matrix, the run id of each run is <run-id>-<run name>. "+
+		"Keep it short, as the deployers may truncate the run ids in the cloud resource names.")
+	artifactsDir := flags.String("artifacts", artifacts.BaseDir(), `Directory to put the artifacts of the runs under, each in its own <run id> directory, defaulting to "${ARTIFACTS:-./_artifacts}".`)
+	help := flags.BoolP("help", "h", false, "")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *help {
+		cmd.Printf("Usage:\n  %s %s --config=matrix.yaml [flags]\n\nFlags:\n%s", BinaryName, MatrixCommand, flags.FlagUsages())
+		return nil
+	}
+	if *config == "" {
+		return errors.New("--config must be set")
+	}
+	if *parallelism < 1 {
+		return errors.New("--parallelism must be at least 1")
+	}
+
+	c, err := loadMatrixConfig(*config)
+	if err != nil {
+		return err
+	}
+	runs, err := expandMatrix(c)
+	if err != nil {
+		return err
+	}
+
+	m := &matrix{
+		runID:        *runID,
+		artifactsDir: *artifactsDir,
+		parallelism:  *parallelism,
+		out:          cmd.OutOrStdout(),
+	}
+	stop := m.cancelOnSignal()
+	results := m.run(runs)
+	stop()
+	summary, err := m.summarize(results)
+	if err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return errors.Errorf("%d of the %d runs of the matrix failed", summary.Failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   matrixConfig
+		expected []matrixRun
+		err      bool
+	}{
+		{
+			name: "axes and runs",
+			config: matrixConfig{
+				Deployer:   "gke",
+				Args:       []string{"--up", "--down"},
+				TesterArgs: []string{"--focus-regex=Conformance"},
+				Axes: [][]matrixRun{
+					{
+						{Name: "1.29", Args: []string{"--cluster-version=1.29"}},
+						{Name: "1.30", Args: []string{"--cluster-version=1.30"}},
+					},
+					{
+						{Name: "COS", Args: []string{"--image-type=cos_containerd"}},
+						{Name: "Ubuntu", Args: []string{"--image-type=ubuntu_containerd"}, TesterArgs: []string{"--parallel=10"}},
+					},
+				},
+				Runs: []matrixRun{{Name: "kind", Deployer: "kind"}},
+			},
+			expected: []matrixRun{
+				{Name: "1-29-cos", Deployer: "gke", Args: []string{"--up", "--down", "--cluster-version=1.29", "--image-type=cos_containerd"}, TesterArgs: []string{"--focus-regex=Conformance"}},
+				{Name: "1-29-ubuntu", Deployer: "gke", Args: []string{"--up", "--down", "--cluster-version=1.29", "--image-type=ubuntu_containerd"}, TesterArgs: []string{"--focus-regex=Conformance", "--parallel=10"}},
+				{Name: "1-30-cos", Deployer: "gke", Args: []string{"--up", "--down", "--cluster-version=1.30", "--image-type=cos_containerd"}, TesterArgs: []string{"--focus-regex=Conformance"}},
+				{Name: "1-30-ubuntu", Deployer: "gke", Args: []string{"--up", "--down", "--cluster-version=1.30", "--image-type=ubuntu_containerd"}, TesterArgs: []string{"--focus-regex=Conformance", "--parallel=10"}},
+				{Name: "kind", Deployer: "kind", Args: []string{"--up", "--down"}, TesterArgs: []string{"--focus-regex=Conformance"}},
+			},
+		},
+		{
+			name: "axis value overriding the deployer",
+			config: matrixConfig{
+				Deployer: "gke",
+				Axes:     [][]matrixRun{{{Name: "gke"}, {Name: "gce", Deployer: "gce"}}},
+			},
+			expected: []matrixRun{
+				{Name: "gke", Deployer: "gke", Args: []string{}, TesterArgs: []string{}},
+				{Name: "gce", Deployer: "gce", Args: []string{}, TesterArgs: []string{}},
+			},
+		},
+		{
+			name:   "no runs",
+			config: matrixConfig{Deployer: "gke"},
+			err:    true,
+		},
+		{
+			name:   "empty axis",
+			config: matrixConfig{Deployer: "gke", Axes: [][]matrixRun{{}}},
+			err:    true,
+		},
+		{
+			name:   "duplicate run names",
+			config: matrixConfig{Deployer: "gke", Runs: []matrixRun{{Name: "a.b"}, {Name: "a-b"}}},
+			err:    true,
+		},
+		{
+			name:   "invalid run name",
+			config: matrixConfig{Deployer: "gke", Runs: []matrixRun{{Name: "..."}}},
+			err:    true,
+		},
+		{
+			name:   "no deployer",
+			config: matrixConfig{Runs: []matrixRun{{Name: "a"}}},
+			err:    true,
+		},
+		{
+			name:   "flag set by the matrix",
+			config: matrixConfig{Deployer: "gke", Runs: []matrixRun{{Name: "a", Args: []string{"--run-id=a"}}}},
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			runs, err := expandMatrix(&tc.config)
+			if err != nil {
+				if !tc.err {
+					st.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				st.Errorf("expected an error, got %v", runs)
+				return
+			}
+			if diff := cmp.Diff(tc.expected, runs); diff != "" {
+				st.Errorf("runs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMatrixArgs(t *testing.T) {
+	run := matrixRun{Name: "a", Args: []string{"--up"}, TesterArgs: []string{"--parallel=10"}}
+	expected := []string{"--up", "--run-id=m-a", "--artifacts=/artifacts", "--artifacts-layout=plain", "--", "--parallel=10"}
+	if diff := cmp.Diff(expected, matrixArgs(run, "m-a", "/artifacts")); diff != "" {
+		t.Errorf("args (-want +got):\n%s", diff)
+	}
+}
+
+func TestMatrixRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matrix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// FindDeployer looks the deployer binaries up in PATH.
+	bin := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(bin, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(bin, BinaryName+"-fake"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", bin)
+
+	artifactsDir := filepath.Join(dir, "artifacts")
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	defer func(e func(string, []string, []string, io.Writer) error) { execMatrixRun = e }(execMatrixRun)
+	execMatrixRun = func(argv0 string, args []string, env []string, w io.Writer) error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		defer func() {
+			lock.Lock()
+			running--
+			lock.Unlock()
+		}()
+
+		fmt.Fprintf(w, "running %s\n", argv0)
+		if args[0] == "--fail" {
+			return errors.New("exit status 1")
+		}
+		runDir := filepath.Join(artifactsDir, "m-pass")
+		return ioutil.WriteFile(filepath.Join(runDir, "run-summary.json"), []byte(`{"result":"success"}`), 0644)
+	}
+
+	var out bytes.Buffer
+	m := &matrix{runID: "m", artifactsDir: artifactsDir, parallelism: 2, out: &out}
+	results := m.run([]matrixRun{
+		{Name: "pass", Deployer: "fake", Args: []string{"--up"}},
+		{Name: "fail", Deployer: "fake", Args: []string{"--fail"}},
+		{Name: "missing", Deployer: "missing", Args: []string{"--up"}},
+	})
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 runs at once, got %d", maxRunning)
+	}
+
+	for i, expected := range []struct {
+		name   string
+		result string
+		record bool
+	}{
+		{name: "pass", result: "success", record: true},
+		{name: "fail", result: "failure"},
+		{name: "missing", result: "failure"},
+	} {
+		r := results[i]
+		if r.Name != expected.name || r.Result != expected.result || (r.Record != nil) != expected.record {
+			t.Errorf("unexpected result %+v, expected %+v", r, expected)
+		}
+		if r.RunID != "m-"+expected.name || r.RunDir != filepath.Join(artifactsDir, "m-"+expected.name) {
+			t.Errorf("unexpected run id %s and run dir %s", r.RunID, r.RunDir)
+		}
+	}
+	if log, err := ioutil.ReadFile(filepath.Join(artifactsDir, "m-pass", "kubetest2.log")); err != nil || !bytes.Contains(log, []byte("running")) {
+		t.Errorf("expected the output of the run in its artifacts, got %q, %v", log, err)
+	}
+
+	summary, err := m.summarize(results)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary.Passed != 1 || summary.Failed != 2 {
+		t.Errorf("expected 1 passed and 2 failed runs, got %d and %d", summary.Passed, summary.Failed)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(artifactsDir, matrixSummaryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written matrixSummary
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("failed to parse the matrix summary: %v", err)
+	}
+	if written.RunID != "m" || len(written.Runs) != 3 {
+		t.Errorf("unexpected matrix summary %s", data)
+	}
+}
+
+func TestMatrixRunCancelled(t *testing.T) {
+	m := &matrix{runID: "m", artifactsDir: "/artifacts", parallelism: 1, out: ioutil.Discard, cancelled: true}
+	results := m.run([]matrixRun{{Name: "a", Deployer: "fake"}})
+	if results[0].Result != "failure" || results[0].Error == "" {
+		t.Errorf("expected the run to be skipped, got %+v", results[0])
+	}
+}
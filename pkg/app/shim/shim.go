@@ -44,6 +44,8 @@ It orchestrates creating clusters, building kubernetes, deleting clusters, runni
 
 kubetest2 should be called with a deployer like: 'kubetest2 kind --help'
 
+A matrix of independent runs can be executed with: 'kubetest2 matrix --config=matrix.yaml'
+
 For more information see: https://github.com/kubernetes-sigs/kubetest2`
 
 // NewCommand returns a new cobra.Command for building the base image
@@ -84,6 +86,10 @@ func runE(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if args[0] == MatrixCommand {
+		return runMatrix(cmd, args[1:])
+	}
+
 	// otherwise find and execute the deployer with the remaining arguments
 	deployerName := args[0]
 	deployer, err := FindDeployer(deployerName)
@@ -108,6 +114,7 @@ func usage(cmd *cobra.Command) {
 	deployers := FindDeployers()
 	cmd.Println("Usage:")
 	cmd.Printf("  %s [deployer] [flags]\n", BinaryName)
+	cmd.Printf("  %s %s --config=matrix.yaml [flags]\n", BinaryName, MatrixCommand)
 	cmd.Println()
 	cmd.Println("Detected Deployers:")
 	for deployer := range deployers {
@@ -0,0 +1,330 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+const (
+	// triageBundleName is the name of the triage bundle in the run dir
+	triageBundleName = "triage.tar.gz"
+	// maxTriagePods is the maximum number of not ready pods described in the
+	// triage bundle
+	maxTriagePods = 50
+)
+
+// triageOptions is implemented by the options which configure the triage
+// bundle
+type triageOptions interface {
+	triageLogLines() int
+}
+
+// triageBundle assembles the triage bundle of a failed run: the last lines of
+// each log in the run dir, the output of the failed commands, the deployer
+// metadata and the description of the not ready nodes and pods, so that the
+// failure can be triaged from a single file.
+type triageBundle struct {
+	runDir string
+	lines  int
+	// files are the files collected before writing the bundle, by name
+	files map[string][]byte
+}
+
+func newTriageBundle(opts types.Options) *triageBundle {
+	lines := 0
+	if o, ok := opts.(triageOptions); ok {
+		lines = o.triageLogLines()
+	}
+	return &triageBundle{
+		runDir: opts.RunDir(),
+		lines:  lines,
+		files:  map[string][]byte{},
+	}
+}
+
+func (t *triageBundle) enabled() bool {
+	return t.lines > 0
+}
+
+// collectClusterState describes the not ready nodes and pods of the cluster,
+// it must be called before the cluster is torn down.
+func (t *triageBundle) collectClusterState(d types.Deployer) {
+	if !t.enabled() {
+		return
+	}
+	dWithKubeconfig, ok := d.(types.DeployerWithKubeconfig)
+	if !ok {
+		return
+	}
+	kubeconfig, err := dWithKubeconfig.Kubeconfig()
+	if err != nil {
+		klog.Warningf("Failed to get the kubeconfig for the triage bundle: %v", err)
+		return
+	}
+	kubectl := func(args ...string) ([]byte, error) {
+		return exec.Output(exec.Command("kubectl", append([]string{"--kubeconfig=" + kubeconfig, "--request-timeout=30s"}, args...)...))
+	}
+
+	var nodes nodeList
+	if out, err := kubectl("get", "nodes", "-o=json"); err != nil {
+		t.files["cluster/nodes.txt"] = []byte(fmt.Sprintf("failed to list the nodes: %v\n%s", err, out))
+	} else if err := json.Unmarshal(out, &nodes); err != nil {
+		klog.Warningf("Failed to parse the nodes for the triage bundle: %v", err)
+	} else if names := nodes.notReady(); len(names) > 0 {
+		out, _ := kubectl(append([]string{"describe", "nodes"}, names...)...)
+		t.files["cluster/not-ready-nodes.txt"] = out
+	}
+
+	var pods podList
+	if out, err := kubectl("get", "pods", "--all-namespaces", "-o=json"); err != nil {
+		t.files["cluster/pods.txt"] = []byte(fmt.Sprintf("failed to list the pods: %v\n%s", err, out))
+	} else if err := json.Unmarshal(out, &pods); err != nil {
+		klog.Warningf("Failed to parse the pods for the triage bundle: %v", err)
+	} else {
+		var described []string
+		for _, pod := range pods.notReady(maxTriagePods) {
+			out, _ := kubectl("describe", "pod", "--namespace="+pod[0], pod[1])
+			described = append(described, string(out))
+		}
+		if len(described) > 0 {
+			t.files["cluster/not-ready-pods.txt"] = []byte(strings.Join(described, "\n---\n"))
+		}
+	}
+}
+
+// write writes the bundle for the failed run to the run dir
+func (t *triageBundle) write(result error) error {
+	if !t.enabled() {
+		return nil
+	}
+	t.files["error.txt"] = []byte(result.Error() + "\n")
+
+	// the output of the failed commands is included in full
+	failed := map[string]bool{}
+	for _, path := range exec.FailedOutputs(exec.DefaultCmder) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		failed[path] = true
+		t.files["failed-commands/"+filepath.Base(path)] = content
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(t.runDir, "metadata.json")); err == nil {
+		t.files["metadata.json"] = content
+	}
+	err := filepath.Walk(t.runDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || failed[path] || filepath.Ext(path) != ".log" {
+			return err
+		}
+		rel, err := filepath.Rel(t.runDir, path)
+		if err != nil {
+			return err
+		}
+		content, err := lastLines(path, t.lines)
+		if err != nil {
+			klog.Warningf("Failed to read %s for the triage bundle: %v", path, err)
+			return nil
+		}
+		t.files["logs/"+filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not collect the logs for the triage bundle")
+	}
+
+	for name, content := range t.files {
+		t.files[name] = redact(content)
+	}
+	path := filepath.Join(t.runDir, triageBundleName)
+	if err := writeTarball(path, t.files); err != nil {
+		return errors.Wrap(err, "could not write the triage bundle")
+	}
+	klog.Infof("Wrote the triage bundle of the failed run to %s", path)
+	return nil
+}
+
+// secretPatterns match the secrets which may end up in the logs, e.g. in the
+// flags or the output of the commands, the first group is kept
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(--[a-z0-9-]*(?:token|password|secret)[a-z0-9-]*=)[^\s"']+`),
+	regexp.MustCompile(`(?i)("(?:token|password|access_token|refresh_token|client_secret|private_key)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9._~+/=-]+`),
+	// Google OAuth2 access tokens
+	regexp.MustCompile(`()ya29\.[0-9A-Za-z_.-]+`),
+}
+
+// redact replaces the secrets in the content of a file of the bundle, which
+// is uploaded with the artifacts
+func redact(content []byte) []byte {
+	for _, p := range secretPatterns {
+		content = p.ReplaceAll(content, []byte("${1}REDACTED"))
+	}
+	return content
+}
+
+// lastLines returns the last n lines of the file
+func lastLines(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// writeTarball writes the files to a gzipped tarball at path, in the triage/
+// directory
+func writeTarball(path string, files map[string][]byte) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    "triage/" + name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: now,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// nodeList is the subset of a kubectl list of nodes read by the triage bundle
+type nodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// notReady returns the names of the nodes whose Ready condition is not True
+func (l nodeList) notReady() []string {
+	var names []string
+	for _, node := range l.Items {
+		ready := false
+		for _, c := range node.Status.Conditions {
+			if c.Type == "Ready" && c.Status == "True" {
+				ready = true
+			}
+		}
+		if !ready {
+			names = append(names, node.Metadata.Name)
+		}
+	}
+	return names
+}
+
+// podList is the subset of a kubectl list of pods read by the triage bundle
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			Phase             string `json:"phase"`
+			ContainerStatuses []struct {
+				Ready bool `json:"ready"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// notReady returns the namespace and name of up to max pods which are
+// neither succeeded nor running with all their containers ready
+func (l podList) notReady(max int) [][2]string {
+	var pods [][2]string
+	for _, pod := range l.Items {
+		if len(pods) == max {
+			break
+		}
+		ready := pod.Status.Phase == "Succeeded"
+		if pod.Status.Phase == "Running" {
+			ready = true
+			for _, c := range pod.Status.ContainerStatuses {
+				ready = ready && c.Ready
+			}
+		}
+		if !ready {
+			pods = append(pods, [2]string{pod.Metadata.Namespace, pod.Metadata.Name})
+		}
+	}
+	return pods
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Layout arranges the artifacts of a run under the artifacts directory for
+// the CI system collecting them
+type Layout interface {
+	// RunDir returns the directory of the artifacts of the run
+	RunDir(baseDir, runID string) string
+	// JUnitDir returns the directory of the junit_*.xml files of the run
+	JUnitDir(baseDir, runID string) string
+}
+
+// plainLayout writes all the artifacts of a run to its own flat directory,
+// so the artifacts of the successive runs are kept side by side
+type plainLayout struct{}
+
+func (plainLayout) RunDir(baseDir, runID string) string {
+	return filepath.Join(baseDir, runID)
+}
+
+func (plainLayout) JUnitDir(baseDir, runID string) string {
+	return filepath.Join(baseDir, runID)
+}
+
+// prowLayout follows the layout of the Prow pod utilities, which give each
+// job its own $ARTIFACTS, and look for the junit_*.xml files at its top level
+type prowLayout struct{}
+
+func (prowLayout) RunDir(baseDir, runID string) string {
+	return baseDir
+}
+
+func (prowLayout) JUnitDir(baseDir, runID string) string {
+	return baseDir
+}
+
+// jenkinsLayout keeps the artifacts of each build apart, since the workspace
+// is reused across builds, and gathers the junit_*.xml files in a junit
+// directory matched by the JUnit plugin, e.g. with _artifacts/*/junit/*.xml
+type jenkinsLayout struct{}
+
+func (jenkinsLayout) RunDir(baseDir, runID string) string {
+	return filepath.Join(baseDir, runID)
+}
+
+func (jenkinsLayout) JUnitDir(baseDir, runID string) string {
+	return filepath.Join(baseDir, runID, "junit")
+}
+
+// layouts are the layouts selectable with --artifacts-layout
+var layouts = map[string]Layout{
+	"plain":   plainLayout{},
+	"prow":    prowLayout{},
+	"jenkins": jenkinsLayout{},
+}
+
+var layoutName string
+
+// layoutNames returns the sorted names of the layouts
+func layoutNames() []string {
+	names := []string{}
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// VerifyLayoutFlag validates the --artifacts-layout flag
+func VerifyLayoutFlag() error {
+	if layoutName == "" {
+		return nil
+	}
+	if _, ok := layouts[layoutName]; !ok {
+		return fmt.Errorf("--artifacts-layout must be one of %v, found %q", layoutNames(), layoutName)
+	}
+	return nil
+}
+
+// CurrentLayout returns the layout selected with --artifacts-layout, or the
+// plain layout if unset
+func CurrentLayout() Layout {
+	if layout, ok := layouts[layoutName]; ok {
+		return layout
+	}
+	return plainLayout{}
+}
+
+// RunDir returns the directory of the artifacts of the run in the current
+// layout
+func RunDir(runID string) string {
+	return CurrentLayout().RunDir(BaseDir(), runID)
+}
+
+// JUnitDir returns the directory of the junit_*.xml files of the run in the
+// current layout
+func JUnitDir(runID string) string {
+	return CurrentLayout().JUnitDir(BaseDir(), runID)
+}
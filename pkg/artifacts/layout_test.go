@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLayouts(t *testing.T) {
+	testCases := []struct {
+		layout           string
+		expectedRunDir   string
+		expectedJUnitDir string
+	}{
+		{layout: "plain", expectedRunDir: "/artifacts/run", expectedJUnitDir: "/artifacts/run"},
+		{layout: "prow", expectedRunDir: "/artifacts", expectedJUnitDir: "/artifacts"},
+		{layout: "jenkins", expectedRunDir: "/artifacts/run", expectedJUnitDir: "/artifacts/run/junit"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.layout, func(t *testing.T) {
+			layout := layouts[tc.layout]
+			if runDir := layout.RunDir("/artifacts", "run"); runDir != tc.expectedRunDir {
+				t.Errorf("expected run dir %q, got %q", tc.expectedRunDir, runDir)
+			}
+			if junitDir := layout.JUnitDir("/artifacts", "run"); junitDir != tc.expectedJUnitDir {
+				t.Errorf("expected junit dir %q, got %q", tc.expectedJUnitDir, junitDir)
+			}
+		})
+	}
+}
+
+func TestVerifyLayoutFlag(t *testing.T) {
+	defer func(name string) { layoutName = name }(layoutName)
+	for name, expectError := range map[string]bool{"": false, "prow": false, "jenkins": false, "plain": false, "buildkite": true} {
+		layoutName = name
+		if err := VerifyLayoutFlag(); (err != nil) != expectError {
+			t.Errorf("unexpected error for %q: %v", name, err)
+		}
+	}
+}
+
+func TestCurrentLayoutDefault(t *testing.T) {
+	defer func(name string) { layoutName = name }(layoutName)
+	layoutName = ""
+	// the layout is opt-in even in CI
+	defer os.Unsetenv("PROW_JOB_ID")
+	os.Setenv("PROW_JOB_ID", "job")
+	if _, ok := CurrentLayout().(plainLayout); !ok {
+		t.Errorf("expected the plain layout by default, got %T", CurrentLayout())
+	}
+}
@@ -67,6 +67,9 @@ func BindFlags(flags *pflag.FlagSet) error {
 		return err
 	}
 	flags.StringVar(&baseDir, "artifacts", defaultArtifacts, `top-level directory to put artifacts under for each kubetest2 run, defaulting to "${ARTIFACTS:-./_artifacts}". If using the ginkgo tester, this must be an absolute path.`)
+	flags.StringVar(&layoutName, "artifacts-layout", "", fmt.Sprintf("layout of the artifacts of the run under --artifacts, one of %v. "+
+		"plain writes them to a <run-id> directory, prow to the top level as the Prow pod utilities expect, and jenkins to a <run-id> directory with the junit files under junit/. "+
+		"Defaults to plain.", layoutNames()))
 	return nil
 }
 
@@ -25,18 +25,29 @@ import (
 	"k8s.io/klog"
 	"sigs.k8s.io/boskos/client"
 	"sigs.k8s.io/boskos/common"
+
+	"sigs.k8s.io/kubetest2/pkg/credentials"
 )
 
 // const (for the run) owner string for consistency between up and down
 var boskosOwner = os.Getenv("JOB_NAME") + "-kubetest2"
 
-// NewClient creates a boskos client for kubetest2 deployers.
-func NewClient(boskosLocation string) (*client.Client, error) {
+// NewClient creates a boskos client for kubetest2 deployers. The password of
+// the username is a reference resolved by the credentials package, only
+// used if the username is set. ctx is the context of the run.
+func NewClient(ctx context.Context, boskosLocation, username, password string) (*client.Client, error) {
+	if (username == "") != (password == "") {
+		return nil, fmt.Errorf("the boskos username and password must be set together")
+	}
+	passwordFile, err := credentials.Path(ctx, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the boskos password: %s", err)
+	}
 	boskos, err := client.NewClient(
 		boskosOwner,
 		boskosLocation,
-		"",
-		"",
+		username,
+		passwordFile,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create boskos client: %s", err)
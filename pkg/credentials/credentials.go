@@ -0,0 +1,264 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package credentials resolves the secrets used by the deployers, e.g. the
+// service account keys and the Boskos password, from a reference given in a
+// flag, so that they can come from a secret store instead of a key file baked
+// into the CI image.
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	adcRef              = "adc"
+	envPrefix           = "env:"
+	filePrefix          = "file:"
+	vaultPrefix         = "vault:"
+	secretManagerPrefix = "gcp-secret-manager:"
+)
+
+// Usage describes the references taken by New, for the help of the flags.
+const Usage = "One of a file path, file:PATH, env:VARIABLE, adc for the file of the application default credentials, " +
+	"vault:PATH#FIELD for a field of a Vault KV secret (read with the vault CLI and $VAULT_ADDR / $VAULT_TOKEN), " +
+	"or gcp-secret-manager:projects/PROJECT/secrets/SECRET[/versions/VERSION] for a GCP Secret Manager secret."
+
+// Provider provides a secret.
+type Provider interface {
+	// Secret returns the content of the secret. The commands run to access it
+	// are killed when ctx is done.
+	Secret(ctx context.Context) ([]byte, error)
+	// String describes where the secret comes from, without its content.
+	String() string
+}
+
+// output runs a command and returns its output, it is a variable so that it
+// can be faked in the tests. The default Cmder is not used as it may tee the
+// output, i.e. the secret, into the artifacts.
+var output = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.Output((&exec.LocalCmder{}).CommandContext(ctx, name, args...))
+}
+
+// New returns the provider of the referenced secret, see Usage, or nil if the
+// reference is empty.
+func New(ref string) (Provider, error) {
+	switch {
+	case ref == "":
+		return nil, nil
+	case ref == adcRef:
+		return adcProvider{}, nil
+	case strings.HasPrefix(ref, envPrefix):
+		name := strings.TrimPrefix(ref, envPrefix)
+		if name == "" {
+			return nil, fmt.Errorf("missing the environment variable of %q", ref)
+		}
+		return envProvider(name), nil
+	case strings.HasPrefix(ref, filePrefix):
+		path := strings.TrimPrefix(ref, filePrefix)
+		if path == "" {
+			return nil, fmt.Errorf("missing the path of %q", ref)
+		}
+		return fileProvider(path), nil
+	case strings.HasPrefix(ref, vaultPrefix):
+		parts := strings.Split(strings.TrimPrefix(ref, vaultPrefix), "#")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%q must be in the format of vault:PATH#FIELD", ref)
+		}
+		return vaultProvider{path: parts[0], field: parts[1]}, nil
+	case strings.HasPrefix(ref, secretManagerPrefix):
+		return newSecretManagerProvider(strings.TrimPrefix(ref, secretManagerPrefix))
+	}
+	return fileProvider(ref), nil
+}
+
+// Read returns the secret of the reference without the surrounding spaces,
+// or an empty string if the reference is empty. ctx is usually the context of
+// the run.
+func Read(ctx context.Context, ref string) (string, error) {
+	p, err := New(ref)
+	if err != nil || p == nil {
+		return "", err
+	}
+	secret, err := p.Secret(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the secret from %s: %v", p, err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// Path returns a path to a file holding the secret of the reference, for the
+// tools only taking files, or an empty string if the reference is empty.
+// The secrets not already in a file are written to a temp file only readable
+// by the user, which is left until Cleanup is called since some tools reload
+// it.
+func Path(ctx context.Context, ref string) (string, error) {
+	p, err := New(ref)
+	if err != nil || p == nil {
+		return "", err
+	}
+	switch f := p.(type) {
+	case fileProvider:
+		return string(f), nil
+	case adcProvider:
+		return f.path()
+	}
+	secret, err := p.Secret(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the secret from %s: %v", p, err)
+	}
+	// ioutil.TempFile creates the file with the 0600 permissions.
+	f, err := ioutil.TempFile("", "kubetest2-credentials-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	tempFilesLock.Lock()
+	tempFiles = append(tempFiles, f.Name())
+	tempFilesLock.Unlock()
+	if _, err := f.Write(secret); err != nil {
+		return "", fmt.Errorf("failed to write the secret from %s: %v", p, err)
+	}
+	return f.Name(), nil
+}
+
+var (
+	tempFilesLock sync.Mutex
+	// tempFiles are the files the secrets were written to by Path
+	tempFiles []string
+)
+
+// Cleanup deletes the temp files the secrets were written to by Path, it is
+// called when kubetest2 exits.
+func Cleanup() {
+	tempFilesLock.Lock()
+	defer tempFilesLock.Unlock()
+	for _, name := range tempFiles {
+		os.Remove(name)
+	}
+	tempFiles = nil
+}
+
+type fileProvider string
+
+func (f fileProvider) Secret(context.Context) ([]byte, error) {
+	return ioutil.ReadFile(string(f))
+}
+
+func (f fileProvider) String() string {
+	return "file " + string(f)
+}
+
+type envProvider string
+
+func (e envProvider) Secret(context.Context) ([]byte, error) {
+	secret, ok := os.LookupEnv(string(e))
+	if !ok {
+		return nil, fmt.Errorf("$%s is not set", string(e))
+	}
+	return []byte(secret), nil
+}
+
+func (e envProvider) String() string {
+	return "environment variable " + string(e)
+}
+
+// adcProvider reads the file of the application default credentials, which
+// is $GOOGLE_APPLICATION_CREDENTIALS or the one written by
+// gcloud auth application-default login.
+// Reference: https://cloud.google.com/docs/authentication/application-default-credentials
+type adcProvider struct{}
+
+func (adcProvider) path() (string, error) {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return path, nil
+	}
+	config := os.Getenv("CLOUDSDK_CONFIG")
+	if config == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		config = filepath.Join(home, ".config", "gcloud")
+	}
+	return filepath.Join(config, "application_default_credentials.json"), nil
+}
+
+func (a adcProvider) Secret(context.Context) ([]byte, error) {
+	path, err := a.path()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+func (adcProvider) String() string {
+	return "application default credentials"
+}
+
+// vaultProvider reads a field of a KV secret with the vault CLI, which is
+// configured by the VAULT_* environment variables.
+type vaultProvider struct {
+	path  string
+	field string
+}
+
+func (v vaultProvider) Secret(ctx context.Context) ([]byte, error) {
+	return output(ctx, "vault", "kv", "get", "-field="+v.field, v.path)
+}
+
+func (v vaultProvider) String() string {
+	return fmt.Sprintf("field %s of Vault secret %s", v.field, v.path)
+}
+
+// secretManagerProvider accesses a version of a GCP Secret Manager secret
+// with gcloud, using its active account.
+type secretManagerProvider struct {
+	project string
+	secret  string
+	version string
+}
+
+func newSecretManagerProvider(name string) (Provider, error) {
+	parts := strings.Split(name, "/")
+	if (len(parts) != 4 && len(parts) != 6) || parts[0] != "projects" || parts[1] == "" || parts[2] != "secrets" || parts[3] == "" ||
+		(len(parts) == 6 && (parts[4] != "versions" || parts[5] == "")) {
+		return nil, fmt.Errorf("%q must be in the format of %sprojects/PROJECT/secrets/SECRET[/versions/VERSION]", secretManagerPrefix+name, secretManagerPrefix)
+	}
+	p := secretManagerProvider{project: parts[1], secret: parts[3], version: "latest"}
+	if len(parts) == 6 {
+		p.version = parts[5]
+	}
+	return p, nil
+}
+
+func (s secretManagerProvider) Secret(ctx context.Context) ([]byte, error) {
+	return output(ctx, "gcloud", "secrets", "versions", "access", s.version,
+		"--secret="+s.secret,
+		"--project="+s.project)
+}
+
+func (s secretManagerProvider) String() string {
+	return fmt.Sprintf("version %s of GCP Secret Manager secret %s in project %s", s.version, s.secret, s.project)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ref      string
+		expected Provider
+		err      bool
+	}{
+		{name: "empty", ref: ""},
+		{name: "bare path", ref: "/etc/key.json", expected: fileProvider("/etc/key.json")},
+		{name: "file", ref: "file:key.json", expected: fileProvider("key.json")},
+		{name: "file without path", ref: "file:", err: true},
+		{name: "env", ref: "env:GCP_KEY", expected: envProvider("GCP_KEY")},
+		{name: "env without variable", ref: "env:", err: true},
+		{name: "adc", ref: "adc", expected: adcProvider{}},
+		{name: "vault", ref: "vault:secret/ci/gcp#key", expected: vaultProvider{path: "secret/ci/gcp", field: "key"}},
+		{name: "vault without field", ref: "vault:secret/ci/gcp", err: true},
+		{name: "vault with empty field", ref: "vault:secret/ci/gcp#", err: true},
+		{
+			name:     "secret manager",
+			ref:      "gcp-secret-manager:projects/ci/secrets/gcp-key",
+			expected: secretManagerProvider{project: "ci", secret: "gcp-key", version: "latest"},
+		},
+		{
+			name:     "secret manager with version",
+			ref:      "gcp-secret-manager:projects/ci/secrets/gcp-key/versions/3",
+			expected: secretManagerProvider{project: "ci", secret: "gcp-key", version: "3"},
+		},
+		{name: "secret manager without project", ref: "gcp-secret-manager:secrets/gcp-key", err: true},
+		{name: "secret manager with empty version", ref: "gcp-secret-manager:projects/ci/secrets/gcp-key/versions/", err: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			p, err := New(tc.ref)
+			if err != nil {
+				if !tc.err {
+					st.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				st.Errorf("expected an error, got %v", p)
+				return
+			}
+			if diff := cmp.Diff(tc.expected, p, cmp.AllowUnexported(vaultProvider{}, secretManagerProvider{})); diff != "" {
+				st.Errorf("provider (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := filepath.Join(dir, "key.json")
+	if err := ioutil.WriteFile(key, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("KUBETEST2_TEST_SECRET", " env-secret ")
+	defer os.Unsetenv("KUBETEST2_TEST_SECRET")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var commands []string
+	defer func(o func(context.Context, string, ...string) ([]byte, error)) { output = o }(output)
+	output = func(c context.Context, name string, args ...string) ([]byte, error) {
+		if c != ctx {
+			t.Errorf("expected %s to run under the context passed to Read", name)
+		}
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return []byte("command-secret\n"), nil
+	}
+
+	for ref, expected := range map[string]string{
+		"":                          "",
+		key:                         "file-secret",
+		"file:" + key:               "file-secret",
+		"env:KUBETEST2_TEST_SECRET": "env-secret",
+		"vault:secret/ci/gcp#key":   "command-secret",
+		"gcp-secret-manager:projects/ci/secrets/gcp-key/versions/3": "command-secret",
+	} {
+		secret, err := Read(ctx, ref)
+		if err != nil {
+			t.Errorf("unexpected error reading %q: %v", ref, err)
+			continue
+		}
+		if secret != expected {
+			t.Errorf("expected %q to be %q, got %q", ref, expected, secret)
+		}
+	}
+
+	expectedCommands := map[string]bool{
+		"vault kv get -field=key secret/ci/gcp":                          true,
+		"gcloud secrets versions access 3 --secret=gcp-key --project=ci": true,
+	}
+	if len(commands) != len(expectedCommands) {
+		t.Errorf("expected the commands %v, got %v", expectedCommands, commands)
+	}
+	for _, command := range commands {
+		if !expectedCommands[command] {
+			t.Errorf("unexpected command %q", command)
+		}
+	}
+
+	for _, ref := range []string{filepath.Join(dir, "missing"), "env:KUBETEST2_TEST_MISSING_SECRET"} {
+		if _, err := Read(ctx, ref); err == nil {
+			t.Errorf("expected an error reading %q", ref)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	if path, err := Path(context.Background(), "/etc/key.json"); err != nil || path != "/etc/key.json" {
+		t.Errorf("expected the key file path to be returned as is, got %q, %v", path, err)
+	}
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/etc/adc.json")
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path, err := Path(context.Background(), "adc"); err != nil || path != "/etc/adc.json" {
+		t.Errorf("expected the application default credentials path to be returned as is, got %q, %v", path, err)
+	}
+
+	os.Setenv("KUBETEST2_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("KUBETEST2_TEST_SECRET")
+	path, err := Path(context.Background(), "env:KUBETEST2_TEST_SECRET")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer Cleanup()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the secret file to only be readable by the user, got %v", info.Mode().Perm())
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "env-secret" {
+		t.Errorf("unexpected secret file content %q", content)
+	}
+
+	Cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected Cleanup to delete the secret file, got %v", err)
+	}
+}
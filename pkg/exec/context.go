@@ -0,0 +1,44 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import "context"
+
+// contextCmder creates the commands of Command with a context, so that they
+// are interrupted once it is done.
+type contextCmder struct {
+	Cmder
+	ctx func() context.Context
+}
+
+// WithContext returns a Cmder binding the commands created with Command to
+// the context returned by ctx at the time they are created, e.g. the context
+// of the run, so that every command is interrupted once the run times out or
+// is cancelled. CommandContext is passed through as is.
+func WithContext(cmder Cmder, ctx func() context.Context) Cmder {
+	return &contextCmder{Cmder: cmder, ctx: ctx}
+}
+
+var _ CmderWithFailedOutputs = &contextCmder{}
+
+func (c *contextCmder) FailedOutputs() []string {
+	return FailedOutputs(c.Cmder)
+}
+
+func (c *contextCmder) Command(name string, arg ...string) Cmd {
+	return c.Cmder.CommandContext(c.ctx(), name, arg...)
+}
@@ -45,12 +45,31 @@ type Cmder interface {
 	CommandContext(context.Context, string, ...string) Cmd
 }
 
+// CmderWithFailedOutputs adds the ability to report where the output of the
+// failed commands was kept, see LocalCmder.OutputDir. The Cmders wrapping
+// another one should implement it by forwarding to FailedOutputs.
+type CmderWithFailedOutputs interface {
+	Cmder
+	// FailedOutputs returns the files the output of the failed commands was
+	// teed into, in the order they failed.
+	FailedOutputs() []string
+}
+
+// FailedOutputs returns the files the output of the failed commands of cmder
+// was teed into, if it keeps them.
+func FailedOutputs(cmder Cmder) []string {
+	if c, ok := cmder.(CmderWithFailedOutputs); ok {
+		return c.FailedOutputs()
+	}
+	return nil
+}
+
 // DefaultCmder is a LocalCmder instance used for convenience, packages
 // originally using os/exec.Command can instead use pkg/kind/exec.Command
 // which forwards to this instance
-// TODO(bentheelder): swap this for testing
+// It may be swapped e.g. to wrap the commands, before any command is created
 // TODO(bentheelder): consider not using a global for this :^)
-var DefaultCmder = &LocalCmder{}
+var DefaultCmder Cmder = &LocalCmder{}
 
 // Command is a convenience wrapper over DefaultCmder.Command
 func Command(command string, args ...string) Cmd {
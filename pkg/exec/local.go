@@ -19,38 +19,85 @@ package exec
 import (
 	"context"
 	"io"
+	"os"
 	osexec "os/exec"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"k8s.io/klog"
 )
 
+// InterruptGracePeriod is how long a command created with a context is given
+// to exit after it is interrupted, before it is killed.
+var InterruptGracePeriod = 30 * time.Second
+
 // LocalCmd wraps os/exec.Cmd, implementing the exec.Cmd interface
 type LocalCmd struct {
 	*osexec.Cmd
+	// ctx is the context the command is bound to, may be nil
+	ctx context.Context
+	// outputPath is the file the output is teed into, empty if not teed
+	outputPath     string
+	condensedLines int
+	// failed collects the output file if the command fails
+	failed *failedOutputs
 }
 
 var _ Cmd = &LocalCmd{}
 
 // LocalCmder is a factory for LocalCmd, implementing Cmder
-type LocalCmder struct{}
+type LocalCmder struct {
+	// seq is the sequence number of the last command, it's the first field
+	// to be 64-bit aligned for atomic operations
+	seq uint64
+
+	// OutputDir is the directory the combined output of each command is
+	// teed into, if set. Only the first CondensedLines lines of the output
+	// are printed to the current process's stdout / stderr then.
+	OutputDir      string
+	CondensedLines int
+
+	failed failedOutputs
+}
 
-var _ Cmder = &LocalCmder{}
+var _ CmderWithFailedOutputs = &LocalCmder{}
 
 // Command returns a new exec.Cmd backed by Cmd
 func (c *LocalCmder) Command(name string, arg ...string) Cmd {
 	klog.V(2).Infof("⚙️ %s %s", name, strings.Join(arg, " "))
-	return &LocalCmd{
-		Cmd: osexec.Command(name, arg...),
-	}
+	return c.newCmd(name, arg...)
 }
 
-// CommandContext returns a new exec.Cmd with the context, backed by Cmd
+// CommandContext returns a new exec.Cmd with the context, backed by Cmd.
+// Unlike os/exec.CommandContext, the command is interrupted rather than
+// killed once the context is done, so that e.g. gcloud can clean up.
 func (c *LocalCmder) CommandContext(ctx context.Context, name string, arg ...string) Cmd {
+	if ctx == nil {
+		panic("nil Context")
+	}
 	klog.V(2).Infof("⚙️ %s %s", name, strings.Join(arg, " "))
-	return &LocalCmd{
-		Cmd: osexec.CommandContext(ctx, name, arg...),
+	cmd := c.newCmd(name, arg...)
+	cmd.ctx = ctx
+	return cmd
+}
+
+func (c *LocalCmder) newCmd(name string, arg ...string) *LocalCmd {
+	cmd := &LocalCmd{
+		Cmd: osexec.Command(name, arg...),
 	}
+	if c.OutputDir != "" {
+		cmd.outputPath = commandOutputPath(c.OutputDir, atomic.AddUint64(&c.seq, 1), name)
+		cmd.condensedLines = c.CondensedLines
+		cmd.failed = &c.failed
+	}
+	return cmd
+}
+
+// FailedOutputs returns the files the output of the failed commands was teed
+// into, in the order they failed.
+func (c *LocalCmder) FailedOutputs() []string {
+	return c.failed.list()
 }
 
 // SetEnv sets env
@@ -84,5 +131,60 @@ func (cmd *LocalCmd) SetDir(dir string) Cmd {
 
 // Run runs
 func (cmd *LocalCmd) Run() error {
-	return cmd.Cmd.Run()
+	if cmd.outputPath == "" || (discarded(cmd.Stdout) && discarded(cmd.Stderr)) {
+		return cmd.run()
+	}
+	tee, err := newTeeOutput(cmd.outputPath, cmd.Args[0], cmd.Args[1:])
+	if err != nil {
+		klog.Warningf("Failed to tee the output of %s: %v", cmd.Path, err)
+		return cmd.run()
+	}
+	cmd.Stdout, cmd.Stderr = tee.wrap(cmd.Stdout, cmd.Stderr, cmd.condensedLines)
+	err = cmd.run()
+	if closeErr := tee.close(err); closeErr != nil {
+		klog.Warningf("Failed to write the output of %s to %s: %v", cmd.Path, cmd.outputPath, closeErr)
+	}
+	if err != nil && cmd.failed != nil {
+		cmd.failed.add(cmd.outputPath)
+	}
+	return err
+}
+
+func (cmd *LocalCmd) run() error {
+	if cmd.ctx == nil {
+		return cmd.Cmd.Run()
+	}
+	if err := cmd.ctx.Err(); err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// set up a channel to monitor for when it exits
+	wait := make(chan error, 1)
+	go func() {
+		wait <- cmd.Wait()
+		close(wait)
+	}()
+
+	select {
+	case err := <-wait:
+		return err
+	case <-cmd.ctx.Done():
+	}
+
+	klog.V(2).Infof("Interrupting %s: %v", cmd.Path, cmd.ctx.Err())
+	// interrupting is not supported on all platforms, e.g. windows
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		_ = cmd.Process.Kill()
+	}
+	select {
+	case <-wait:
+	case <-time.After(InterruptGracePeriod):
+		klog.Warningf("%s did not exit %v after being interrupted, killing it", cmd.Path, InterruptGracePeriod)
+		_ = cmd.Process.Kill()
+		<-wait
+	}
+	return cmd.ctx.Err()
 }
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommandContextInterrupt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err := (&LocalCmder{}).CommandContext(ctx, "sleep", "30").Run()
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the command to be interrupted once the context is done, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("expected the command to exit once interrupted, took %v", elapsed)
+	}
+
+	if err := (&LocalCmder{}).CommandContext(ctx, "true").Run(); err != context.DeadlineExceeded {
+		t.Errorf("expected the command not to start once the context is done, got %v", err)
+	}
+}
+
+func TestCommandContextKill(t *testing.T) {
+	defer func(d time.Duration) { InterruptGracePeriod = d }(InterruptGracePeriod)
+	InterruptGracePeriod = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := (&LocalCmder{}).CommandContext(ctx, "sh", "-c", `trap "" INT; echo ready; exec sleep 30`)
+	ready := make(chan struct{})
+	cmd.SetStdout(writerFunc(func(p []byte) (int, error) {
+		close(ready)
+		return len(p), nil
+	}))
+	done := make(chan error, 1)
+	go func() { done <- cmd.Run() }()
+
+	<-ready
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected the command to be killed after ignoring the interrupt, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the command to be killed after the grace period")
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cmder := WithContext(&LocalCmder{}, func() context.Context { return ctx })
+	if err := cmder.Command("true").Run(); err != context.Canceled {
+		t.Errorf("expected the command to be bound to the context, got %v", err)
+	}
+	if err := cmder.CommandContext(context.Background(), "true").Run(); err != nil {
+		t.Errorf("expected the context of CommandContext to be kept, got %v", err)
+	}
+	if _, ok := cmder.(CmderWithFailedOutputs); !ok {
+		t.Errorf("expected the failed outputs of the wrapped cmder to be forwarded")
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultCondensedLines is the number of output lines of a teed command
+// printed to the current process's stdout / stderr by default.
+const DefaultCondensedLines = 20
+
+// commandOutputPath returns the file the output of the seq-th command is teed
+// into, named by the sequence and the binary.
+func commandOutputPath(dir string, seq uint64, name string) string {
+	return filepath.Join(dir, fmt.Sprintf("%04d-%s.log", seq, filepath.Base(name)))
+}
+
+// lockedWriter serializes the writes of stdout and stderr into the same file
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.w.Write(p)
+}
+
+// condensedWriter passes the first limit lines through to w, and counts the
+// lines after that.
+type condensedWriter struct {
+	w       io.Writer
+	limit   int
+	lines   int
+	dropped int
+}
+
+func (c *condensedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 && c.lines < c.limit {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			_, err := c.w.Write(p)
+			return n, err
+		}
+		if _, err := c.w.Write(p[:i+1]); err != nil {
+			return n, err
+		}
+		c.lines++
+		p = p[i+1:]
+	}
+	c.dropped += bytes.Count(p, []byte{'\n'})
+	return n, nil
+}
+
+// teeOutput tees the output of a command into the file at path, and condenses
+// the output written to the current process's stdout / stderr.
+type teeOutput struct {
+	file      *os.File
+	path      string
+	condensed []*condensedWriter
+}
+
+func newTeeOutput(path string, name string, args []string) (*teeOutput, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(f, "$ %s %s\n\n", name, strings.Join(args, " ")); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &teeOutput{file: f, path: path}, nil
+}
+
+// discarded returns whether the caller discards the output of the command,
+// e.g. as it may contain secrets, in which case it is not teed either.
+func discarded(w io.Writer) bool {
+	return w == ioutil.Discard
+}
+
+// wrap returns the writers to use for stdout and stderr of the command.
+func (t *teeOutput) wrap(stdout, stderr io.Writer, condensedLines int) (io.Writer, io.Writer) {
+	file := &lockedWriter{w: t.file}
+	wrapOne := func(w io.Writer) io.Writer {
+		switch {
+		case discarded(w):
+			return w
+		case w == nil:
+			return file
+		case w == os.Stdout || w == os.Stderr:
+			c := &condensedWriter{w: w, limit: condensedLines}
+			t.condensed = append(t.condensed, c)
+			return io.MultiWriter(file, c)
+		default:
+			return io.MultiWriter(file, w)
+		}
+	}
+	// os/exec uses a single pipe if stdout and stderr are the same writer
+	if stdout == stderr {
+		w := wrapOne(stdout)
+		return w, w
+	}
+	return wrapOne(stdout), wrapOne(stderr)
+}
+
+// close records the error of the command, closes the file and reports the
+// lines that were left out of the condensed output.
+func (t *teeOutput) close(cmdErr error) error {
+	if cmdErr != nil {
+		fmt.Fprintf(t.file, "\n# %v\n", cmdErr)
+	}
+	for _, c := range t.condensed {
+		if c.dropped > 0 {
+			fmt.Fprintf(c.w, "... %d more lines of output in %s\n", c.dropped, t.path)
+		}
+	}
+	return t.file.Close()
+}
+
+// failedOutputs collects the output files of the failed commands
+type failedOutputs struct {
+	lock  sync.Mutex
+	paths []string
+}
+
+func (f *failedOutputs) add(path string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.paths = append(f.paths, path)
+}
+
+func (f *failedOutputs) list() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string{}, f.paths...)
+}
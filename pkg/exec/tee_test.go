@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCondensedWriter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		writes   []string
+		limit    int
+		expected string
+		dropped  int
+	}{
+		{
+			name:     "under the limit",
+			writes:   []string{"a\nb\n"},
+			limit:    3,
+			expected: "a\nb\n",
+		},
+		{
+			name:     "over the limit",
+			writes:   []string{"a\nb\nc\nd\n"},
+			limit:    2,
+			expected: "a\nb\n",
+			dropped:  2,
+		},
+		{
+			name:     "lines split across writes",
+			writes:   []string{"a", "a\nb", "b\nc", "c\n"},
+			limit:    2,
+			expected: "aa\nbb\n",
+			dropped:  1,
+		},
+		{
+			name:    "no lines",
+			writes:  []string{"a\nb\n"},
+			limit:   0,
+			dropped: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			var out bytes.Buffer
+			c := &condensedWriter{w: &out, limit: tc.limit}
+			for _, w := range tc.writes {
+				if n, err := c.Write([]byte(w)); err != nil || n != len(w) {
+					st.Fatalf("expected %d bytes written, got %d, %v", len(w), n, err)
+				}
+			}
+			if out.String() != tc.expected {
+				st.Errorf("expected the output %q, got %q", tc.expected, out.String())
+			}
+			if c.dropped != tc.dropped {
+				st.Errorf("expected %d dropped lines, got %d", tc.dropped, c.dropped)
+			}
+		})
+	}
+}
+
+func TestTeeOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmder := &LocalCmder{OutputDir: dir, CondensedLines: 1}
+
+	var stdout bytes.Buffer
+	cmd := cmder.Command("sh", "-c", "echo out; echo err >&2")
+	cmd.SetStdout(&stdout)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdout.String() != "out\n" {
+		t.Errorf("expected the stdout to still be captured, got %q", stdout.String())
+	}
+	teed, err := ioutil.ReadFile(commandOutputPath(dir, 1, "sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"$ sh -c echo out; echo err >&2\n", "out\n", "err\n"} {
+		if !strings.Contains(string(teed), expected) {
+			t.Errorf("expected the teed output to contain %q, got %q", expected, teed)
+		}
+	}
+	if failed := cmder.FailedOutputs(); len(failed) != 0 {
+		t.Errorf("expected no failed command, got %v", failed)
+	}
+
+	if err := cmder.Command("sh", "-c", "exit 3").Run(); err == nil {
+		t.Fatal("expected the command to fail")
+	}
+	failed := cmder.FailedOutputs()
+	if len(failed) != 1 || failed[0] != commandOutputPath(dir, 2, "sh") {
+		t.Errorf("expected the output of the second command to be recorded as failed, got %v", failed)
+	}
+	if teed, err := ioutil.ReadFile(failed[0]); err != nil || !strings.Contains(string(teed), "# exit status 3") {
+		t.Errorf("expected the error to be recorded in the teed output, got %q, %v", teed, err)
+	}
+}
+
+func TestTeeOutputDiscarded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmder := &LocalCmder{OutputDir: dir}
+
+	cmd := cmder.Command("sh", "-c", "echo secret")
+	NoOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(commandOutputPath(dir, 1, "sh")); !os.IsNotExist(err) {
+		t.Errorf("expected the discarded output not to be teed, got %v", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd = cmder.Command("sh", "-c", "echo secret; echo err >&2")
+	cmd.SetStdout(ioutil.Discard)
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	teed, err := ioutil.ReadFile(commandOutputPath(dir, 2, "sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.TrimPrefix(string(teed), "$ sh -c echo secret; echo err >&2\n"), "secret") || !strings.Contains(string(teed), "err\n") {
+		t.Errorf("expected only the stderr to be teed, got %q", teed)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*")); len(matches) != 1 {
+		t.Errorf("expected a single teed output, got %v", matches)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package featuregates parses the --feature-gates and --runtime-config
+// values shared by the deployers, so that feature-gate test jobs have the
+// same interface whichever deployer they use.
+package featuregates
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a comma-separated list of Gate=true|false pairs, as taken by
+// the --feature-gates flag of the Kubernetes components.
+func Parse(value string) (map[string]bool, error) {
+	pairs, err := parsePairs("feature gate", value, true)
+	if err != nil {
+		return nil, err
+	}
+	gates := make(map[string]bool, len(pairs))
+	for k, v := range pairs {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of feature gate %q, must be true or false", v, k)
+		}
+		gates[k] = enabled
+	}
+	return gates, nil
+}
+
+// ParseRuntimeConfig parses a comma-separated list of api/version=value
+// pairs, as taken by the --runtime-config flag of kube-apiserver.
+// A bare key is enabled, e.g. "api/all" is the same as "api/all=true".
+func ParseRuntimeConfig(value string) (map[string]string, error) {
+	return parsePairs("runtime config", value, false)
+}
+
+// parsePairs parses a comma-separated list of key=value pairs. If
+// requireValue is false, a bare key has the value "true".
+func parsePairs(kind, value string, requireValue bool) (map[string]string, error) {
+	pairs := make(map[string]string)
+	if strings.TrimSpace(value) == "" {
+		return pairs, nil
+	}
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		k := strings.TrimSpace(kv[0])
+		if k == "" {
+			return nil, fmt.Errorf("missing %s name in %q", kind, s)
+		}
+		v := "true"
+		if len(kv) == 2 {
+			v = strings.TrimSpace(kv[1])
+		} else if requireValue {
+			return nil, fmt.Errorf("missing value of %s %q in %q", kind, k, s)
+		}
+		if _, ok := pairs[k]; ok {
+			return nil, fmt.Errorf("duplicate %s %q", kind, k)
+		}
+		pairs[k] = v
+	}
+	return pairs, nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package featuregates
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected map[string]bool
+		err      bool
+	}{
+		{name: "empty", value: "", expected: map[string]bool{}},
+		{name: "single", value: "A=true", expected: map[string]bool{"A": true}},
+		{name: "multiple with spaces", value: "A=true, B=false,", expected: map[string]bool{"A": true, "B": false}},
+		{name: "missing value", value: "A", err: true},
+		{name: "invalid value", value: "A=yes", err: true},
+		{name: "missing name", value: "=true", err: true},
+		{name: "duplicate", value: "A=true,A=false", err: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			gates, err := Parse(tc.value)
+			if (err != nil) != tc.err {
+				st.Fatalf("expected error: %v, got: %v", tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			if diff := cmp.Diff(tc.expected, gates); diff != "" {
+				st.Errorf("unexpected feature gates (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRuntimeConfig(t *testing.T) {
+	config, err := ParseRuntimeConfig("api/all=false,batch/v2alpha1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"api/all": "false", "batch/v2alpha1": "true"}
+	if diff := cmp.Diff(expected, config); diff != "" {
+		t.Errorf("unexpected runtime config (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kubeconfig implements the Kubeconfig() contract shared by the
+// deployers: the returned path is a single kubeconfig file, holding one
+// context per cluster named by ContextName, with the current context set to
+// the last cluster, and KUBECONFIG is set to it.
+package kubeconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ContextName returns the name of the context of a cluster in the kubeconfig
+// returned by a deployer, kubetest2-<provider>-<names...>, where the names
+// identify the cluster for the provider, e.g. the project and the cluster name
+// for GKE.
+func ContextName(provider string, names ...string) string {
+	return strings.Join(append([]string{"kubetest2", provider}, names...), "-")
+}
+
+// Source is a context of a kubeconfig file written by the tools of a
+// deployer, to be merged into the kubeconfig returned by the deployer.
+type Source struct {
+	// Path is the kubeconfig file.
+	Path string
+	// Context is the context in the file, the current context if empty.
+	Context string
+	// Name is the name of the context in the merged kubeconfig, see
+	// ContextName.
+	Name string
+}
+
+type config struct {
+	APIVersion     string         `yaml:"apiVersion"`
+	Kind           string         `yaml:"kind"`
+	Clusters       []namedCluster `yaml:"clusters"`
+	Contexts       []namedContext `yaml:"contexts"`
+	Users          []namedUser    `yaml:"users"`
+	CurrentContext string         `yaml:"current-context"`
+}
+
+type namedCluster struct {
+	Name    string        `yaml:"name"`
+	Cluster yaml.MapSlice `yaml:"cluster"`
+}
+
+type namedContext struct {
+	Name    string      `yaml:"name"`
+	Context contextInfo `yaml:"context"`
+}
+
+type contextInfo struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type namedUser struct {
+	Name string        `yaml:"name"`
+	User yaml.MapSlice `yaml:"user"`
+}
+
+// Merge writes the contexts of the sources into a single kubeconfig at path.
+// The cluster and the user of each context are renamed after it, so that the
+// sources cannot collide, and the current context is the one of the last
+// source. The relative file references of the sources are made absolute.
+func Merge(path string, sources ...Source) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("no kubeconfig to merge into %s", path)
+	}
+	merged := config{APIVersion: "v1", Kind: "Config"}
+	seen := make(map[string]bool, len(sources))
+	for _, s := range sources {
+		if seen[s.Name] {
+			return fmt.Errorf("duplicate context %q", s.Name)
+		}
+		seen[s.Name] = true
+
+		c, err := read(s.Path)
+		if err != nil {
+			return err
+		}
+		context, err := c.context(s.Context)
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.Path, err)
+		}
+		cluster, ok := c.cluster(context.Context.Cluster)
+		if !ok {
+			return fmt.Errorf("%s: cluster %q of context %q not found", s.Path, context.Context.Cluster, context.Name)
+		}
+		user, ok := c.user(context.Context.User)
+		if !ok {
+			return fmt.Errorf("%s: user %q of context %q not found", s.Path, context.Context.User, context.Name)
+		}
+
+		dir := filepath.Dir(s.Path)
+		merged.Clusters = append(merged.Clusters, namedCluster{
+			Name:    s.Name,
+			Cluster: absolutePaths(cluster.Cluster, dir, "certificate-authority"),
+		})
+		merged.Users = append(merged.Users, namedUser{
+			Name: s.Name,
+			User: absolutePaths(user.User, dir, "client-certificate", "client-key", "tokenFile"),
+		})
+		merged.Contexts = append(merged.Contexts, namedContext{
+			Name:    s.Name,
+			Context: contextInfo{Cluster: s.Name, User: s.Name, Namespace: context.Context.Namespace},
+		})
+		merged.CurrentContext = s.Name
+	}
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the kubeconfig: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	// The kubeconfig holds credentials.
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return fmt.Errorf("failed to write the kubeconfig: %v", err)
+	}
+	return nil
+}
+
+// Verify checks that path honors the Kubeconfig() contract: it is the absolute
+// path of a single kubeconfig file, whose contexts are named by ContextName
+// and whose current context is set.
+func Verify(path string) error {
+	if path == "" {
+		return fmt.Errorf("the kubeconfig path is empty")
+	}
+	if strings.Contains(path, string(os.PathListSeparator)) {
+		return fmt.Errorf("the kubeconfig %q is a list of files, it must be a single merged file", path)
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("the kubeconfig %q is not an absolute path", path)
+	}
+	c, err := read(path)
+	if err != nil {
+		return err
+	}
+	if c.CurrentContext == "" {
+		return fmt.Errorf("%s: the current context is not set", path)
+	}
+	if _, err := c.context(c.CurrentContext); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	prefix := ContextName("")
+	for _, context := range c.Contexts {
+		if !strings.HasPrefix(context.Name, prefix) {
+			return fmt.Errorf("%s: the context %q is not named %s<provider>-<cluster>", path, context.Name, prefix)
+		}
+	}
+	return nil
+}
+
+// Export verifies path and sets KUBECONFIG to it.
+func Export(path string) error {
+	if err := Verify(path); err != nil {
+		return err
+	}
+	return os.Setenv("KUBECONFIG", path)
+}
+
+// Write merges the sources into the kubeconfig at path made absolute, and
+// exports it. It returns the absolute path, to be returned by Kubeconfig().
+func Write(path string, sources ...Source) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if err := Merge(path, sources...); err != nil {
+		return "", err
+	}
+	if err := Export(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func read(path string) (*config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the kubeconfig: %v", err)
+	}
+	c := &config{}
+	if err := yaml.Unmarshal(content, c); err != nil {
+		return nil, fmt.Errorf("failed to parse the kubeconfig %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// context returns the context of the given name, or the current context, or
+// the only context of the kubeconfig if name is empty.
+func (c *config) context(name string) (namedContext, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" && len(c.Contexts) == 1 {
+		return c.Contexts[0], nil
+	}
+	if name == "" {
+		return namedContext{}, fmt.Errorf("no current context in the kubeconfig")
+	}
+	for _, context := range c.Contexts {
+		if context.Name == name {
+			return context, nil
+		}
+	}
+	return namedContext{}, fmt.Errorf("context %q not found", name)
+}
+
+func (c *config) cluster(name string) (namedCluster, bool) {
+	for _, cluster := range c.Clusters {
+		if cluster.Name == name {
+			return cluster, true
+		}
+	}
+	return namedCluster{}, false
+}
+
+func (c *config) user(name string) (namedUser, bool) {
+	for _, user := range c.Users {
+		if user.Name == name {
+			return user, true
+		}
+	}
+	return namedUser{}, false
+}
+
+// absolutePaths returns a copy of entry with the relative paths of the given
+// keys resolved against dir, as they are resolved by kubectl against the
+// directory of their kubeconfig.
+func absolutePaths(entry yaml.MapSlice, dir string, keys ...string) yaml.MapSlice {
+	resolved := make(yaml.MapSlice, 0, len(entry))
+	for _, item := range entry {
+		for _, key := range keys {
+			if path, ok := item.Value.(string); ok && item.Key == key && path != "" && !filepath.IsAbs(path) {
+				item.Value = filepath.Join(dir, path)
+			}
+		}
+		resolved = append(resolved, item)
+	}
+	return resolved
+}
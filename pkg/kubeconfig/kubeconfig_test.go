@@ -0,0 +1,268 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+// gkeKubeconfig is a kubeconfig as written by gcloud container clusters
+// get-credentials, with the user name shared by the token mode.
+const gkeKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: gke_project_us-central1-c_%[1]s
+  cluster:
+    certificate-authority-data: %[1]s-ca
+    server: https://%[1]s
+contexts:
+- name: gke_project_us-central1-c_%[1]s
+  context:
+    cluster: gke_project_us-central1-c_%[1]s
+    user: kubetest2
+users:
+- name: kubetest2
+  user:
+    token: %[1]s-token
+current-context: gke_project_us-central1-c_%[1]s
+`
+
+const kindKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kind-kind
+  cluster:
+    certificate-authority: certs/ca.crt
+    server: https://127.0.0.1:6443
+- name: kind-other
+  cluster:
+    server: https://127.0.0.1:6444
+contexts:
+- name: kind-kind
+  context:
+    cluster: kind-kind
+    user: kind-kind
+    namespace: test
+- name: kind-other
+  context:
+    cluster: kind-other
+    user: kind-other
+users:
+- name: kind-kind
+  user:
+    client-certificate: /certs/client.crt
+    client-key: certs/client.key
+- name: kind-other
+  user:
+    token: other
+current-context: kind-other
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestContextName(t *testing.T) {
+	if name := ContextName("gke", "project", "cluster"); name != "kubetest2-gke-project-cluster" {
+		t.Errorf("unexpected context name %q", name)
+	}
+	if name := ContextName("kind", "kind"); name != "kubetest2-kind-kind" {
+		t.Errorf("unexpected context name %q", name)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	kind := writeFile(t, dir, "kind", kindKubeconfig)
+	gke1 := writeFile(t, dir, "gke1", fmt.Sprintf(gkeKubeconfig, "cluster1"))
+	gke2 := writeFile(t, dir, "gke2", fmt.Sprintf(gkeKubeconfig, "cluster2"))
+
+	testCases := []struct {
+		name     string
+		sources  []Source
+		expected *config
+		err      bool
+	}{
+		{
+			name: "multiple clusters",
+			sources: []Source{
+				{Path: gke1, Name: "kubetest2-gke-project-cluster1"},
+				{Path: gke2, Name: "kubetest2-gke-project-cluster2"},
+			},
+			expected: &config{
+				APIVersion: "v1",
+				Kind:       "Config",
+				Clusters: []namedCluster{
+					{Name: "kubetest2-gke-project-cluster1", Cluster: yaml.MapSlice{
+						{Key: "certificate-authority-data", Value: "cluster1-ca"},
+						{Key: "server", Value: "https://cluster1"},
+					}},
+					{Name: "kubetest2-gke-project-cluster2", Cluster: yaml.MapSlice{
+						{Key: "certificate-authority-data", Value: "cluster2-ca"},
+						{Key: "server", Value: "https://cluster2"},
+					}},
+				},
+				Contexts: []namedContext{
+					{Name: "kubetest2-gke-project-cluster1", Context: contextInfo{Cluster: "kubetest2-gke-project-cluster1", User: "kubetest2-gke-project-cluster1"}},
+					{Name: "kubetest2-gke-project-cluster2", Context: contextInfo{Cluster: "kubetest2-gke-project-cluster2", User: "kubetest2-gke-project-cluster2"}},
+				},
+				Users: []namedUser{
+					{Name: "kubetest2-gke-project-cluster1", User: yaml.MapSlice{{Key: "token", Value: "cluster1-token"}}},
+					{Name: "kubetest2-gke-project-cluster2", User: yaml.MapSlice{{Key: "token", Value: "cluster2-token"}}},
+				},
+				CurrentContext: "kubetest2-gke-project-cluster2",
+			},
+		},
+		{
+			name:    "explicit context with relative paths",
+			sources: []Source{{Path: kind, Context: "kind-kind", Name: "kubetest2-kind-kind"}},
+			expected: &config{
+				APIVersion: "v1",
+				Kind:       "Config",
+				Clusters: []namedCluster{
+					{Name: "kubetest2-kind-kind", Cluster: yaml.MapSlice{
+						{Key: "certificate-authority", Value: filepath.Join(dir, "certs/ca.crt")},
+						{Key: "server", Value: "https://127.0.0.1:6443"},
+					}},
+				},
+				Contexts: []namedContext{
+					{Name: "kubetest2-kind-kind", Context: contextInfo{Cluster: "kubetest2-kind-kind", User: "kubetest2-kind-kind", Namespace: "test"}},
+				},
+				Users: []namedUser{
+					{Name: "kubetest2-kind-kind", User: yaml.MapSlice{
+						{Key: "client-certificate", Value: "/certs/client.crt"},
+						{Key: "client-key", Value: filepath.Join(dir, "certs/client.key")},
+					}},
+				},
+				CurrentContext: "kubetest2-kind-kind",
+			},
+		},
+		{
+			name:    "missing context",
+			sources: []Source{{Path: kind, Context: "kind-missing", Name: "kubetest2-kind-missing"}},
+			err:     true,
+		},
+		{
+			name:    "missing file",
+			sources: []Source{{Path: filepath.Join(dir, "missing"), Name: "kubetest2-kind-kind"}},
+			err:     true,
+		},
+		{
+			name: "duplicate name",
+			sources: []Source{
+				{Path: gke1, Name: "kubetest2-gke-project-cluster"},
+				{Path: gke2, Name: "kubetest2-gke-project-cluster"},
+			},
+			err: true,
+		},
+		{
+			name: "no source",
+			err:  true,
+		},
+	}
+
+	for i, tc := range testCases {
+		tc := tc
+		path := filepath.Join(dir, "merged", fmt.Sprintf("kubeconfig-%d", i))
+		t.Run(tc.name, func(st *testing.T) {
+			err := Merge(path, tc.sources...)
+			if err != nil {
+				if !tc.err {
+					st.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				st.Fatal("expected an error")
+			}
+			merged, err := read(path)
+			if err != nil {
+				st.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expected, merged); diff != "" {
+				st.Errorf("merged kubeconfig (-want +got):\n%s", diff)
+			}
+			if err := Verify(path); err != nil {
+				st.Errorf("the merged kubeconfig does not honor the contract: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gke1 := writeFile(t, dir, "gke1", fmt.Sprintf(gkeKubeconfig, "cluster1"))
+	gke2 := writeFile(t, dir, "gke2", fmt.Sprintf(gkeKubeconfig, "cluster2"))
+	merged := filepath.Join(dir, "merged")
+	if err := Merge(merged, Source{Path: gke1, Name: "kubetest2-gke-project-cluster1"}); err != nil {
+		t.Fatal(err)
+	}
+	noCurrent := writeFile(t, dir, "no-current", `apiVersion: v1
+kind: Config
+contexts:
+- name: kubetest2-kind-kind
+  context:
+    cluster: kubetest2-kind-kind
+    user: kubetest2-kind-kind
+`)
+
+	testCases := []struct {
+		name string
+		path string
+		err  bool
+	}{
+		{name: "merged", path: merged},
+		{name: "empty", path: "", err: true},
+		{name: "list of files", path: gke1 + string(os.PathListSeparator) + gke2, err: true},
+		{name: "relative", path: "kubeconfig", err: true},
+		{name: "missing", path: filepath.Join(dir, "missing"), err: true},
+		{name: "context not named by the scheme", path: gke1, err: true},
+		{name: "no current context", path: noCurrent, err: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			err := Verify(tc.path)
+			if tc.err && err == nil {
+				st.Error("expected an error")
+			}
+			if !tc.err && err != nil {
+				st.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
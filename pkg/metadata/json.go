@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+)
+
+// CustomJSON holds deployer specific metadata (e.g. properties of the
+// provisioned clusters), which kubetest2 writes out as metadata.json in the
+// run directory so testers and other tooling can consume it.
+type CustomJSON struct {
+	mu   sync.Mutex
+	data map[string]interface{}
+	// junitProperties are written out as properties of junit_runner.xml
+	junitProperties map[string]string
+}
+
+// NewCustomJSON returns an empty CustomJSON
+func NewCustomJSON() *CustomJSON {
+	return &CustomJSON{
+		data:            map[string]interface{}{},
+		junitProperties: map[string]string{},
+	}
+}
+
+// Add sets key to value, overriding any existing value.
+// value must be serializable to JSON.
+// It is safe to call Add from multiple goroutines.
+func (m *CustomJSON) Add(key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+}
+
+// Get returns the value of key and whether it is set
+func (m *CustomJSON) Get(key string) (interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.data[key]
+	return value, ok
+}
+
+// AddJUnitProperty sets the junit property name to value, overriding any
+// existing value. It is safe to call AddJUnitProperty from multiple goroutines.
+func (m *CustomJSON) AddJUnitProperty(name, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.junitProperties[name] = value
+}
+
+// JUnitProperties returns the names of the junit properties in sorted order,
+// and their values.
+func (m *CustomJSON) JUnitProperties() ([]string, map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.junitProperties))
+	values := make(map[string]string, len(m.junitProperties))
+	for name, value := range m.junitProperties {
+		names = append(names, name)
+		values[name] = value
+	}
+	sort.Strings(names)
+	return names, values
+}
+
+// Write writes out the metadata as indented JSON
+func (m *CustomJSON) Write(writer io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := json.NewEncoder(writer)
+	e.SetIndent("", "  ")
+	return e.Encode(m.data)
+}
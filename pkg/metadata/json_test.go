@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCustomJSON(t *testing.T) {
+	m := NewCustomJSON()
+	m.Add("windows-node-count", 3)
+	m.Add("autopilot", false)
+	m.Add("linux-node-count", 1)
+	// later values override the earlier ones
+	m.Add("linux-node-count", 2)
+
+	if v, ok := m.Get("autopilot"); !ok || v != false {
+		t.Errorf("expected autopilot to be set to false, got %v (set: %v)", v, ok)
+	}
+	if _, ok := m.Get("unknown"); ok {
+		t.Errorf("expected unknown to not be set")
+	}
+
+	out := bytes.NewBuffer([]byte{})
+	if err := m.Write(out); err != nil {
+		t.Fatalf("unexpected error for Write() %v", err)
+	}
+	expectedOutput := strings.TrimPrefix(`
+{
+  "autopilot": false,
+  "linux-node-count": 2,
+  "windows-node-count": 3
+}
+`, "\n")
+	if out.String() != expectedOutput {
+		t.Errorf("output did not match expected \n%v\nVERSUS:\n %v", expectedOutput, out.String())
+	}
+}
+
+func TestCustomJSONJUnitProperties(t *testing.T) {
+	m := NewCustomJSON()
+	m.AddJUnitProperty("image-type/kt2-b", "ubuntu_containerd")
+	m.AddJUnitProperty("image-type/kt2-a", "cos")
+	// later values override the earlier ones
+	m.AddJUnitProperty("image-type/kt2-a", "cos_containerd")
+
+	names, values := m.JUnitProperties()
+	expectedNames := []string{"image-type/kt2-a", "image-type/kt2-b"}
+	if strings.Join(names, ",") != strings.Join(expectedNames, ",") {
+		t.Errorf("expected property names %v, got %v", expectedNames, names)
+	}
+	if values["image-type/kt2-a"] != "cos_containerd" {
+		t.Errorf("expected image-type/kt2-a to be set to cos_containerd, got %q", values["image-type/kt2-a"])
+	}
+
+	// the junit properties are not part of metadata.json
+	out := bytes.NewBuffer([]byte{})
+	if err := m.Write(out); err != nil {
+		t.Fatalf("unexpected error for Write() %v", err)
+	}
+	if out.String() != "{}\n" {
+		t.Errorf("expected empty metadata, got %v", out.String())
+	}
+}
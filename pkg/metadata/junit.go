@@ -60,7 +60,20 @@ type testSuite struct {
 	Failures int      `xml:"failures,attr"`
 	Tests    int      `xml:"tests,attr"`
 	Time     float64  `xml:"time,attr"`
-	Cases    []testCase
+	// Properties is a pointer so that the element is omitted when empty
+	Properties *properties `xml:"properties,omitempty"`
+	Cases      []testCase
+}
+
+// properties holds key/value metadata of the run, e.g. the cluster
+// configuration, which is shown alongside the results.
+type properties struct {
+	Properties []property `xml:"property"`
+}
+
+type property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
 }
 
 func (t *testSuite) Write(writer io.Writer) error {
@@ -72,6 +85,13 @@ func (t *testSuite) Write(writer io.Writer) error {
 	return e.Encode(t)
 }
 
+func (t *testSuite) AddProperty(name, value string) {
+	if t.Properties == nil {
+		t.Properties = &properties{}
+	}
+	t.Properties.Properties = append(t.Properties.Properties, property{Name: name, Value: value})
+}
+
 func (t *testSuite) AddTestCase(tc testCase) {
 	t.Tests++
 	if tc.Failure != "" {
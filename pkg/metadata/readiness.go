@@ -0,0 +1,33 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+// ReadinessKey is the key of the deployer metadata holding the readiness of
+// the clusters, as a []ClusterReadiness, which kubetest2 copies into the run
+// summary to track the provisioning SLOs.
+const ReadinessKey = "readiness"
+
+// ClusterReadiness is how long a cluster took to become usable after its
+// creation started. The durations of the milestones not reached are 0.
+// The field names are valid BigQuery column names.
+type ClusterReadiness struct {
+	Project               string  `json:"project"`
+	Cluster               string  `json:"cluster"`
+	APIAvailableSeconds   float64 `json:"api_available_seconds"`
+	FirstNodeReadySeconds float64 `json:"first_node_ready_seconds"`
+	AllNodesReadySeconds  float64 `json:"all_nodes_ready_seconds"`
+}
@@ -18,11 +18,14 @@ package metadata
 
 import (
 	"io"
+	"sync"
 	"time"
 )
 
 // Writer manages writing out kubetest2 metadata, namely JUnit
 type Writer struct {
+	// lock guards suite, as steps may run concurrently
+	lock      sync.Mutex
 	suite     testSuite
 	start     time.Time
 	runnerOut io.Writer
@@ -61,12 +64,42 @@ func (w *Writer) WrapStep(name string, doStep func() error) error {
 	if v, ok := err.(JUnitError); ok {
 		tc.SystemOut = v.SystemOut()
 	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
 	w.suite.AddTestCase(tc)
 	return err
 }
 
+// AddProperty adds a property to the test suite, properties are written out
+// in the order they are added.
+func (w *Writer) AddProperty(name, value string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.suite.AddProperty(name, value)
+}
+
+// StepResult is the result of a step run with WrapStep
+type StepResult struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+	Failed  bool    `json:"failed"`
+}
+
+// Steps returns the results of the steps run so far, in order
+func (w *Writer) Steps() []StepResult {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	steps := []StepResult{}
+	for _, tc := range w.suite.Cases {
+		steps = append(steps, StepResult{Name: tc.Name, Seconds: tc.Time, Failed: tc.Failure != ""})
+	}
+	return steps
+}
+
 // Finish finalizes the metadata (time) and writes it out
 func (w *Writer) Finish() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
 	w.suite.Time = w.timeNow().Sub(w.start).Seconds()
 	return w.suite.Write(w.runnerOut)
 }
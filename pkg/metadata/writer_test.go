@@ -19,6 +19,7 @@ package metadata
 import (
 	"bytes"
 	"errors"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -190,3 +191,47 @@ func TestWriter(t *testing.T) {
 		})
 	}
 }
+
+func TestWriterProperties(t *testing.T) {
+	runnerOut := bytes.NewBuffer([]byte{})
+	w := NewWriter("kubetest2", runnerOut)
+	w.timeNow = makeFakeNow()
+	w.start = w.timeNow()
+	w.AddProperty("image-type/kt2-1", "cos_containerd")
+	w.AddProperty("image-type/kt2-2", "ubuntu_containerd")
+	if err := w.WrapStep("noop", func() error { return nil }); err != nil {
+		t.Errorf("got unexpected error for step noop %v", err)
+	}
+	if err := w.Finish(); err != nil {
+		t.Errorf("unexpected error for writer.Finish() %v", err)
+	}
+	expectedOutput := strings.TrimPrefix(
+		`
+<?xml version="1.0" encoding="UTF-8"?><testsuite name="kubetest2" failures="0" tests="1" time="3">
+    <properties>
+        <property name="image-type/kt2-1" value="cos_containerd"></property>
+        <property name="image-type/kt2-2" value="ubuntu_containerd"></property>
+    </properties>
+    <testcase name="noop" classname="kubetest2" time="1"></testcase>
+</testsuite>`,
+		"\n",
+	)
+	if output := runnerOut.String(); output != expectedOutput {
+		t.Errorf("runnerOut did not match expected \n%v\nVERSUS:\n %v", expectedOutput, output)
+	}
+}
+
+func TestWriterSteps(t *testing.T) {
+	w := NewWriter("kubetest2", bytes.NewBuffer([]byte{}))
+	w.timeNow = makeFakeNow()
+	w.start = w.timeNow()
+	_ = w.WrapStep("Up", func() error { return nil })
+	_ = w.WrapStep("Test", func() error { return errors.New("oh noes") })
+	expected := []StepResult{
+		{Name: "Up", Seconds: 1},
+		{Name: "Test", Seconds: 1, Failed: true},
+	}
+	if steps := w.Steps(); !reflect.DeepEqual(steps, expected) {
+		t.Errorf("expected steps %v, got %v", expected, steps)
+	}
+}
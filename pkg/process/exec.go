@@ -17,6 +17,7 @@ limitations under the License.
 package process
 
 import (
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -37,6 +38,17 @@ func Exec(argv0 string, args []string, env []string) error {
 	return execCmdWithSignals(cmd)
 }
 
+// ExecOutput is like Exec, but writes the stdout and stderr of the child
+// process to w, so that several processes can be run at once
+func ExecOutput(argv0 string, args []string, env []string, w io.Writer) error {
+	cmd := exec.Command(argv0, args...)
+	cmd.Env = env
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	return execCmdWithSignals(cmd)
+}
+
 func execCmdWithSignals(cmd *exec.Cmd) error {
 	// setup listener to forward all signals
 	// TODO(bentheelder): what should this buffer size be?
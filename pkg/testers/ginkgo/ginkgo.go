@@ -36,6 +36,7 @@ type Tester struct {
 	GinkgoArgs         string `desc:"Additional arguments supported by the ginkgo binary."`
 	Parallel           int    `desc:"Run this many tests in parallel at once."`
 	SkipRegex          string `desc:"Regular expression of jobs to skip."`
+	AutoSkip           bool   `desc:"Also skip the jobs unsupported by the capabilities of the clusters (e.g. autopilot, private-cluster, windows) recorded by the deployer in metadata.json in the run dir."`
 	FocusRegex         string `desc:"Regular expression of jobs to focus on."`
 	TestPackageVersion string `desc:"The ginkgo tester uses a test package made during the kubernetes build. The tester downloads this test package from one of the release tars published to GCS. Defaults to latest. Use \"gsutil ls gs://kubernetes-release/release/\" to find release names. Example: v1.20.0-alpha.0"`
 	TestPackageBucket  string `desc:"The bucket which release tars will be downloaded from to acquire the test package. Defaults to the main kubernetes project bucket."`
@@ -43,6 +44,7 @@ type Tester struct {
 	TestPackageMarker  string `desc:"The version marker in the directory containing the package version to download when unspecified. Defaults to latest.txt."`
 	TestArgs           string `desc:"Additional arguments supported by the e2e test framework (https://godoc.org/k8s.io/kubernetes/test/e2e/framework#TestContextType)."`
 	UseBuiltBinaries   bool   `desc:"determines whether to use binaries built by the deployer instead of extracting the test tars from GCS."`
+	KubectlSkew        bool   `desc:"Run the tests with the kubectl of the previous minor version acquired by kubetest2 --kubectl-skew, for client-skew tests."`
 
 	kubeconfigPath string
 	runDir         string
@@ -58,12 +60,28 @@ func (t *Tester) Test() error {
 		return err
 	}
 
+	skipRegex, err := t.skipRegex()
+	if err != nil {
+		return err
+	}
+	if t.AutoSkip {
+		klog.V(0).Infof("Skipping the jobs matching %q", skipRegex)
+	}
+
 	e2eTestArgs := []string{
 		"--kubeconfig=" + t.kubeconfigPath,
 		"--ginkgo.flakeAttempts=" + strconv.Itoa(t.FlakeAttempts),
-		"--ginkgo.skip=" + t.SkipRegex,
+		"--ginkgo.skip=" + skipRegex,
 		"--ginkgo.focus=" + t.FocusRegex,
-		"--report-dir=" + artifacts.BaseDir(),
+		"--report-dir=" + reportDir(),
+	}
+	if t.KubectlSkew {
+		kubectl := os.Getenv("KUBETEST2_SKEW_KUBECTL_PATH")
+		if kubectl == "" {
+			return fmt.Errorf("--kubectl-skew requires kubetest2 --kubectl-skew to acquire the kubectl of the previous minor version")
+		}
+		klog.V(0).Infof("Using kubectl %s at %s", os.Getenv("KUBETEST2_SKEW_KUBECTL_VERSION"), kubectl)
+		e2eTestArgs = append(e2eTestArgs, "--kubectl-path="+kubectl)
 	}
 	extraE2EArgs, err := shellquote.Split(t.TestArgs)
 	if err != nil {
@@ -165,6 +183,15 @@ func (t *Tester) Execute() error {
 	return t.Test()
 }
 
+// reportDir returns the directory to write the junit files to, which is
+// $KUBETEST2_JUNIT_DIR if kubetest2 keeps them apart from the other artifacts.
+func reportDir() string {
+	if dir := os.Getenv("KUBETEST2_JUNIT_DIR"); dir != "" {
+		return dir
+	}
+	return artifacts.BaseDir()
+}
+
 // initializes relevant information from the well defined kubetest2 environment variables.
 func (t *Tester) initKubetest2Info() error {
 	if dir, ok := os.LookupEnv("KUBETEST2_RUN_DIR"); ok {
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ginkgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// capabilitySkips are the tests to skip on the clusters with the capability
+// recorded in the deployer metadata.
+var capabilitySkips = map[string]string{
+	// GKE Autopilot does not allow privileged pods or access to the nodes.
+	"autopilot": `\[Disruptive\]|\[Privileged\]|\[NodeFeature:[^\]]*\]|\[Feature:SSH\]`,
+	// The nodes of private clusters cannot reach the internet.
+	"private-cluster": `\[Feature:Networking-IPv4\]|\[Feature:Networking-IPv6\]`,
+	// GKE Dataplane V2 replaces kube-proxy.
+	"dataplane-v2": `\[Feature:KubeProxyDaemonSetMigration\]|\[Feature:KubeProxyDaemonSetUpgrade\]`,
+}
+
+// missingCapabilitySkips are the tests to skip on the clusters without the
+// capability recorded in the deployer metadata.
+var missingCapabilitySkips = map[string]string{
+	"windows":        `\[sig-windows\]|\[Feature:Windows\]`,
+	"network-policy": `\[Feature:NetworkPolicy\]`,
+}
+
+// capabilitiesSkipRegex returns the regex of the tests to skip for the
+// capabilities of the clusters, or "" if none.
+func capabilitiesSkipRegex(capabilities []string) string {
+	has := map[string]bool{}
+	for _, c := range capabilities {
+		has[c] = true
+	}
+	var skips []string
+	for c, skip := range capabilitySkips {
+		if has[c] {
+			skips = append(skips, skip)
+		}
+	}
+	for c, skip := range missingCapabilitySkips {
+		if !has[c] {
+			skips = append(skips, skip)
+		}
+	}
+	sort.Strings(skips)
+	return strings.Join(skips, "|")
+}
+
+// readCapabilities reads the capabilities of the clusters from the deployer
+// metadata in the run dir.
+func readCapabilities(runDir string) ([]string, error) {
+	path := filepath.Join(runDir, "metadata.json")
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the deployer metadata: %v", err)
+	}
+	var m struct {
+		Capabilities *[]string `json:"capabilities"`
+	}
+	if err := json.Unmarshal(contents, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse the deployer metadata %s: %v", path, err)
+	}
+	if m.Capabilities == nil {
+		return nil, fmt.Errorf("the deployer metadata %s has no capabilities", path)
+	}
+	return *m.Capabilities, nil
+}
+
+// skipRegex returns --skip-regex, extended with the skips for the
+// capabilities of the clusters if --auto-skip is set.
+func (t *Tester) skipRegex() (string, error) {
+	if !t.AutoSkip {
+		return t.SkipRegex, nil
+	}
+	capabilities, err := readCapabilities(t.runDir)
+	if err != nil {
+		return "", err
+	}
+	skips := capabilitiesSkipRegex(capabilities)
+	if t.SkipRegex != "" && skips != "" {
+		return t.SkipRegex + "|" + skips, nil
+	}
+	return t.SkipRegex + skips, nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ginkgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCapabilitiesSkipRegex(t *testing.T) {
+	testCases := []struct {
+		name         string
+		capabilities []string
+		expected     string
+	}{
+		{
+			name:         "no capabilities",
+			capabilities: []string{},
+			expected:     `\[Feature:NetworkPolicy\]|\[sig-windows\]|\[Feature:Windows\]`,
+		},
+		{
+			name:         "all the missing capabilities",
+			capabilities: []string{"windows", "network-policy"},
+			expected:     "",
+		},
+		{
+			name:         "autopilot",
+			capabilities: []string{"autopilot", "network-policy", "windows"},
+			expected:     `\[Disruptive\]|\[Privileged\]|\[NodeFeature:[^\]]*\]|\[Feature:SSH\]`,
+		},
+		{
+			name:         "private cluster without windows",
+			capabilities: []string{"private-cluster", "network-policy"},
+			expected:     `\[Feature:Networking-IPv4\]|\[Feature:Networking-IPv6\]|\[sig-windows\]|\[Feature:Windows\]`,
+		},
+		{
+			name:         "unknown capability",
+			capabilities: []string{"gpu", "windows", "network-policy"},
+			expected:     "",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			if actual := capabilitiesSkipRegex(tc.capabilities); actual != tc.expected {
+				st.Errorf("expected skip regex %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReadCapabilities(t *testing.T) {
+	testCases := []struct {
+		name      string
+		metadata  string
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "capabilities",
+			metadata: `{"capabilities": ["autopilot", "windows"]}`,
+			expected: []string{"autopilot", "windows"},
+		},
+		{
+			name:     "no capability",
+			metadata: `{"capabilities": []}`,
+			expected: []string{},
+		},
+		{
+			name:      "deployer without capabilities",
+			metadata:  `{"clusters": ["kt2-1"]}`,
+			expectErr: true,
+		},
+		{
+			name:      "invalid metadata",
+			metadata:  `capabilities`,
+			expectErr: true,
+		},
+		{
+			name:      "no metadata",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(st *testing.T) {
+			st.Parallel()
+			dir, err := ioutil.TempDir("", "capabilities")
+			if err != nil {
+				st.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if tc.metadata != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, "metadata.json"), []byte(tc.metadata), 0644); err != nil {
+					st.Fatal(err)
+				}
+			}
+
+			capabilities, err := readCapabilities(dir)
+			if (err != nil) != tc.expectErr {
+				st.Fatalf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if tc.expectErr {
+				return
+			}
+			if len(capabilities) != len(tc.expected) {
+				st.Fatalf("expected capabilities %v, got %v", tc.expected, capabilities)
+			}
+			for i := range capabilities {
+				if capabilities[i] != tc.expected[i] {
+					st.Errorf("expected capabilities %v, got %v", tc.expected, capabilities)
+				}
+			}
+		})
+	}
+}
+
+func TestSkipRegex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "metadata.json"), []byte(`{"capabilities": ["windows", "network-policy", "dataplane-v2"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tester := &Tester{SkipRegex: `\[Slow\]`, runDir: dir}
+	if skips, err := tester.skipRegex(); err != nil || skips != `\[Slow\]` {
+		t.Errorf("expected --skip-regex without --auto-skip, got %q, %v", skips, err)
+	}
+	tester.AutoSkip = true
+	expected := `\[Slow\]|\[Feature:KubeProxyDaemonSetMigration\]|\[Feature:KubeProxyDaemonSetUpgrade\]`
+	if skips, err := tester.skipRegex(); err != nil || skips != expected {
+		t.Errorf("expected skip regex %q, got %q, %v", expected, skips, err)
+	}
+}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package types
 
+import "context"
+
 type incorrectUsageImpl struct {
 	helpText string
 }
@@ -36,3 +38,19 @@ func (i *incorrectUsageImpl) HelpText() string {
 func NewIncorrectUsage(helpText string) error {
 	return &incorrectUsageImpl{helpText}
 }
+
+// ParallelBuild returns true if opts request to build concurrently with Up(),
+// see OptionsWithParallelBuild.
+func ParallelBuild(opts Options) bool {
+	o, ok := opts.(OptionsWithParallelBuild)
+	return ok && o.ParallelBuild()
+}
+
+// Context returns the context of the run if opts provide one, see
+// OptionsWithContext, or context.Background() otherwise.
+func Context(opts Options) context.Context {
+	if o, ok := opts.(OptionsWithContext); ok {
+		return o.Context()
+	}
+	return context.Background()
+}
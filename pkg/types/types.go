@@ -19,7 +19,11 @@ limitations under the License.
 package types
 
 import (
+	"context"
+
 	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
 )
 
 // IncorrectUsage is an error with an addition HelpText() method
@@ -62,6 +66,28 @@ type Options interface {
 	RunDir() string
 }
 
+// OptionsWithParallelBuild adds the --parallel-build option to the Options,
+// see ParallelBuild.
+type OptionsWithParallelBuild interface {
+	Options
+
+	// if this is true, kubetest2 will be calling deployer.Build concurrently
+	// with deployer.Up, if the deployer supports it (see
+	// DeployerWithParallelBuild)
+	ParallelBuild() bool
+}
+
+// OptionsWithContext adds the context of the run to the Options, see Context.
+type OptionsWithContext interface {
+	Options
+
+	// Context returns the context commands should be bound to. It is done
+	// once the run times out or kubetest2 is interrupted.
+	// While tearing down the cluster it is only done if kubetest2 is
+	// interrupted again, so that Down can clean up after a timeout.
+	Context() context.Context
+}
+
 // Deployer defines the interface between kubetest and a deployer
 //
 // If any returned error meets the:
@@ -90,6 +116,9 @@ type DeployerWithKubeconfig interface {
 	Deployer
 
 	// Kubeconfig returns a path to a kubeconfig file for the cluster.
+	// The path should be a single file with one context per cluster, and
+	// KUBECONFIG set to it, see sigs.k8s.io/kubetest2/pkg/kubeconfig.
+	// kubetest2 warns about the kubeconfigs not following this.
 	Kubeconfig() (string, error)
 }
 
@@ -113,6 +142,38 @@ type DeployerWithPostTester interface {
 	PostTest(testErr error) error
 }
 
+// DeployerWithParallelBuild adds the ability to run Build() concurrently
+// with Up(). The deployers not implementing it always build before Up(), as
+// Up() may consume the artifacts of Build().
+type DeployerWithParallelBuild interface {
+	Deployer
+
+	// SupportsParallelBuild returns true if Build() can run concurrently with
+	// Up(), i.e. Up() does not need the artifacts of Build().
+	SupportsParallelBuild() bool
+}
+
+// DeployerWithMetadata adds the ability to expose deployer specific metadata,
+// e.g. properties of the provisioned clusters, to the tester.
+type DeployerWithMetadata interface {
+	Deployer
+
+	// Metadata returns the metadata kubetest2 writes out as metadata.json
+	// in the run directory after Up().
+	Metadata() (*metadata.CustomJSON, error)
+}
+
+// DeployerWithTesterEnv adds the ability to expose deployer specific
+// environment variables, e.g. the endpoints of the provisioned clusters, to
+// the tester.
+type DeployerWithTesterEnv interface {
+	Deployer
+
+	// TesterEnv returns the environment variables, in the KEY=value format,
+	// added to the environment of the tester.
+	TesterEnv() ([]string, error)
+}
+
 // Tester defines the "interface" between kubetest2 and a tester
 // The tester is executed as a separate binary during the Test() phase
 type Tester struct {